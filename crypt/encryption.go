@@ -4,6 +4,7 @@ import (
 	"context"
 	"crypto/aes"
 	"crypto/cipher"
+	"crypto/hmac"
 	"crypto/rand"
 	"crypto/sha256"
 	"fmt"
@@ -48,17 +49,21 @@ func GenerateRandomBytes(w io.Writer, numBytes int32) ([]byte, error) {
 	return result.GetData(), nil
 }
 
-// Encrypt encrypts data using 256-bit AES-GCM.  This both hides the content of
-// the data and provides a check that it hasn't been altered. Output takes the
-// form nonce|ciphertext|tag where '|' indicates concatenation.
-func Encrypt(plaintext []byte, key []byte) (ciphertext []byte, err error) {
+// newGCM returns 256-bit AES-GCM with the SHA-256 hash of key as the AES key.
+func newGCM(key []byte) (cipher.AEAD, error) {
 	k := sha256.Sum256(key)
 	block, err := aes.NewCipher(k[:])
 	if err != nil {
 		return nil, err
 	}
+	return cipher.NewGCM(block)
+}
 
-	gcm, err := cipher.NewGCM(block)
+// Encrypt encrypts data using 256-bit AES-GCM.  This both hides the content of
+// the data and provides a check that it hasn't been altered. Output takes the
+// form nonce|ciphertext|tag where '|' indicates concatenation.
+func Encrypt(plaintext []byte, key []byte) (ciphertext []byte, err error) {
+	gcm, err := newGCM(key)
 	if err != nil {
 		return nil, err
 	}
@@ -72,17 +77,31 @@ func Encrypt(plaintext []byte, key []byte) (ciphertext []byte, err error) {
 	return gcm.Seal(nonce, nonce, plaintext, nil), nil
 }
 
-// Decrypt decrypts data using 256-bit AES-GCM.  This both hides the content of
-// the data and provides a check that it hasn't been altered. Expects input
-// form nonce|ciphertext|tag where '|' indicates concatenation.
-func Decrypt(ciphertext []byte, key []byte) (plaintext []byte, err error) {
-	k := sha256.Sum256(key)
-	block, err := aes.NewCipher(k[:])
+// EncryptDeterministic encrypts data like Encrypt, but the nonce is an HMAC of
+// the plaintext instead of random, so the same plaintext and key always give the
+// same ciphertext. That makes ciphertexts comparable for equality, and reveals
+// which values are equal, only use it when equality matters. Output takes the
+// same form as Encrypt and is decrypted with Decrypt.
+func EncryptDeterministic(plaintext []byte, key []byte) (ciphertext []byte, err error) {
+	gcm, err := newGCM(key)
 	if err != nil {
 		return nil, err
 	}
 
-	gcm, err := cipher.NewGCM(block)
+	// the HMAC key is derived from key so it is never the AES key itself
+	k := sha256.Sum256(append([]byte("deterministic-nonce:"), key...))
+	mac := hmac.New(sha256.New, k[:])
+	mac.Write(plaintext)
+	nonce := mac.Sum(nil)[:gcm.NonceSize()]
+
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// Decrypt decrypts data using 256-bit AES-GCM.  This both hides the content of
+// the data and provides a check that it hasn't been altered. Expects input
+// form nonce|ciphertext|tag where '|' indicates concatenation.
+func Decrypt(ciphertext []byte, key []byte) (plaintext []byte, err error) {
+	gcm, err := newGCM(key)
 	if err != nil {
 		return nil, err
 	}
@@ -32,6 +32,7 @@ var NotClosedTrait = errorx.RegisterTrait("Not Closed")
 var UnknownTrait = errorx.RegisterTrait("Unknown")
 var CanceledTrait = errorx.RegisterTrait("Canceled")
 var AbortedTrait = errorx.RegisterTrait("Aborted")
+var ConflictTrait = errorx.RegisterTrait("Conflict")
 
 // http error status traits
 var HttpRedirectionTrait = errorx.RegisterTrait("Redirection")
@@ -55,6 +56,7 @@ var NotWrittenError = MustNeverError.NewSubtype("Not Written", UnableToWriteTrai
 var NotUpdatedError = MustNeverError.NewSubtype("Not Updated", UnableToWriteTrait)
 var NotReadError = MustNeverError.NewSubtype("Not Read", UnableToReadTrait)
 var DuplicateExistsError = errorx.IllegalState.NewSubtype("Duplicate Exists", errorx.Duplicate())
+var ConflictError = errorx.IllegalState.NewSubtype("Conflict", ConflictTrait, TemporaryTrait)
 
 // marshalling errors
 var ParseError = MustNeverError.NewSubtype("Unable to Parse", UnableToParseTrait)
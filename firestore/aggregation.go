@@ -0,0 +1,240 @@
+package firestore
+
+import (
+	"context"
+	"math"
+	"sync"
+
+	fs "cloud.google.com/go/firestore"
+	pb "cloud.google.com/go/firestore/apiv1/firestorepb"
+	"github.com/joomcode/errorx"
+	"golang.org/x/sync/errgroup"
+
+	errs "github.com/jarrodhroberson/ossgo/errors"
+)
+
+// MAX_AGGREGATIONS is the maximum number of aggregations Firestore runs in a single aggregation query.
+const MAX_AGGREGATIONS = 5
+
+// GROUP_BY_CONCURRENCY is the maximum number of aggregation queries GroupBy runs at the same time.
+const GROUP_BY_CONCURRENCY = 10
+
+type aggregationKind string
+
+const (
+	countAggregation   aggregationKind = "count"
+	sumAggregation     aggregationKind = "sum"
+	averageAggregation aggregationKind = "average"
+)
+
+// Aggregation is one aggregation of an aggregation query, its value is in the AggregationResults under its alias.
+type Aggregation struct {
+	kind  aggregationKind
+	alias string
+	field string
+}
+
+// CountOf counts the documents matching the query.
+func CountOf(alias string) Aggregation {
+	return Aggregation{kind: countAggregation, alias: alias}
+}
+
+// SumOf sums the numbers in the dot separated field of the documents matching the query, documents where
+// it is not a number are skipped. The sum is an int64 when every number is an integer, a float64 otherwise.
+func SumOf(alias string, field string) Aggregation {
+	return Aggregation{kind: sumAggregation, alias: alias, field: field}
+}
+
+// AverageOf averages the numbers in the dot separated field of the documents matching the query, documents
+// where it is not a number are skipped. The average is a float64, nil when no document has a number.
+func AverageOf(alias string, field string) Aggregation {
+	return Aggregation{kind: averageAggregation, alias: alias, field: field}
+}
+
+// apply adds the aggregation to aq.
+func (a Aggregation) apply(aq *fs.AggregationQuery) *fs.AggregationQuery {
+	switch a.kind {
+	case sumAggregation:
+		return aq.WithSum(a.field, a.alias)
+	case averageAggregation:
+		return aq.WithAvg(a.field, a.alias)
+	default:
+		return aq.WithCount(a.alias)
+	}
+}
+
+// validateAggregations checks there are between 1 and MAX_AGGREGATIONS aggregations with unique aliases.
+func validateAggregations(aggregations []Aggregation) error {
+	if len(aggregations) == 0 {
+		return errs.MustNotBeEmpty.New("at least one aggregation is required")
+	}
+	if len(aggregations) > MAX_AGGREGATIONS {
+		return errs.MaxSizeExceededError.New("%d aggregations exceed the maximum of %d per query", len(aggregations), MAX_AGGREGATIONS)
+	}
+	aliases := make(map[string]struct{}, len(aggregations))
+	for _, a := range aggregations {
+		if a.alias == "" {
+			return errorx.IllegalArgument.New("the %s aggregation has no alias", a.kind)
+		}
+		if a.kind != countAggregation && a.field == "" {
+			return errorx.IllegalArgument.New("the %s aggregation %s has no field", a.kind, a.alias)
+		}
+		if _, ok := aliases[a.alias]; ok {
+			return errs.DuplicateExistsError.New("alias %s is used by more than one aggregation", a.alias)
+		}
+		aliases[a.alias] = struct{}{}
+	}
+	return nil
+}
+
+// AggregationResults are the values of the aggregations of a query by alias: an int64 count, an int64 or
+// float64 sum and a float64 average, nil when no document had a number to average.
+type AggregationResults map[string]any
+
+// Int64 returns the value of the aggregation alias as an int64, a count or a sum of integers.
+func (r AggregationResults) Int64(alias string) (int64, error) {
+	v, ok := r[alias]
+	if !ok {
+		return 0, errs.NotFoundError.New("there is no aggregation %s", alias)
+	}
+	switch vt := v.(type) {
+	case int64:
+		return vt, nil
+	case float64:
+		if vt == math.Trunc(vt) && math.Abs(vt) < math.MaxInt64 {
+			return int64(vt), nil
+		}
+	}
+	return 0, errs.InvalidData.New("aggregation %s is %v, not an integer", alias, v)
+}
+
+// Float64 returns the value of the aggregation alias as a float64, an average of no numbers is a NotFoundError.
+func (r AggregationResults) Float64(alias string) (float64, error) {
+	v, ok := r[alias]
+	if !ok {
+		return 0, errs.NotFoundError.New("there is no aggregation %s", alias)
+	}
+	switch vt := v.(type) {
+	case int64:
+		return float64(vt), nil
+	case float64:
+		return vt, nil
+	case nil:
+		return 0, errs.NotFoundError.New("aggregation %s has no value, no document has a number to aggregate", alias)
+	}
+	return 0, errs.InvalidData.New("aggregation %s is %v, not a number", alias, v)
+}
+
+// aggregationValueOf converts the value of an aggregation as Firestore returns it to an int64, float64 or nil.
+func aggregationValueOf(alias string, v any) (any, error) {
+	pv, ok := v.(*pb.Value)
+	if !ok {
+		return nil, errs.InvalidData.New("aggregation %s is a %T, not a value", alias, v)
+	}
+	switch pv.GetValueType().(type) {
+	case *pb.Value_IntegerValue:
+		return pv.GetIntegerValue(), nil
+	case *pb.Value_DoubleValue:
+		return pv.GetDoubleValue(), nil
+	case *pb.Value_NullValue:
+		return nil, nil
+	}
+	return nil, errs.InvalidData.New("aggregation %s is %v, not a number", alias, pv)
+}
+
+// Aggregate runs the aggregations over the documents matching query in a single aggregation query,
+// without reading the documents. At most MAX_AGGREGATIONS aggregations can run in one query.
+//
+// Example usage:
+//
+//	results, err := firestore.Aggregate(ctx, client.Collection("orders").Where("status", "==", "paid"),
+//		firestore.CountOf("orders"), firestore.SumOf("revenue", "total"), firestore.AverageOf("basket", "total"))
+func Aggregate(ctx context.Context, query fs.Query, aggregations ...Aggregation) (AggregationResults, error) {
+	if err := validateAggregations(aggregations); err != nil {
+		return nil, err
+	}
+	aq := query.NewAggregationQuery()
+	for _, a := range aggregations {
+		aq = a.apply(aq)
+	}
+	result, err := aq.Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	results := make(AggregationResults, len(aggregations))
+	for _, a := range aggregations {
+		v, ok := result[a.alias]
+		if !ok {
+			return nil, errs.NotFoundError.New("the result of the aggregation query has no %s", a.alias)
+		}
+		if results[a.alias], err = aggregationValueOf(a.alias, v); err != nil {
+			return nil, err
+		}
+	}
+	return results, nil
+}
+
+// Aggregate runs the aggregations over the documents matching the query, see Aggregate.
+func (q Query[T]) Aggregate(ctx context.Context, aggregations ...Aggregation) (AggregationResults, error) {
+	query, err := q.Build()
+	if err != nil {
+		return nil, err
+	}
+	return Aggregate(ctx, query, aggregations...)
+}
+
+// Aggregate runs the aggregations over the documents matching the query built by build, without reading them.
+//
+// Example usage:
+//
+//	results, err := store.Aggregate(ctx, func(q firestore.Query[Order]) firestore.Query[Order] {
+//		return q.Where("status", firestore.QueryOps.Equals, "paid")
+//	}, firestore.CountOf("orders"), firestore.SumOf("revenue", "total"))
+func (c collectionStore[T]) Aggregate(ctx context.Context, build func(q Query[T]) Query[T], aggregations ...Aggregation) (AggregationResults, error) {
+	client, err := c.clientProvider(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return build(c.query(client)).Aggregate(ctx, aggregations...)
+}
+
+// GroupBy runs the aggregations of the query built by build once for each of the keys, restricted to the
+// documents whose field equals the key. Firestore can not group, so every key is a separate aggregation
+// query, up to GROUP_BY_CONCURRENCY of them run at the same time. A key without documents has a count of 0.
+// The first error stops the remaining queries and is returned.
+//
+// Example usage:
+//
+//	byStatus, err := firestore.GroupBy(ctx, store, nil, "status", []string{"pending", "paid", "shipped"},
+//		firestore.CountOf("orders"))
+//	paid, err := byStatus["paid"].Int64("orders")
+func GroupBy[T any, K comparable](ctx context.Context, store CollectionStore[T], build func(q Query[T]) Query[T], field string, keys []K, aggregations ...Aggregation) (map[K]AggregationResults, error) {
+	if err := validateAggregations(aggregations); err != nil {
+		return nil, err
+	}
+	var mu sync.Mutex
+	results := make(map[K]AggregationResults, len(keys))
+	errgp, gctx := errgroup.WithContext(ctx)
+	errgp.SetLimit(GROUP_BY_CONCURRENCY)
+	for _, key := range keys {
+		errgp.Go(func() error {
+			r, err := store.Aggregate(gctx, func(q Query[T]) Query[T] {
+				if build != nil {
+					q = build(q)
+				}
+				return q.Where(field, QueryOps.Equals, key)
+			}, aggregations...)
+			if err != nil {
+				return errorx.Decorate(err, "could not aggregate the documents with %s == %v", field, key)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			results[key] = r
+			return nil
+		})
+	}
+	if err := errgp.Wait(); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
@@ -0,0 +1,72 @@
+package firestore
+
+import (
+	"testing"
+
+	pb "cloud.google.com/go/firestore/apiv1/firestorepb"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestAggregationValueOf(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   any
+		want    any
+		wantErr bool
+	}{
+		{name: "count", value: &pb.Value{ValueType: &pb.Value_IntegerValue{IntegerValue: 42}}, want: int64(42)},
+		{name: "sum of doubles", value: &pb.Value{ValueType: &pb.Value_DoubleValue{DoubleValue: 1.5}}, want: 1.5},
+		{name: "average of nothing", value: &pb.Value{ValueType: &pb.Value_NullValue{NullValue: structpb.NullValue_NULL_VALUE}}, want: nil},
+		{name: "not a number", value: &pb.Value{ValueType: &pb.Value_StringValue{StringValue: "42"}}, wantErr: true},
+		{name: "not a value", value: int64(42), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := aggregationValueOf("alias", tt.value)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("aggregationValueOf() = %v %v, want %v and an error %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateAggregations(t *testing.T) {
+	tests := []struct {
+		name         string
+		aggregations []Aggregation
+		wantErr      bool
+	}{
+		{name: "valid", aggregations: []Aggregation{CountOf("count"), SumOf("sum", "total"), AverageOf("average", "total")}},
+		{name: "none", wantErr: true},
+		{name: "too many", aggregations: []Aggregation{CountOf("a"), CountOf("b"), CountOf("c"), CountOf("d"), CountOf("e"), CountOf("f")}, wantErr: true},
+		{name: "duplicate alias", aggregations: []Aggregation{CountOf("total"), SumOf("total", "total")}, wantErr: true},
+		{name: "no alias", aggregations: []Aggregation{CountOf("")}, wantErr: true},
+		{name: "no field", aggregations: []Aggregation{SumOf("sum", "")}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateAggregations(tt.aggregations); (err != nil) != tt.wantErr {
+				t.Errorf("validateAggregations() = %v, want an error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAggregationResults(t *testing.T) {
+	r := AggregationResults{"count": int64(3), "sum": float64(12), "fraction": 2.5, "average": nil}
+	if got, err := r.Int64("sum"); err != nil || got != 12 {
+		t.Errorf("Int64() of an integral sum = %d %v, want 12", got, err)
+	}
+	if _, err := r.Int64("fraction"); err == nil {
+		t.Errorf("Int64() of 2.5 succeeded, want an error")
+	}
+	if got, err := r.Float64("count"); err != nil || got != 3 {
+		t.Errorf("Float64() of a count = %v %v, want 3", got, err)
+	}
+	if _, err := r.Float64("average"); err == nil {
+		t.Errorf("Float64() of an average of no numbers succeeded, want an error")
+	}
+	if _, err := r.Int64("missing"); err == nil {
+		t.Errorf("Int64() of a missing alias succeeded, want an error")
+	}
+}
@@ -0,0 +1,506 @@
+package firestore
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"os"
+	"slices"
+	"testing"
+	"time"
+
+	fs "cloud.google.com/go/firestore"
+	"github.com/joomcode/errorx"
+
+	errs "github.com/jarrodhroberson/ossgo/errors"
+)
+
+type conformanceDoc struct {
+	ID            string    `json:"id" firestore:"id"`
+	Name          string    `json:"name" firestore:"name"`
+	Age           int       `json:"age" firestore:"age"`
+	Tags          []string  `json:"tags" firestore:"tags"`
+	CreatedAt     time.Time `json:"created_at" firestore:"created_at"`
+	LastUpdatedAt time.Time `json:"last_updated_at" firestore:"last_updated_at"`
+}
+
+func conformanceKeyer(d *conformanceDoc) string {
+	return d.ID
+}
+
+func conformanceDocs() []*conformanceDoc {
+	return []*conformanceDoc{
+		{ID: "a", Name: "Ada", Age: 36, Tags: []string{"math", "engines"}},
+		{ID: "b", Name: "Brian", Age: 28, Tags: []string{"unix"}},
+		{ID: "c", Name: "Claude", Age: 52, Tags: []string{"math", "information"}},
+		{ID: "d", Name: "Dennis", Age: 28, Tags: []string{"unix", "c"}},
+		{ID: "e", Name: "Edsger", Age: 72},
+	}
+}
+
+func ids(docs []*conformanceDoc) []string {
+	result := make([]string, 0, len(docs))
+	for _, d := range docs {
+		result = append(result, d.ID)
+	}
+	return result
+}
+
+// transactionRunner runs fn in a transaction on the database of the stores under test.
+type transactionRunner func(ctx context.Context, fn func(tx UnitOfWork) error) error
+
+// testCollectionStore is the behaviour every CollectionStore implementation must have,
+// newStore returns an empty store for every subtest and run runs the transactions of InTransaction.
+func testCollectionStore(t *testing.T, newStore func(t *testing.T) CollectionStore[conformanceDoc], run transactionRunner) {
+	seeded := func(t *testing.T) CollectionStore[conformanceDoc] {
+		store := newStore(t)
+		if err := store.BulkStore(slices.Values(conformanceDocs()), FAIL_ON_FIRST_ERROR); err != nil {
+			t.Fatalf("BulkStore() = %v", err)
+		}
+		return store
+	}
+	query := func(t *testing.T, store CollectionStore[conformanceDoc], build func(q Query[conformanceDoc]) Query[conformanceDoc]) []string {
+		docs := make([]*conformanceDoc, 0)
+		for d, err := range store.Query(t.Context(), build) {
+			if err != nil {
+				t.Fatalf("Query() = %v", err)
+			}
+			docs = append(docs, d)
+		}
+		return ids(docs)
+	}
+
+	t.Run("store and load", func(t *testing.T) {
+		store := newStore(t)
+		if _, err := store.Load("a"); !IsNotFound(err) {
+			t.Errorf("Load() of a missing document = %v, want a NotFound error", err)
+		}
+		if _, err := store.Store(conformanceDocs()[0]); err != nil {
+			t.Fatalf("Store() = %v", err)
+		}
+		got, err := store.Load("a")
+		if err != nil {
+			t.Fatalf("Load() = %v", err)
+		}
+		if got.Name != "Ada" || got.Age != 36 || !slices.Equal(got.Tags, []string{"math", "engines"}) {
+			t.Errorf("Load() = %+v, want the stored document", got)
+		}
+		if got.LastUpdatedAt.IsZero() || got.CreatedAt.IsZero() {
+			t.Errorf("Load() after Store() has created_at %v and last_updated_at %v, want both", got.CreatedAt, got.LastUpdatedAt)
+		}
+
+		// storing the loaded document again keeps the created_at of the document, not the one of the value
+		got.CreatedAt = time.Time{}
+		if _, err = store.Store(got); err != nil {
+			t.Fatalf("Store() = %v", err)
+		}
+		again, err := store.Load("a")
+		if err != nil {
+			t.Fatalf("Load() = %v", err)
+		}
+		if !again.CreatedAt.Equal(got.LastUpdatedAt) || again.LastUpdatedAt.Before(got.LastUpdatedAt) {
+			t.Errorf("Load() after a second Store() has created_at %v and last_updated_at %v, want created_at %v kept", again.CreatedAt, again.LastUpdatedAt, got.LastUpdatedAt)
+		}
+	})
+
+	t.Run("all", func(t *testing.T) {
+		store := seeded(t)
+		got := make([]string, 0)
+		for id, d := range store.All() {
+			if id != d.ID {
+				t.Errorf("All() yielded %s for document %s", id, d.ID)
+			}
+			got = append(got, id)
+		}
+		if want := []string{"a", "b", "c", "d", "e"}; !slices.Equal(got, want) {
+			t.Errorf("All() = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("find", func(t *testing.T) {
+		store := seeded(t)
+		find := func(where WherePredicate, projection Projection) []*conformanceDoc {
+			return slices.Collect(store.Find(where, projection))
+		}
+		if got, want := ids(find(func(q fs.Query) fs.Query { return q.Where("age", ">=", 36) }, All)), []string{"a", "c", "e"}; !slices.Equal(got, want) {
+			t.Errorf("Find(age >= 36) = %v, want %v", got, want)
+		}
+		if got, want := ids(find(func(q fs.Query) fs.Query { return q.Where("tags", "array-contains", "unix").OrderBy("name", fs.Desc) }, All)), []string{"d", "b"}; !slices.Equal(got, want) {
+			t.Errorf("Find(tags array-contains unix) = %v, want %v", got, want)
+		}
+		if got, want := ids(find(func(q fs.Query) fs.Query { return q.OrderBy("age", fs.Asc).Limit(3) }, All)), []string{"b", "d", "a"}; !slices.Equal(got, want) {
+			t.Errorf("Find(order by age limit 3) = %v, want %v", got, want)
+		}
+		if got, want := ids(find(nil, All)), []string{"a", "b", "c", "d", "e"}; !slices.Equal(got, want) {
+			t.Errorf("Find(nil) = %v, want %v", got, want)
+		}
+		got := find(func(q fs.Query) fs.Query { return q.Where(fs.DocumentID, "==", "c") }, NewProjection("id", "name"))
+		if len(got) != 1 || got[0].Name != "Claude" || got[0].Age != 0 || got[0].Tags != nil {
+			t.Errorf("Find() with projection = %+v, want only the id and name of c", got)
+		}
+	})
+
+	t.Run("query", func(t *testing.T) {
+		store := seeded(t)
+		for _, tt := range []struct {
+			name  string
+			build func(q Query[conformanceDoc]) Query[conformanceDoc]
+			want  []string
+		}{
+			{"equals", func(q Query[conformanceDoc]) Query[conformanceDoc] {
+				return q.Where("age", QueryOps.Equals, 28)
+			}, []string{"b", "d"}},
+			{"not equals", func(q Query[conformanceDoc]) Query[conformanceDoc] {
+				return q.Where("age", QueryOps.NotEquals, 28)
+			}, []string{"a", "c", "e"}},
+			{"in", func(q Query[conformanceDoc]) Query[conformanceDoc] {
+				return q.Where("name", QueryOps.In, []string{"Ada", "Edsger", "Grace"})
+			}, []string{"a", "e"}},
+			{"not in", func(q Query[conformanceDoc]) Query[conformanceDoc] {
+				return q.Where("age", QueryOps.NotIn, []int{28, 72})
+			}, []string{"a", "c"}},
+			{"array contains any", func(q Query[conformanceDoc]) Query[conformanceDoc] {
+				return q.Where("tags", QueryOps.ArrayContainsAny, []string{"c", "information"})
+			}, []string{"c", "d"}},
+			{"or", func(q Query[conformanceDoc]) Query[conformanceDoc] {
+				return q.Filter(Or(Condition("age", QueryOps.GreaterThan, 60), Condition("name", QueryOps.Equals, "Brian")))
+			}, []string{"b", "e"}},
+			{"order with ties", func(q Query[conformanceDoc]) Query[conformanceDoc] {
+				return q.OrderBy("age", fs.Desc)
+			}, []string{"e", "c", "a", "d", "b"}},
+			{"limit to last", func(q Query[conformanceDoc]) Query[conformanceDoc] {
+				return q.OrderBy("age", fs.Asc).LimitToLast(2)
+			}, []string{"c", "e"}},
+			{"cursors", func(q Query[conformanceDoc]) Query[conformanceDoc] {
+				return q.OrderBy("age", fs.Asc).StartAfter(28).EndAt(52)
+			}, []string{"a", "c"}},
+		} {
+			t.Run(tt.name, func(t *testing.T) {
+				if got := query(t, store, tt.build); !slices.Equal(got, tt.want) {
+					t.Errorf("Query() = %v, want %v", got, tt.want)
+				}
+			})
+		}
+	})
+
+	t.Run("aggregate", func(t *testing.T) {
+		store := seeded(t)
+		results, err := store.Aggregate(t.Context(), func(q Query[conformanceDoc]) Query[conformanceDoc] {
+			return q.Where("age", QueryOps.LessThan, 60)
+		}, CountOf("count"), SumOf("sum", "age"), AverageOf("average", "age"), AverageOf("none", "missing"))
+		if err != nil {
+			t.Fatalf("Aggregate() = %v", err)
+		}
+		count, _ := results.Int64("count")
+		sum, _ := results.Float64("sum")
+		average, _ := results.Float64("average")
+		if count != 4 || sum != 144 || average != 36 {
+			t.Errorf("Aggregate() = count %d, sum %v, average %v, want 4, 144 and 36", count, sum, average)
+		}
+		if _, err = results.Float64("none"); !errorx.IsOfType(err, errs.NotFoundError) {
+			t.Errorf("Float64() of an average of no numbers = %v, want a NotFoundError", err)
+		}
+
+		groups, err := GroupBy(t.Context(), store, nil, "age", []int{28, 36, 99}, CountOf("count"))
+		if err != nil {
+			t.Fatalf("GroupBy() = %v", err)
+		}
+		for age, want := range map[int]int64{28: 2, 36: 1, 99: 0} {
+			if got, err := groups[age].Int64("count"); err != nil || got != want {
+				t.Errorf("GroupBy() count of age %d = %d %v, want %d", age, got, err, want)
+			}
+		}
+	})
+
+	t.Run("page", func(t *testing.T) {
+		store := seeded(t)
+		build := func(q Query[conformanceDoc]) Query[conformanceDoc] {
+			return q.OrderBy("age", fs.Asc)
+		}
+		got := make([]*conformanceDoc, 0)
+		token := ""
+		for pages := 1; ; pages++ {
+			page, err := store.Page(t.Context(), build, 2, token)
+			if err != nil {
+				t.Fatalf("Page() = %v", err)
+			}
+			got = append(got, page.Items...)
+			if token = page.NextPageToken; token == "" {
+				if pages != 3 {
+					t.Errorf("Page() returned %d pages, want 3", pages)
+				}
+				break
+			}
+		}
+		if want := []string{"b", "d", "a", "c", "e"}; !slices.Equal(ids(got), want) {
+			t.Errorf("Page() = %v, want %v", ids(got), want)
+		}
+	})
+
+	t.Run("create", func(t *testing.T) {
+		store := newStore(t)
+		created, err := store.Create(t.Context(), conformanceDocs()[0])
+		if err != nil {
+			t.Fatalf("Create() = %v", err)
+		}
+		if created.UpdateTime.IsZero() {
+			t.Errorf("Create() has no UpdateTime")
+		}
+		if _, err = store.Create(t.Context(), conformanceDocs()[0]); !IsAlreadyExists(err) {
+			t.Errorf("Create() of an existing document = %v, want an AlreadyExists error", err)
+		}
+		got, err := store.Load("a")
+		if err != nil {
+			t.Fatalf("Load() = %v", err)
+		}
+		if got.CreatedAt.IsZero() || got.LastUpdatedAt.IsZero() {
+			t.Errorf("Load() after Create() has created_at %v and last_updated_at %v, want both", got.CreatedAt, got.LastUpdatedAt)
+		}
+	})
+
+	t.Run("update", func(t *testing.T) {
+		store := seeded(t)
+		current, err := store.LoadVersioned(t.Context(), "a")
+		if err != nil {
+			t.Fatalf("LoadVersioned() = %v", err)
+		}
+		current.Value.Age = 37
+		current.Value.Name = "Lovelace"
+		updated, err := store.Update(t.Context(), current, "age")
+		if err != nil {
+			t.Fatalf("Update() = %v", err)
+		}
+		if !updated.UpdateTime.After(current.UpdateTime) {
+			t.Errorf("Update() UpdateTime %v is not after %v", updated.UpdateTime, current.UpdateTime)
+		}
+		if got, _ := store.Load("a"); got.Age != 37 || got.Name != "Ada" {
+			t.Errorf("Load() after Update(age) = %+v, want only the age updated", got)
+		}
+		if _, err = store.Update(t.Context(), current, "age"); !IsConflict(err) {
+			t.Errorf("Update() of a stale version = %v, want a ConflictError", err)
+		}
+		if _, err = store.Update(t.Context(), &Versioned[conformanceDoc]{Value: &conformanceDoc{ID: "z"}}); !IsNotFound(err) {
+			t.Errorf("Update() of a missing document = %v, want a NotFound error", err)
+		}
+	})
+
+	t.Run("update without fields", func(t *testing.T) {
+		store := seeded(t)
+		current, err := store.LoadVersioned(t.Context(), "b")
+		if err != nil {
+			t.Fatalf("LoadVersioned() = %v", err)
+		}
+		before := time.Now()
+		current.Value.Age = 29
+		current.Value.Name = "Kernighan"
+		if _, err = store.Update(t.Context(), current); err != nil {
+			t.Fatalf("Update() = %v", err)
+		}
+		if got, _ := store.Load("b"); got.Age != 29 || got.Name != "Kernighan" {
+			t.Errorf("Load() after Update() = %+v, want every field updated", got)
+		}
+		// last_updated_at is written as a timestamp, a string would not compare with a time
+		got := query(t, store, func(q Query[conformanceDoc]) Query[conformanceDoc] {
+			return q.Where(LAST_UPDATED_AT, QueryOps.GreaterThanOrEquals, before)
+		})
+		if !slices.Equal(got, []string{"b"}) {
+			t.Errorf("Query() by last_updated_at after Update() = %v, want [b]", got)
+		}
+	})
+
+	t.Run("modify", func(t *testing.T) {
+		store := seeded(t)
+		modified, err := store.Modify(t.Context(), "b", 3, func(d *conformanceDoc) error {
+			d.Tags = append(d.Tags, "plan9")
+			return nil
+		}, "tags")
+		if err != nil {
+			t.Fatalf("Modify() = %v", err)
+		}
+		got, _ := store.Load("b")
+		if want := []string{"unix", "plan9"}; !slices.Equal(got.Tags, want) || !slices.Equal(modified.Value.Tags, want) {
+			t.Errorf("Modify() = %v, want tags %v", got.Tags, want)
+		}
+	})
+
+	t.Run("remove", func(t *testing.T) {
+		store := seeded(t)
+		if err := store.Remove("a"); err != nil {
+			t.Fatalf("Remove() = %v", err)
+		}
+		if _, err := store.Load("a"); !IsNotFound(err) {
+			t.Errorf("Load() after Remove() = %v, want a NotFound error", err)
+		}
+		if err := store.Remove("a"); err != nil {
+			t.Errorf("Remove() of a missing document = %v, want nil", err)
+		}
+		if err := store.BulkRemove(slices.Values([]string{"b", "c", "z"}), FAIL_ON_FIRST_ERROR); err != nil {
+			t.Fatalf("BulkRemove() = %v", err)
+		}
+		got := make([]string, 0)
+		for id := range store.All() {
+			got = append(got, id)
+		}
+		if want := []string{"d", "e"}; !slices.Equal(got, want) {
+			t.Errorf("All() after BulkRemove() = %v, want %v", got, want)
+		}
+	})
+	t.Run("in transaction", func(t *testing.T) {
+		store := newStore(t)
+		inTransaction := func(fn func(ts TransactionStore[conformanceDoc]) error) error {
+			return run(t.Context(), func(tx UnitOfWork) error {
+				return fn(store.InTransaction(tx))
+			})
+		}
+		load := func(id string) *conformanceDoc {
+			t.Helper()
+			d, err := store.Load(id)
+			if err != nil {
+				t.Fatalf("Load(%s) = %v", id, err)
+			}
+			return d
+		}
+
+		for _, d := range conformanceDocs()[:3] {
+			if err := inTransaction(func(ts TransactionStore[conformanceDoc]) error { return ts.Create(d) }); err != nil {
+				t.Fatalf("Create() = %v", err)
+			}
+		}
+		created := load("a")
+		if created.CreatedAt.IsZero() || !created.LastUpdatedAt.Equal(created.CreatedAt) {
+			t.Errorf("Create() stamped created_at %v and last_updated_at %v, want both set to the same time", created.CreatedAt, created.LastUpdatedAt)
+		}
+		if err := inTransaction(func(ts TransactionStore[conformanceDoc]) error { return ts.Create(conformanceDocs()[0]) }); err == nil {
+			t.Error("Create() of an existing document error = nil")
+		}
+
+		// every read comes before the first write, as Firestore requires
+		err := inTransaction(func(ts TransactionStore[conformanceDoc]) error {
+			a, err := ts.Load("a")
+			if err != nil {
+				return err
+			}
+			if exists, err := ts.Exists("x"); exists || err != nil {
+				t.Errorf("Exists() of a missing document = %v, %v, want false", exists, err)
+			}
+			all, err := ts.LoadAll("a", "x", "b")
+			if err != nil {
+				return err
+			}
+			if len(all) != 3 || all[0] == nil || all[1] != nil || all[2] == nil || all[2].Name != "Brian" {
+				t.Errorf("LoadAll() = %v, want a, nil and b", all)
+			}
+			older := make([]*conformanceDoc, 0)
+			for d, err := range ts.Query(func(q Query[conformanceDoc]) Query[conformanceDoc] {
+				return q.Where("age", QueryOps.GreaterThan, 30)
+			}) {
+				if err != nil {
+					return err
+				}
+				older = append(older, d)
+			}
+			if got := ids(older); !slices.Equal(got, []string{"a", "c"}) {
+				t.Errorf("Query() = %v, want [a c]", got)
+			}
+			a.Name = "Ada Lovelace"
+			return ts.Store(a)
+		})
+		if err != nil {
+			t.Fatalf("Store() = %v", err)
+		}
+		stored := load("a")
+		if stored.Name != "Ada Lovelace" || !stored.CreatedAt.Equal(created.CreatedAt) || !stored.LastUpdatedAt.After(created.LastUpdatedAt) {
+			t.Errorf("Store() = %+v, want the new name, created_at kept and last_updated_at advanced", stored)
+		}
+
+		if err = inTransaction(func(ts TransactionStore[conformanceDoc]) error {
+			return ts.Update(&conformanceDoc{ID: "a", Name: "ignored", Age: 37}, "age")
+		}); err != nil {
+			t.Fatalf("Update() = %v", err)
+		}
+		updated := load("a")
+		if updated.Age != 37 || updated.Name != "Ada Lovelace" || !updated.CreatedAt.Equal(created.CreatedAt) || !updated.LastUpdatedAt.After(stored.LastUpdatedAt) {
+			t.Errorf("Update() = %+v, want only age changed, created_at kept and last_updated_at advanced", updated)
+		}
+		if err = inTransaction(func(ts TransactionStore[conformanceDoc]) error {
+			return ts.Update(&conformanceDoc{ID: "x"}, "age")
+		}); err == nil {
+			t.Error("Update() of a missing document error = nil")
+		}
+
+		if err = inTransaction(func(ts TransactionStore[conformanceDoc]) error { return ts.Remove("b") }); err != nil {
+			t.Fatalf("Remove() = %v", err)
+		}
+		if _, err = store.Load("b"); !IsNotFound(err) {
+			t.Errorf("Load() after Remove() = %v, want a NotFound error", err)
+		}
+	})
+}
+
+// conformanceOptions are the sets of options the conformance tests run with, the behaviour of a
+// CollectionStore must not change with the metadata it keeps.
+var conformanceOptions = map[string][]CollectionStoreOption{
+	"plain":   nil,
+	"tracked": {WithSoftDelete(), WithAudit(), WithTemporalMetadata(nil)},
+}
+
+func TestMemoryCollectionStore(t *testing.T) {
+	for name, options := range conformanceOptions {
+		t.Run(name, func(t *testing.T) {
+			testCollectionStore(t, func(t *testing.T) CollectionStore[conformanceDoc] {
+				return NewMemoryCollectionStore[conformanceDoc]("people", conformanceKeyer, options...)
+			}, func(ctx context.Context, fn func(tx UnitOfWork) error) error {
+				return fn(unitOfWork{ctx: ctx})
+			})
+		})
+	}
+}
+
+// TestFirestoreCollectionStore runs the conformance tests against the Firestore emulator,
+// start it with "gcloud emulators firestore start" and set FIRESTORE_EMULATOR_HOST.
+func TestFirestoreCollectionStore(t *testing.T) {
+	if os.Getenv("FIRESTORE_EMULATOR_HOST") == "" {
+		t.Skip("FIRESTORE_EMULATOR_HOST is not set")
+	}
+	client, err := fs.NewClient(t.Context(), "ossgo-conformance")
+	if err != nil {
+		t.Fatalf("NewClient() = %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	for name, options := range conformanceOptions {
+		t.Run(name, func(t *testing.T) {
+			testCollectionStore(t, func(t *testing.T) CollectionStore[conformanceDoc] {
+				collection := fmt.Sprintf("people_%d", time.Now().UnixNano())
+				return NewCollectionStore[conformanceDoc](DEFAULT, collection, conformanceKeyer, append(options, WithClient(client))...)
+			}, func(ctx context.Context, fn func(tx UnitOfWork) error) error {
+				return client.RunTransaction(ctx, func(ctx context.Context, tx *fs.Transaction) error {
+					return fn(unitOfWork{ctx: ctx, client: client, tx: tx})
+				})
+			})
+		})
+	}
+}
+
+func TestMemoryCollectionStoreWatch(t *testing.T) {
+	store := NewMemoryCollectionStore[conformanceDoc]("people", conformanceKeyer)
+	store.Store(conformanceDocs()[0])
+	next, stop := iter.Pull2(store.Watch(t.Context(), func(q Query[conformanceDoc]) Query[conformanceDoc] {
+		return q.Where("age", QueryOps.GreaterThan, 30)
+	}))
+	defer stop()
+
+	expect := func(kind ChangeKind, id string) {
+		t.Helper()
+		change, err, ok := next()
+		if !ok || err != nil || change.Kind != kind || change.ID != id {
+			t.Fatalf("Watch() = %v %s %v, want %v %s", change.Kind, change.ID, err, kind, id)
+		}
+	}
+	expect(ChangeKinds.Added, "a")
+	store.Store(conformanceDocs()[2])
+	expect(ChangeKinds.Added, "c")
+	store.Modify(t.Context(), "a", 1, func(d *conformanceDoc) error { d.Age = 20; return nil }, "age")
+	expect(ChangeKinds.Removed, "a")
+	store.Remove("c")
+	expect(ChangeKinds.Removed, "c")
+}
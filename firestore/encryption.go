@@ -0,0 +1,383 @@
+package firestore
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"maps"
+	"reflect"
+	"slices"
+	"sync"
+	"time"
+
+	fs "cloud.google.com/go/firestore"
+	"github.com/rs/zerolog/log"
+
+	"github.com/jarrodhroberson/ossgo/crypt"
+	errs "github.com/jarrodhroberson/ossgo/errors"
+	"github.com/jarrodhroberson/ossgo/functions/must"
+	"github.com/jarrodhroberson/ossgo/secrets"
+	"github.com/jarrodhroberson/ossgo/structs"
+)
+
+// OSSGO_TAG is the struct tag for the options of this package. A field tagged structs.Encrypt is stored
+// encrypted with crypt.Encrypt, adding structs.Deterministic encrypts equal values to equal ciphertexts
+// so the field can be queried for equality with EncryptedEquals. Only top level fields can be encrypted,
+// the field is stored as a map of its ciphertext and the version of the key it was encrypted with.
+//
+//	type Patient struct {
+//		ID        string   `json:"id"`
+//		Name      string   `json:"name" ossgo:"encrypt"`
+//		Email     string   `json:"email" ossgo:"encrypt,deterministic"`
+//		Allergies []string `json:"allergies" ossgo:"encrypt"`
+//	}
+const OSSGO_TAG = "ossgo"
+
+// CIPHERTEXT_FIELD and KEY_VERSION_FIELD are the fields of the map an encrypted field is stored as.
+const CIPHERTEXT_FIELD = "ciphertext"
+const KEY_VERSION_FIELD = "key_version"
+
+// KeyRing resolves the keys encrypted fields are encrypted with by version, so the key can be rotated and
+// values encrypted with an earlier version can still be decrypted.
+type KeyRing interface {
+	// Current returns the version and the key new values are encrypted with.
+	Current(ctx context.Context) (int, []byte, error)
+	// Key returns the key of version, to decrypt the values that were encrypted with it.
+	Key(ctx context.Context, version int) ([]byte, error)
+}
+
+// staticKeyRing is a KeyRing of fixed keys, the current key is the highest version.
+type staticKeyRing map[int][]byte
+
+// StaticKeyRing returns a KeyRing of keys by version, the highest version is the current key.
+// Use it for tests and local development, use SecretKeyRing in production.
+func StaticKeyRing(keys map[int][]byte) KeyRing {
+	return staticKeyRing(maps.Clone(keys))
+}
+
+func (kr staticKeyRing) Current(ctx context.Context) (int, []byte, error) {
+	if len(kr) == 0 {
+		return 0, nil, EncryptionError.New("key ring has no keys")
+	}
+	version := slices.Max(slices.Collect(maps.Keys(kr)))
+	return version, kr[version], nil
+}
+
+func (kr staticKeyRing) Key(ctx context.Context, version int) ([]byte, error) {
+	key, ok := kr[version]
+	if !ok {
+		return nil, EncryptionError.New("key ring has no key version %d", version)
+	}
+	return key, nil
+}
+
+// secretKeyRing is a KeyRing of the versions of a Secret Manager secret, keys are cached once they are read.
+type secretKeyRing struct {
+	name    string
+	refresh time.Duration
+	mu      sync.Mutex
+	keys    map[int][]byte
+	current int
+	checked time.Time
+}
+
+// SecretKeyRing returns a KeyRing of the versions of the secret name, the payload of a version is its key.
+// The current key is the latest version of the secret, it is looked up again after refresh so a key rotated by
+// adding a version is used without a restart. If the lookup fails the current key stays in use.
+//
+// Example usage:
+//
+//	store := firestore.NewCollectionStore[Patient](firestore.DEFAULT, "patients", keyer,
+//		firestore.WithEncryption(firestore.SecretKeyRing("patients-pii-key", 10*time.Minute)))
+func SecretKeyRing(name string, refresh time.Duration) KeyRing {
+	return &secretKeyRing{name: name, refresh: refresh, keys: make(map[int][]byte)}
+}
+
+func (kr *secretKeyRing) Current(ctx context.Context) (int, []byte, error) {
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+	if kr.current == 0 || time.Since(kr.checked) > kr.refresh {
+		path, key, err := secrets.GetSecretVersionValue(ctx, kr.name, 0)
+		if err != nil {
+			if kr.current == 0 {
+				return 0, nil, EncryptionError.Wrap(err, "could not read the latest version of secret %s", kr.name)
+			}
+			log.Warn().Err(err).Msgf("could not refresh the latest version of secret %s, still using version %d", kr.name, kr.current)
+		} else {
+			kr.keys[path.Version] = key
+			kr.current = path.Version
+		}
+		kr.checked = time.Now()
+	}
+	return kr.current, kr.keys[kr.current], nil
+}
+
+func (kr *secretKeyRing) Key(ctx context.Context, version int) ([]byte, error) {
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+	if key, ok := kr.keys[version]; ok {
+		return key, nil
+	}
+	_, key, err := secrets.GetSecretVersionValue(ctx, kr.name, version)
+	if err != nil {
+		return nil, EncryptionError.Wrap(err, "could not read version %d of secret %s", version, kr.name)
+	}
+	kr.keys[version] = key
+	return key, nil
+}
+
+// WithEncryption encrypts the fields of T tagged with OSSGO_TAG using the keys of keys.
+// A store of a T with encrypted fields and without a KeyRing fails to write, so they are never stored in plain text.
+// Documents are decrypted on every read, decoded through their json tags like DocSnapShotToType.
+// Plain text values, written before a field was encrypted, are read as is and encrypted on the next write.
+func WithEncryption(keys KeyRing) CollectionStoreOption {
+	return func(o *collectionStoreOptions) {
+		o.keys = keys
+	}
+}
+
+type documentOptions struct {
+	keys KeyRing
+}
+
+// DocumentOption configures how OnDocument and TypedMigration decode documents outside a CollectionStore.
+type DocumentOption func(o *documentOptions)
+
+// WithKeyRing decrypts the fields of T tagged with OSSGO_TAG with the keys of keys, like WithEncryption does
+// for a CollectionStore. Without it a T with encrypted fields can not be decoded from a document that has them.
+func WithKeyRing(keys KeyRing) DocumentOption {
+	return func(o *documentOptions) {
+		o.keys = keys
+	}
+}
+
+// newDocumentOptions applies options to the defaults.
+func newDocumentOptions(options []DocumentOption) documentOptions {
+	o := documentOptions{}
+	for _, option := range options {
+		option(&o)
+	}
+	return o
+}
+
+// fieldEncryption encrypts and decrypts the fields of the documents of a store, fields is the json name of
+// every encrypted field and whether it is deterministic.
+type fieldEncryption struct {
+	keys   KeyRing
+	fields map[string]bool
+}
+
+// newFieldEncryption finds the encrypted fields of T.
+func newFieldEncryption[T any](keys KeyRing) fieldEncryption {
+	fe := fieldEncryption{keys: keys}
+	rt := reflect.TypeFor[T]()
+	for rt.Kind() == reflect.Pointer {
+		rt = rt.Elem()
+	}
+	if rt.Kind() != reflect.Struct {
+		return fe
+	}
+	tags := structs.Tags(reflect.New(rt).Interface())
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		tag, ok := structs.FindTag(tags[field.Name], OSSGO_TAG)
+		if !field.IsExported() || !ok || !tag.Has(structs.Encrypt) {
+			continue
+		}
+		name := field.Name
+		if jsonTag, ok := structs.FindTag(tags[field.Name], "json"); ok && len(jsonTag.Values) > 0 && jsonTag.Values[0] != "" {
+			if jsonTag.Values[0] == "-" {
+				continue
+			}
+			name = jsonTag.Values[0]
+		}
+		if fe.fields == nil {
+			fe.fields = make(map[string]bool)
+		}
+		fe.fields[name] = tag.Has(structs.Deterministic)
+	}
+	return fe
+}
+
+// canonicalJSON marshals v the way it is marshalled as a field of a document by must.MarshallMap,
+// so a value encrypts to the same ciphertext whether it comes from a document or a query.
+func canonicalJSON(v any) ([]byte, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, errs.MarshalError.Wrap(err, "could not marshal %T", v)
+	}
+	var generic any
+	if err = json.Unmarshal(b, &generic); err != nil {
+		return nil, errs.UnMarshalError.Wrap(err, "could not unmarshal %s", b)
+	}
+	return json.Marshal(generic)
+}
+
+// encryptValue encrypts the JSON of v with key into the map it is stored as.
+func encryptValue(v any, version int, key []byte, deterministic bool) (map[string]any, error) {
+	plaintext, err := canonicalJSON(v)
+	if err != nil {
+		return nil, err
+	}
+	encrypt := crypt.Encrypt
+	if deterministic {
+		encrypt = crypt.EncryptDeterministic
+	}
+	ciphertext, err := encrypt(plaintext, key)
+	if err != nil {
+		return nil, EncryptionError.Wrap(err, "could not encrypt with key version %d", version)
+	}
+	return map[string]any{CIPHERTEXT_FIELD: ciphertext, KEY_VERSION_FIELD: version}, nil
+}
+
+// encryptedValueOf returns the ciphertext and key version of a stored encrypted field,
+// ok is false when v is not an encrypted value.
+func encryptedValueOf(v any) (ciphertext []byte, version int, ok bool) {
+	m, isMap := v.(map[string]any)
+	if !isMap || len(m) != 2 {
+		return nil, 0, false
+	}
+	switch ct := m[CIPHERTEXT_FIELD].(type) {
+	case []byte:
+		ciphertext = ct
+	case string:
+		b, err := base64.StdEncoding.DecodeString(ct)
+		if err != nil {
+			return nil, 0, false
+		}
+		ciphertext = b
+	default:
+		return nil, 0, false
+	}
+	switch kv := m[KEY_VERSION_FIELD].(type) {
+	case int:
+		version = kv
+	case int64:
+		version = int(kv)
+	case float64:
+		version = int(kv)
+	default:
+		return nil, 0, false
+	}
+	return ciphertext, version, true
+}
+
+// encrypt replaces the encrypted fields in m with their encrypted value.
+func (fe fieldEncryption) encrypt(ctx context.Context, m map[string]any) error {
+	if len(fe.fields) == 0 {
+		return nil
+	}
+	if fe.keys == nil {
+		return EncryptionError.New("fields %v are encrypted but the store has no KeyRing, use WithEncryption", slices.Sorted(maps.Keys(fe.fields)))
+	}
+	version, key, err := fe.keys.Current(ctx)
+	if err != nil {
+		return err
+	}
+	for field, deterministic := range fe.fields {
+		v, ok := m[field]
+		if !ok {
+			continue
+		}
+		if m[field], err = encryptValue(v, version, key, deterministic); err != nil {
+			return EncryptionError.Wrap(err, "could not encrypt field %s", field)
+		}
+	}
+	return nil
+}
+
+// decrypt replaces the encrypted values in m with the values they decrypt to.
+func (fe fieldEncryption) decrypt(ctx context.Context, m map[string]any) error {
+	for field := range fe.fields {
+		ciphertext, version, ok := encryptedValueOf(m[field])
+		if !ok {
+			continue
+		}
+		if fe.keys == nil {
+			return EncryptionError.New("field %s is encrypted but the store has no KeyRing, use WithEncryption", field)
+		}
+		key, err := fe.keys.Key(ctx, version)
+		if err != nil {
+			return err
+		}
+		plaintext, err := crypt.Decrypt(ciphertext, key)
+		if err != nil {
+			return EncryptionError.Wrap(err, "could not decrypt field %s with key version %d", field, version)
+		}
+		var v any
+		if err = json.Unmarshal(plaintext, &v); err != nil {
+			return errs.UnMarshalError.Wrap(err, "could not unmarshal decrypted field %s", field)
+		}
+		m[field] = v
+	}
+	return nil
+}
+
+// stale returns the encrypted fields of the stored fields m that are not encrypted with the current key,
+// plain text values and values encrypted with an earlier key version. Without a KeyRing there are none.
+func (fe fieldEncryption) stale(ctx context.Context, m map[string]any) ([]string, error) {
+	present := slices.DeleteFunc(slices.Sorted(maps.Keys(fe.fields)), func(field string) bool {
+		_, ok := m[field]
+		return !ok
+	})
+	if len(present) == 0 || fe.keys == nil {
+		return nil, nil
+	}
+	current, _, err := fe.keys.Current(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return slices.DeleteFunc(present, func(field string) bool {
+		_, version, encrypted := encryptedValueOf(m[field])
+		return encrypted && version == current
+	}), nil
+}
+
+// encodeDocument marshals v into the fields of its document with the encrypted fields encrypted.
+func encodeDocument[T any](ctx context.Context, fe fieldEncryption, v *T) (map[string]any, error) {
+	m := must.MarshallMap(v)
+	if err := fe.encrypt(ctx, m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// documentDecoder returns the function that decodes documents into a T, DocSnapShotToType when T has no
+// encrypted fields.
+func documentDecoder[T any](ctx context.Context, fe fieldEncryption) func(dss *fs.DocumentSnapshot) (*T, error) {
+	if len(fe.fields) == 0 {
+		return DocSnapShotToType[T]
+	}
+	return func(dss *fs.DocumentSnapshot) (*T, error) {
+		m := make(map[string]interface{})
+		if err := dss.DataTo(&m); err != nil {
+			return nil, errs.MarshalError.Wrap(err, "error unmarshalling Firestore document with ID %s", dss.Ref.ID)
+		}
+		if err := fe.decrypt(ctx, m); err != nil {
+			return nil, EncryptionError.Wrap(err, "could not decrypt document with ID %s", dss.Ref.ID)
+		}
+		return decodeFields[T](m)
+	}
+}
+
+// EncryptedEquals is a Condition that the deterministically encrypted field equals value.
+// The value is encrypted with the current key of keys, so documents that were encrypted with an earlier
+// key version do not match. Re-encrypt them after a key rotation with a TypedMigration that has WithKeyRing
+// and always returns true, it rewrites every encrypted field that is not encrypted with the current key.
+//
+// Example usage:
+//
+//	patients := store.Query(ctx, func(q firestore.Query[Patient]) firestore.Query[Patient] {
+//		return q.Filter(firestore.EncryptedEquals(ctx, keys, "email", email))
+//	})
+func EncryptedEquals(ctx context.Context, keys KeyRing, field string, value any) Filter {
+	version, key, err := keys.Current(ctx)
+	if err != nil {
+		return Filter{err: err}
+	}
+	ev, err := encryptValue(value, version, key, true)
+	if err != nil {
+		return Filter{err: err}
+	}
+	return Condition(field+"."+CIPHERTEXT_FIELD, QueryOps.Equals, ev[CIPHERTEXT_FIELD])
+}
@@ -0,0 +1,106 @@
+package firestore
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"testing"
+
+	fs "cloud.google.com/go/firestore"
+)
+
+type patient struct {
+	ID        string   `json:"id"`
+	Name      string   `json:"name" ossgo:"encrypt"`
+	Email     string   `json:"email" ossgo:"encrypt,deterministic"`
+	Allergies []string `json:"allergies" ossgo:"encrypt"`
+	Secret    string   `json:"-" ossgo:"encrypt"`
+	Ward      int      `json:"ward"`
+}
+
+func TestNewFieldEncryption(t *testing.T) {
+	got := newFieldEncryption[patient](nil).fields
+	want := map[string]bool{"name": false, "email": true, "allergies": false}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("newFieldEncryption() fields = %v, want %v", got, want)
+	}
+	if fields := newFieldEncryption[conformanceDoc](nil).fields; fields != nil {
+		t.Errorf("newFieldEncryption() of a type without encrypted fields = %v, want nil", fields)
+	}
+}
+
+func TestFieldEncryptionRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	fe := newFieldEncryption[patient](StaticKeyRing(map[int][]byte{1: []byte("key one")}))
+	m, err := encodeDocument(ctx, fe, &patient{ID: "p1", Name: "Ada", Email: "ada@example.com", Allergies: []string{"penicillin"}, Ward: 3})
+	if err != nil {
+		t.Fatalf("encodeDocument() = %v", err)
+	}
+	if m["id"] != "p1" || m["ward"] != float64(3) {
+		t.Errorf("encodeDocument() encrypted fields that are not tagged: %v", m)
+	}
+	for _, field := range []string{"name", "email", "allergies"} {
+		ciphertext, version, ok := encryptedValueOf(m[field])
+		if !ok || version != 1 || bytes.Contains(ciphertext, []byte("Ada")) {
+			t.Errorf("encodeDocument() field %s = %v, want a ciphertext with key version 1", field, m[field])
+		}
+	}
+
+	// after a rotation values are encrypted with the new key and the old values still decrypt
+	fe.keys = StaticKeyRing(map[int][]byte{1: []byte("key one"), 2: []byte("key two")})
+	if err = fe.decrypt(ctx, m); err != nil {
+		t.Fatalf("decrypt() = %v", err)
+	}
+	got, err := decodeFields[patient](m)
+	if err != nil {
+		t.Fatalf("decodeFields() = %v", err)
+	}
+	want := patient{ID: "p1", Name: "Ada", Email: "ada@example.com", Allergies: []string{"penicillin"}, Ward: 3}
+	if !reflect.DeepEqual(*got, want) {
+		t.Errorf("decrypt() = %+v, want %+v", *got, want)
+	}
+	if err = fe.encrypt(ctx, m); err != nil {
+		t.Fatalf("encrypt() = %v", err)
+	}
+	if _, version, _ := encryptedValueOf(m["name"]); version != 2 {
+		t.Errorf("encrypt() after rotation used key version %d, want 2", version)
+	}
+}
+
+func TestFieldEncryptionWithoutKeyRing(t *testing.T) {
+	fe := newFieldEncryption[patient](nil)
+	if _, err := encodeDocument(context.Background(), fe, &patient{Name: "Ada"}); err == nil {
+		t.Errorf("encodeDocument() without a KeyRing succeeded, want an EncryptionError")
+	}
+	m := map[string]any{"name": "Ada"}
+	if err := fe.decrypt(context.Background(), m); err != nil || m["name"] != "Ada" {
+		t.Errorf("decrypt() of a plain text value = %v %v, want it unchanged", m, err)
+	}
+}
+
+func TestEncryptedEquals(t *testing.T) {
+	ctx := context.Background()
+	keys := StaticKeyRing(map[int][]byte{7: []byte("key seven")})
+	fe := newFieldEncryption[patient](keys)
+	m, err := encodeDocument(ctx, fe, &patient{Email: "ada@example.com", Name: "Ada"})
+	if err != nil {
+		t.Fatalf("encodeDocument() = %v", err)
+	}
+	again, _ := encodeDocument(ctx, fe, &patient{Email: "ada@example.com", Name: "Ada"})
+	if reflect.DeepEqual(m["name"], again["name"]) {
+		t.Errorf("encodeDocument() encrypted a randomized field to the same ciphertext twice")
+	}
+
+	f := EncryptedEquals(ctx, keys, "email", "ada@example.com")
+	if f.err != nil {
+		t.Fatalf("EncryptedEquals() = %v", f.err)
+	}
+	pf := f.entity.(fs.PropertyFilter)
+	stored, _, _ := encryptedValueOf(m["email"])
+	if pf.Path != "email.ciphertext" || pf.Operator != "==" || !bytes.Equal(pf.Value.([]byte), stored) {
+		t.Errorf("EncryptedEquals() = %+v, want email.ciphertext == %x", pf, stored)
+	}
+	if f = EncryptedEquals(ctx, StaticKeyRing(nil), "email", "x"); f.err == nil {
+		t.Errorf("EncryptedEquals() with an empty key ring succeeded, want an error")
+	}
+}
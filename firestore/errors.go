@@ -2,6 +2,21 @@ package firestore
 
 import (
 	"github.com/joomcode/errorx"
+
+	errs "github.com/jarrodhroberson/ossgo/errors"
 )
 
 var BulkWriterError = errorx.IllegalState.NewSubtype("Bulk Writer Error")
+
+// InvalidPageTokenError is returned when a page token is malformed, has been tampered with,
+// or was issued for a different query.
+var InvalidPageTokenError = errorx.IllegalArgument.NewSubtype("Invalid Page Token")
+
+// UnsupportedEventError is returned for a CloudEvent that is not a Firestore document event or can not be decoded.
+var UnsupportedEventError = errs.InvalidData.NewSubtype("Unsupported Event")
+
+// MigrationError is returned when a migration is invalid or fails to migrate a document.
+var MigrationError = errorx.IllegalState.NewSubtype("Migration Error")
+
+// EncryptionError is returned when an encrypted field can not be encrypted or decrypted, or its key can not be read.
+var EncryptionError = errorx.IllegalState.NewSubtype("Encryption Error")
@@ -0,0 +1,413 @@
+package firestore
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	fs "cloud.google.com/go/firestore"
+	"google.golang.org/api/iterator"
+	"google.golang.org/genproto/googleapis/type/latlng"
+
+	errs "github.com/jarrodhroberson/ossgo/errors"
+)
+
+// exportRecord is a line of an export, a document with its path relative to the database and its fields
+// encoded as Firestore REST API Value objects, the JSON form of the Value protobuf, e.g.
+//
+//	{"path":"users/u1","fields":{"name":{"stringValue":"Ann"},"tags":{"arrayValue":{"values":[{"stringValue":"a"}]}}}}
+//
+// The one difference is referenceValue, it holds the document path relative to the database instead of the
+// full resource name so an export can be imported into another database. Full names are accepted on import.
+// Vectors are maps with a "__type__" of "__vector__" and a "value" array of doubles, as Firestore stores them.
+type exportRecord struct {
+	Path   string                     `json:"path"`
+	Fields map[string]json.RawMessage `json:"fields"`
+}
+
+// relativePath returns the path of a document relative to its database, without the
+// "projects/{project}/databases/{database}/documents/" prefix.
+func relativePath(path string) string {
+	if _, rel, ok := strings.Cut(path, "/documents/"); ok {
+		return rel
+	}
+	return path
+}
+
+// The keys of the map Firestore stores a vector as.
+const (
+	vectorTypeKey   = "__type__"
+	vectorTypeValue = "__vector__"
+	vectorValueKey  = "value"
+)
+
+// encodeFloat encodes the values JSON has no number for as strings, the same way protojson does.
+func encodeFloat(f float64) any {
+	switch {
+	case math.IsNaN(f):
+		return "NaN"
+	case math.IsInf(f, 1):
+		return "Infinity"
+	case math.IsInf(f, -1):
+		return "-Infinity"
+	default:
+		return f
+	}
+}
+
+// encodeTypedValue encodes a value of DocumentSnapshot.Data into its typed form.
+func encodeTypedValue(v any) (map[string]any, error) {
+	switch vt := v.(type) {
+	case nil:
+		return map[string]any{"nullValue": "NULL_VALUE"}, nil
+	case bool:
+		return map[string]any{"booleanValue": vt}, nil
+	case int64:
+		return map[string]any{"integerValue": strconv.FormatInt(vt, 10)}, nil
+	case float64:
+		return map[string]any{"doubleValue": encodeFloat(vt)}, nil
+	case time.Time:
+		return map[string]any{"timestampValue": vt.UTC().Format(time.RFC3339Nano)}, nil
+	case string:
+		return map[string]any{"stringValue": vt}, nil
+	case []byte:
+		return map[string]any{"bytesValue": base64.StdEncoding.EncodeToString(vt)}, nil
+	case *fs.DocumentRef:
+		return map[string]any{"referenceValue": relativePath(vt.Path)}, nil
+	case *latlng.LatLng:
+		return map[string]any{"geoPointValue": map[string]any{"latitude": vt.GetLatitude(), "longitude": vt.GetLongitude()}}, nil
+	case fs.Vector64:
+		values := make([]any, 0, len(vt))
+		for _, f := range vt {
+			values = append(values, map[string]any{"doubleValue": encodeFloat(f)})
+		}
+		return map[string]any{"mapValue": map[string]any{"fields": map[string]any{
+			vectorTypeKey:  map[string]any{"stringValue": vectorTypeValue},
+			vectorValueKey: map[string]any{"arrayValue": map[string]any{"values": values}},
+		}}}, nil
+	case []any:
+		values := make([]map[string]any, 0, len(vt))
+		for _, av := range vt {
+			ev, err := encodeTypedValue(av)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, ev)
+		}
+		return map[string]any{"arrayValue": map[string]any{"values": values}}, nil
+	case map[string]any:
+		fields, err := encodeTypedFields(vt)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{"mapValue": map[string]any{"fields": fields}}, nil
+	default:
+		return nil, errs.MarshalError.New("unsupported Firestore value type %T", v)
+	}
+}
+
+func encodeTypedFields(data map[string]any) (map[string]any, error) {
+	fields := make(map[string]any, len(data))
+	for k, v := range data {
+		ev, err := encodeTypedValue(v)
+		if err != nil {
+			return nil, errs.MarshalError.Wrap(err, "could not encode field %s", k)
+		}
+		fields[k] = ev
+	}
+	return fields, nil
+}
+
+func decodeFloat(raw json.RawMessage) (float64, error) {
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		switch s {
+		case "NaN":
+			return math.NaN(), nil
+		case "Infinity":
+			return math.Inf(1), nil
+		case "-Infinity":
+			return math.Inf(-1), nil
+		default:
+			return strconv.ParseFloat(s, 64)
+		}
+	}
+	var f float64
+	err := json.Unmarshal(raw, &f)
+	return f, err
+}
+
+// decodeTypedValue decodes a typed value into the value it was exported from, doc creates the
+// DocumentRef of a referenceValue from its relative path.
+func decodeTypedValue(raw json.RawMessage, doc func(path string) *fs.DocumentRef) (any, error) {
+	var typed map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &typed); err != nil {
+		return nil, errs.UnMarshalError.Wrap(err, "%s is not a typed value", raw)
+	}
+	if len(typed) != 1 {
+		return nil, errs.UnMarshalError.New("%s must have exactly one type", raw)
+	}
+	for kind, value := range typed {
+		switch kind {
+		case "nullValue":
+			return nil, nil
+		case "booleanValue":
+			var b bool
+			err := json.Unmarshal(value, &b)
+			return b, err
+		case "integerValue":
+			var s string
+			if err := json.Unmarshal(value, &s); err != nil {
+				return nil, err
+			}
+			return strconv.ParseInt(s, 10, 64)
+		case "doubleValue":
+			return decodeFloat(value)
+		case "timestampValue":
+			var t time.Time
+			err := json.Unmarshal(value, &t)
+			return t, err
+		case "stringValue":
+			var s string
+			err := json.Unmarshal(value, &s)
+			return s, err
+		case "bytesValue":
+			var b []byte
+			err := json.Unmarshal(value, &b)
+			return b, err
+		case "referenceValue":
+			var path string
+			if err := json.Unmarshal(value, &path); err != nil {
+				return nil, err
+			}
+			return doc(relativePath(path)), nil
+		case "geoPointValue":
+			var gp struct {
+				Latitude  float64 `json:"latitude"`
+				Longitude float64 `json:"longitude"`
+			}
+			err := json.Unmarshal(value, &gp)
+			return &latlng.LatLng{Latitude: gp.Latitude, Longitude: gp.Longitude}, err
+		case "arrayValue":
+			var av struct {
+				Values []json.RawMessage `json:"values"`
+			}
+			if err := json.Unmarshal(value, &av); err != nil {
+				return nil, err
+			}
+			array := make([]any, 0, len(av.Values))
+			for _, v := range av.Values {
+				dv, err := decodeTypedValue(v, doc)
+				if err != nil {
+					return nil, err
+				}
+				array = append(array, dv)
+			}
+			return array, nil
+		case "mapValue":
+			var mv struct {
+				Fields map[string]json.RawMessage `json:"fields"`
+			}
+			if err := json.Unmarshal(value, &mv); err != nil {
+				return nil, err
+			}
+			fields, err := decodeTypedFields(mv.Fields, doc)
+			if err != nil {
+				return nil, err
+			}
+			return decodeVector(fields), nil
+		default:
+			return nil, errs.UnMarshalError.New("unknown value type %s", kind)
+		}
+	}
+	return nil, nil
+}
+
+// decodeVector returns the Vector64 a decoded map stands for, or the map if it is not a vector.
+func decodeVector(fields map[string]any) any {
+	if fields[vectorTypeKey] != vectorTypeValue || len(fields) != 2 {
+		return fields
+	}
+	values, ok := fields[vectorValueKey].([]any)
+	if !ok {
+		return fields
+	}
+	vector := make(fs.Vector64, 0, len(values))
+	for _, v := range values {
+		f, ok := v.(float64)
+		if !ok {
+			return fields
+		}
+		vector = append(vector, f)
+	}
+	return vector
+}
+
+func decodeTypedFields(fields map[string]json.RawMessage, doc func(path string) *fs.DocumentRef) (map[string]any, error) {
+	data := make(map[string]any, len(fields))
+	for k, raw := range fields {
+		v, err := decodeTypedValue(raw, doc)
+		if err != nil {
+			return nil, errs.UnMarshalError.Wrap(err, "could not decode field %s", k)
+		}
+		data[k] = v
+	}
+	return data, nil
+}
+
+// exporter writes the documents of collections and their subcollections, depth first.
+type exporter struct {
+	client *fs.Client
+	enc    *json.Encoder
+	count  int
+}
+
+// exportCollection writes every document of the collection, a document that does not exist but has
+// subcollections is not written but its subcollections are.
+func (e *exporter) exportCollection(ctx context.Context, collection *fs.CollectionRef) error {
+	refs := collection.DocumentRefs(ctx)
+	batch := make([]*fs.DocumentRef, 0, MAX_BULK_WRITE_SIZE)
+	for {
+		ref, err := refs.Next()
+		if errors.Is(err, iterator.Done) {
+			break
+		}
+		if err != nil {
+			return errs.NotReadError.Wrap(err, "could not list the documents of %s", collection.Path)
+		}
+		batch = append(batch, ref)
+		if len(batch) == MAX_BULK_WRITE_SIZE {
+			if err = e.exportDocuments(ctx, batch); err != nil {
+				return err
+			}
+			batch = batch[:0]
+		}
+	}
+	return e.exportDocuments(ctx, batch)
+}
+
+func (e *exporter) exportDocuments(ctx context.Context, refs []*fs.DocumentRef) error {
+	if len(refs) == 0 {
+		return nil
+	}
+	docs, err := e.client.GetAll(ctx, refs)
+	if err != nil {
+		return errs.NotReadError.Wrap(err, "could not read %d documents", len(refs))
+	}
+	for _, dss := range docs {
+		if dss.Exists() {
+			fields, err := encodeTypedFields(dss.Data())
+			if err != nil {
+				return errs.MarshalError.Wrap(err, "could not export document %s", dss.Ref.Path)
+			}
+			if err = e.enc.Encode(map[string]any{"path": relativePath(dss.Ref.Path), "fields": fields}); err != nil {
+				return errs.NotWrittenError.Wrap(err, "could not write document %s", dss.Ref.Path)
+			}
+			e.count++
+		}
+		collections, err := dss.Ref.Collections(ctx).GetAll()
+		if err != nil {
+			return errs.NotReadError.Wrap(err, "could not list the subcollections of %s", dss.Ref.Path)
+		}
+		for _, collection := range collections {
+			if err = e.exportCollection(ctx, collection); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Export writes the documents of the collections, and of all their subcollections, to w as
+// newline delimited JSON, one document per line with its path relative to the database and its fields
+// with their Firestore types. Every root collection is exported when no collection paths are given.
+// It returns the number of documents written, Import reads the export back.
+//
+// Example usage:
+//
+//	f, _ := os.Create("users.ndjson")
+//	defer f.Close()
+//	count, err := firestore.Export(ctx, client, f, "users")
+func Export(ctx context.Context, client *fs.Client, w io.Writer, collectionPaths ...string) (int, error) {
+	collections := make([]*fs.CollectionRef, 0, len(collectionPaths))
+	for _, path := range collectionPaths {
+		collections = append(collections, client.Collection(path))
+	}
+	if len(collectionPaths) == 0 {
+		var err error
+		if collections, err = client.Collections(ctx).GetAll(); err != nil {
+			return 0, errs.NotReadError.Wrap(err, "could not list the root collections")
+		}
+	}
+
+	bw := bufio.NewWriter(w)
+	e := &exporter{client: client, enc: json.NewEncoder(bw)}
+	for _, collection := range collections {
+		if err := e.exportCollection(ctx, collection); err != nil {
+			return e.count, err
+		}
+	}
+	if err := bw.Flush(); err != nil {
+		return e.count, errs.NotWrittenError.Wrap(err, "could not flush export")
+	}
+	return e.count, nil
+}
+
+// Import reads an export written by Export from r and sets every document in it with a BulkWriter,
+// existing documents are overwritten. References are resolved against the database of client, so an
+// export can be imported into a different database. It returns the number of documents written.
+func Import(ctx context.Context, client *fs.Client, r io.Reader) (int, error) {
+	bw := client.BulkWriter(ctx)
+	defer bw.End()
+
+	dec := json.NewDecoder(bufio.NewReader(r))
+	jobs := make([]*fs.BulkWriterJob, 0, MAX_BULK_WRITE_SIZE)
+	paths := make([]string, 0, MAX_BULK_WRITE_SIZE)
+	count := 0
+	flush := func() error {
+		bw.Flush()
+		for i, job := range jobs {
+			if _, err := job.Results(); err != nil {
+				return BulkWriterError.Wrap(err, "error importing document %s", paths[i])
+			}
+			count++
+		}
+		jobs, paths = jobs[:0], paths[:0]
+		return nil
+	}
+
+	for line := 1; ; line++ {
+		var record exportRecord
+		if err := dec.Decode(&record); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return count, errs.UnMarshalError.Wrap(err, "could not decode record %d", line)
+		}
+		data, err := decodeTypedFields(record.Fields, client.Doc)
+		if err != nil {
+			return count, errs.UnMarshalError.Wrap(err, "could not decode document %s", record.Path)
+		}
+		doc := client.Doc(record.Path)
+		if doc == nil {
+			return count, errs.InvalidData.New("record %d path %q is not a document path", line, record.Path)
+		}
+		job, err := bw.Set(doc, data)
+		if err != nil {
+			return count, BulkWriterError.Wrap(err, "error importing document %s", record.Path)
+		}
+		jobs, paths = append(jobs, job), append(paths, record.Path)
+		if len(jobs) == MAX_BULK_WRITE_SIZE {
+			if err = flush(); err != nil {
+				return count, err
+			}
+		}
+	}
+	return count, flush()
+}
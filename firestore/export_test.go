@@ -0,0 +1,111 @@
+package firestore
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	fs "cloud.google.com/go/firestore"
+	"google.golang.org/genproto/googleapis/type/latlng"
+)
+
+func TestTypedFieldsRoundTrip(t *testing.T) {
+	data := map[string]any{
+		"null":      nil,
+		"bool":      true,
+		"int":       int64(math.MaxInt64),
+		"double":    1.5,
+		"infinity":  math.Inf(-1),
+		"timestamp": time.Date(2024, 1, 2, 3, 4, 5, 6, time.UTC),
+		"string":    "text",
+		"bytes":     []byte{0, 1, 2},
+		"ref":       &fs.DocumentRef{Path: "projects/p/databases/(default)/documents/users/u1"},
+		"geo":       &latlng.LatLng{Latitude: 1.5, Longitude: -2.5},
+		"vector":    fs.Vector64{1, 2},
+		"array":     []any{"a", int64(1)},
+		"map":       map[string]any{"nested": map[string]any{"at": time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}},
+	}
+	fields, err := encodeTypedFields(data)
+	if err != nil {
+		t.Fatalf("encodeTypedFields() error = %v", err)
+	}
+	b, err := json.Marshal(map[string]any{"path": "users/u1", "fields": fields})
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+	var record exportRecord
+	if err = json.Unmarshal(b, &record); err != nil {
+		t.Fatalf("json.Unmarshal() error = %v", err)
+	}
+	got, err := decodeTypedFields(record.Fields, func(path string) *fs.DocumentRef {
+		return &fs.DocumentRef{Path: path}
+	})
+	if err != nil {
+		t.Fatalf("decodeTypedFields() error = %v", err)
+	}
+
+	if ref := got["ref"].(*fs.DocumentRef); ref.Path != "users/u1" {
+		t.Errorf("ref = %q, want users/u1", ref.Path)
+	}
+	if geo := got["geo"].(*latlng.LatLng); geo.Latitude != 1.5 || geo.Longitude != -2.5 {
+		t.Errorf("geo = %v", geo)
+	}
+	delete(got, "ref")
+	delete(got, "geo")
+	delete(data, "ref")
+	delete(data, "geo")
+	if !reflect.DeepEqual(got, data) {
+		t.Errorf("decodeTypedFields() = %#v, want %#v", got, data)
+	}
+}
+
+func TestTypedValueRESTShape(t *testing.T) {
+	tests := map[string]struct {
+		value any
+		want  string
+	}{
+		"null":   {nil, `{"nullValue":"NULL_VALUE"}`},
+		"int":    {int64(7), `{"integerValue":"7"}`},
+		"array":  {[]any{"a"}, `{"arrayValue":{"values":[{"stringValue":"a"}]}}`},
+		"map":    {map[string]any{"b": true}, `{"mapValue":{"fields":{"b":{"booleanValue":true}}}}`},
+		"vector": {fs.Vector64{1}, `{"mapValue":{"fields":{"__type__":{"stringValue":"__vector__"},"value":{"arrayValue":{"values":[{"doubleValue":1}]}}}}}`},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			ev, err := encodeTypedValue(tt.value)
+			if err != nil {
+				t.Fatalf("encodeTypedValue() error = %v", err)
+			}
+			if b, _ := json.Marshal(ev); string(b) != tt.want {
+				t.Errorf("encodeTypedValue() = %s, want %s", b, tt.want)
+			}
+		})
+	}
+
+	// the REST API leaves out empty lists and names references in full
+	got, err := decodeTypedFields(map[string]json.RawMessage{
+		"empty": json.RawMessage(`{"arrayValue":{}}`),
+		"ref":   json.RawMessage(`{"referenceValue":"projects/p/databases/(default)/documents/users/u1"}`),
+	}, func(path string) *fs.DocumentRef {
+		return &fs.DocumentRef{Path: path}
+	})
+	if err != nil {
+		t.Fatalf("decodeTypedFields() error = %v", err)
+	}
+	if empty := got["empty"].([]any); len(empty) != 0 {
+		t.Errorf("empty = %v, want no values", empty)
+	}
+	if ref := got["ref"].(*fs.DocumentRef); ref.Path != "users/u1" {
+		t.Errorf("ref = %q, want users/u1", ref.Path)
+	}
+}
+
+func TestDecodeTypedValueRejectsUntyped(t *testing.T) {
+	for _, raw := range []string{`"text"`, `{}`, `{"stringValue":"a","booleanValue":true}`, `{"unknownValue":1}`} {
+		if _, err := decodeTypedValue(json.RawMessage(raw), nil); err == nil {
+			t.Errorf("decodeTypedValue(%s) should be an error", raw)
+		}
+	}
+}
@@ -3,9 +3,7 @@ package firestore
 import (
 	"context"
 	"errors"
-	"fmt"
 	"iter"
-	"maps"
 	"os"
 	"reflect"
 	"slices"
@@ -17,7 +15,6 @@ import (
 	"github.com/jarrodhroberson/ossgo/gcp"
 	slyces "github.com/jarrodhroberson/ossgo/slices"
 
-	"cloud.google.com/go/compute/metadata"
 	fs "cloud.google.com/go/firestore"
 	"golang.org/x/sync/errgroup"
 	"google.golang.org/api/iterator"
@@ -29,7 +26,6 @@ import (
 
 	"github.com/jarrodhroberson/ossgo/functions/must"
 	"github.com/jarrodhroberson/ossgo/seq"
-	strs "github.com/jarrodhroberson/ossgo/strings"
 	"github.com/jarrodhroberson/ossgo/timestamp"
 
 	errs "github.com/jarrodhroberson/ossgo/errors"
@@ -49,11 +45,6 @@ func NewProjection(paths ...string) Projection {
 	}
 }
 
-// NewQuery creates a new Query instance for the specified Firestore collection.
-func NewQuery(collection *fs.CollectionRef) Query {
-	return &newQuery{collection: collection}
-}
-
 // DocRefIDKeyer returns a Keyer function that extracts the ID of a DocumentRef as a string.
 // This is useful for creating maps or sets keyed by DocumentRef IDs.
 func DocRefIDKeyer() containers.Keyer[fs.DocumentRef] {
@@ -70,13 +61,28 @@ func DocSnapShotKeyer() containers.Keyer[fs.DocumentSnapshot] {
 }
 
 // NewCollectionStore creates a new collectionStore for a given database, collection, and keyer function.
-func NewCollectionStore[T any](database DatabaseName, collection string, keyerFunc containers.Keyer[T]) *collectionStore[T] {
+func NewCollectionStore[T any](database DatabaseName, collection string, keyerFunc containers.Keyer[T], options ...CollectionStoreOption) *collectionStore[T] {
+	o := collectionStoreOptions{}
+	for _, option := range options {
+		option(&o)
+	}
+	if len(o.pageTokenKey) == 0 {
+		o.pageTokenKey = processPageTokenKey()
+	}
+	if o.registry == nil {
+		o.registry = DefaultClientRegistry
+	}
 	return &collectionStore[T]{
-		clientProvider: func() *fs.Client {
-			return must.Must(Client(context.Background(), database))
+		clientProvider: func(ctx context.Context) (*fs.Client, error) {
+			if o.client != nil {
+				return o.client, nil
+			}
+			return o.registry.Get(ctx, database)
 		},
 		collection: collection,
 		keyer:      keyerFunc,
+		options:    o,
+		encryption: newFieldEncryption[T](o.keys),
 	}
 }
 
@@ -103,13 +109,13 @@ func DocRefExists(ctx context.Context, docRef *fs.DocumentRef) bool {
 }
 
 // AddTemporalMetadata adds created_at and last_updated_at timestamps to the provided map.
-// It takes a map[string]interface{} and a time.Time value as input parameters.
+// It takes a map[string]interface{} and a Timestamp value as input parameters.
 // The timestamps are added to the map using the keys "created_at" and "last_updated_at".
-// Both timestamps are set to the same provided timestamp value.
+// Both timestamps are set to the same provided timestamp value, as a time.Time so Firestore stores a timestamp.
 // Returns the modified map containing the added timestamp fields.
 func AddTemporalMetadata(m map[string]interface{}, t *timestamp.Timestamp) map[string]interface{} {
-	m["created_at"] = t.String()
-	m["last_updated_at"] = t.String()
+	m[CREATED_AT] = timestamp.To(t)
+	m[LAST_UPDATED_AT] = timestamp.To(t)
 	return m
 }
 
@@ -147,44 +153,34 @@ func DeleteCollection(ctx context.Context, client *fs.Client, path string) error
 	return errgp.Wait()
 }
 
-// Client creates a new Firestore client for the specified database.
+// Client creates a new Firestore client for the specified database, the caller owns the client and must close it.
+// Prefer ClientRegistry.Get, which shares one client per database.
+// The project is GOOGLE_CLOUD_PROJECT, or the project of the Google Cloud environment the code is running in.
 func Client(ctx context.Context, database DatabaseName) (*fs.Client, error) {
 	if strings.Trim(string(database), " ") == "" {
 		return nil, errorx.IllegalArgument.New("DatabaseName can not be an empty string")
 	}
-	projectId := strs.FirstNonEmpty(os.Getenv("GOOGLE_CLOUD_PROJECT"), must.Must(gcp.ProjectId()), must.Must(metadata.ProjectIDWithContext(ctx)))
+	projectId := os.Getenv("GOOGLE_CLOUD_PROJECT")
 	if projectId == "" {
-		return nil, errorx.IllegalArgument.New("projectId can not be an empty string")
+		var err error
+		if projectId, err = gcp.ProjectId(); err != nil {
+			return nil, errs.ClientNotInitialized.Wrap(err, "could not determine the project of database %s, set GOOGLE_CLOUD_PROJECT", database)
+		}
 	}
 	client, err := fs.NewClientWithDatabase(ctx, projectId, string(database))
 	if err != nil {
-		log.Fatal().Err(err).Msgf("error creating firestore client %s", err)
-		return nil, err
+		return nil, errs.ClientNotInitialized.Wrap(err, "could not create firestore client for database %s", database)
 	}
 	return client, nil
 }
 
-// Count returns the number of documents that match the given query.
-func Count(ctx context.Context, query fs.Query) int64 {
-	cq := query.NewAggregationQuery().WithCount("count")
-	cqr, err := cq.Get(ctx)
+// Count returns the number of documents that match the given query, without reading them.
+func Count(ctx context.Context, query fs.Query) (int64, error) {
+	results, err := Aggregate(ctx, query, CountOf("count"))
 	if err != nil {
-		log.Fatal().Err(err).Msg("could not run aggregation query")
-		return -1
-	}
-	value, ok := cqr["count"]
-	if !ok {
-		err = errs.MustNeverError.New("could not get \"count\" from results %s", strings.Join(slices.Collect(maps.Keys(cqr)), ","))
-		log.Error().Stack().Err(err).Msg(err.Error())
-		panic(errorx.Panic(err))
+		return 0, err
 	}
-	count, ok := value.(int64)
-	if !ok {
-		err := errs.MustNeverError.New("could not assert that \"%s\" was of type int64", "count")
-		log.Error().Stack().Err(err).Msg(err.Error())
-		panic(errorx.Panic(err))
-	}
-	return count
+	return results.Int64("count")
 }
 
 // MapToUpdates converts a map to a slice of Firestore Update structs.
@@ -213,65 +209,20 @@ func MapToUpdates(m map[string]interface{}) []fs.Update {
 	return updates
 }
 
-// traverseFirestore recursively traverses a Firestore document and its subcollections.
-func traverseFirestore(ctx context.Context, docRef fs.DocumentRef) (map[string]interface{}, error) {
-	var tree map[string]interface{}
-
-	// Load the document snapshot
-	colIter := docRef.Collections(ctx)
-	for {
-		colRef, err := colIter.Next()
-		if err == iterator.Done {
-			break
-		}
-		if err != nil {
-			return nil, err
-		}
-		tree[colRef.Path] = make([]interface{}, 0)
-	}
-
-	docSnap, err := docRef.Get(ctx)
-	if err != nil {
-		return nil, err
-	}
-
-	// Extract document data and collections
-	data := docSnap.Data()
-	for k, v := range data {
-		switch v.(type) {
-		case map[string]interface{}:
-			tree[k] = v.(map[string]interface{})
-		case []interface{}:
-			tree[k] = v.([]interface{})
-		case string, float64, bool:
-			tree[k] = v
-		case int64:
-			tree[k] = strconv.Itoa(int(v.(int64)))
-		case uint64:
-			tree[k] = strconv.Itoa(int(v.(uint64)))
-		case int:
-			tree[k] = strconv.Itoa(v.(int))
-		case uint:
-			tree[k] = strconv.Itoa(int(v.(uint)))
-		default:
-			return nil, fmt.Errorf("unsupported type %T for key %s", v, k)
-		}
-	}
-
-	return tree, nil
-}
-
 // DocSnapShotToType unmarshals a Firestore DocumentSnapshot into a struct of type T.
+// A document that does not decode into a T is returned as an error, it does not panic.
 func DocSnapShotToType[T any](dss *fs.DocumentSnapshot) (*T, error) {
-	var d T
 	m := make(map[string]interface{})
 	err := dss.DataTo(&m)
 	if err != nil {
 		err = errs.MarshalError.Wrap(err, "error unmarshalling Firestore document with ID %s", dss.Ref.ID)
 		return nil, err
 	}
-	must.UnmarshallMap(m, &d)
-	return &d, nil
+	d, err := decodeFields[T](m)
+	if err != nil {
+		return nil, errs.MarshalError.Wrap(err, "error unmarshalling Firestore document with ID %s", dss.Ref.ID)
+	}
+	return d, nil
 }
 
 // DocSnapShotSeq2ToType converts a Seq2 of DocumentSnapshots to a Seq2 of type V.
@@ -329,6 +280,27 @@ func DocumentIteratorToSeq(dsi *fs.DocumentIterator) iter.Seq[*fs.DocumentSnapsh
 	}
 }
 
+// DocumentIteratorToSeqErr converts a firestore.Iterator to an iter.Seq2 that yields the error that ended
+// the iteration instead of logging it like DocumentIteratorToSeq.
+func DocumentIteratorToSeqErr(dsi *fs.DocumentIterator) iter.Seq2[*fs.DocumentSnapshot, error] {
+	return func(yield func(*fs.DocumentSnapshot, error) bool) {
+		defer dsi.Stop()
+		for {
+			docSS, err := dsi.Next()
+			if errors.Is(err, iterator.Done) {
+				return
+			}
+			if err != nil {
+				yield(nil, errs.NotReadError.Wrap(err, "error iterating through Firestore documents"))
+				return
+			}
+			if !yield(docSS, nil) {
+				return
+			}
+		}
+	}
+}
+
 // DocumentIteratorToSeq2 converts a firestore.Iterator to an iter.Seq2.
 // doc.Ref.ID is used as the "key" or first value, second value is a pointer to the type V
 func DocumentIteratorToSeq2(dsi *fs.DocumentIterator) iter.Seq2[string, *fs.DocumentSnapshot] {
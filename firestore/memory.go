@@ -0,0 +1,733 @@
+package firestore
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	fs "cloud.google.com/go/firestore"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/jarrodhroberson/ossgo/containers"
+	errs "github.com/jarrodhroberson/ossgo/errors"
+	"github.com/jarrodhroberson/ossgo/functions/must"
+)
+
+// MemoryCollectionStore is a CollectionStore that keeps the documents in memory, for tests and local development.
+// It behaves like collectionStore against Firestore: documents are encoded and decoded through their json tags,
+// queries are evaluated with the ordering and filter semantics of Firestore, the temporal metadata, tombstones
+// and encrypted fields are written the same way and missing or existing documents are the same gRPC status
+// errors, so IsNotFound, IsAlreadyExists and IsConflict work on them.
+type MemoryCollectionStore[T any] struct {
+	mu          sync.RWMutex
+	collection  *fs.CollectionRef
+	keyer       containers.Keyer[T]
+	options     collectionStoreOptions
+	encryption  fieldEncryption
+	docs        map[string]memoryDocument
+	audits      map[string][]AuditEntry
+	clock       time.Time
+	subscribers map[chan struct{}]struct{}
+}
+
+// NewMemoryCollectionStore creates an empty in-memory CollectionStore for collection, the keyer returns the
+// document ID of a T. It takes the options of NewCollectionStore except WithClient and WithClientRegistry,
+// it never uses a client. With WithAudit the audit trail is kept in memory, read it with AuditTrail.
+//
+// Example usage:
+//
+//	store := firestore.NewMemoryCollectionStore[User]("users", func(u *User) string { return u.ID })
+//	svc := NewUserService(store)
+func NewMemoryCollectionStore[T any](collection string, keyer containers.Keyer[T], options ...CollectionStoreOption) *MemoryCollectionStore[T] {
+	o := collectionStoreOptions{}
+	for _, option := range options {
+		option(&o)
+	}
+	if len(o.pageTokenKey) == 0 {
+		o.pageTokenKey = processPageTokenKey()
+	}
+	return &MemoryCollectionStore[T]{
+		collection:  new(fs.Client).Collection(collection),
+		keyer:       keyer,
+		options:     o,
+		encryption:  newFieldEncryption[T](o.keys),
+		docs:        make(map[string]memoryDocument),
+		audits:      make(map[string][]AuditEntry),
+		subscribers: make(map[chan struct{}]struct{}),
+	}
+}
+
+// tick returns the update time of a write, Firestore update times have microsecond precision and every
+// write gets a later one so LastUpdateTime preconditions tell them apart. It must be called with mu locked.
+func (s *MemoryCollectionStore[T]) tick() time.Time {
+	now := time.Now().Truncate(time.Microsecond)
+	if !now.After(s.clock) {
+		now = s.clock.Add(time.Microsecond)
+	}
+	s.clock = now
+	return now
+}
+
+// notify wakes up every Watch, it must be called with mu locked.
+func (s *MemoryCollectionStore[T]) notify() {
+	for subscriber := range s.subscribers {
+		select {
+		case subscriber <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// write replaces the data of the document, keeping its create time if it exists. It must be called with mu locked.
+func (s *MemoryCollectionStore[T]) write(id string, data map[string]any) memoryDocument {
+	now := s.tick()
+	doc, ok := s.docs[id]
+	if !ok {
+		doc = memoryDocument{id: id, createTime: now}
+	}
+	doc.data, doc.updateTime = data, now
+	s.docs[id] = doc
+	s.notify()
+	return doc
+}
+
+func (s *MemoryCollectionStore[T]) notFound(id string) error {
+	return status.Errorf(codes.NotFound, "%q not found", s.collection.Doc(id).Path)
+}
+
+// snapshot returns the documents ordered by ID and the time they were read.
+func (s *MemoryCollectionStore[T]) snapshot() ([]memoryDocument, time.Time) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	docs := slices.Collect(maps.Values(s.docs))
+	slices.SortFunc(docs, func(a memoryDocument, b memoryDocument) int {
+		return strings.Compare(a.id, b.id)
+	})
+	return docs, s.clock
+}
+
+// run returns the results of the query and the time they were read.
+func (s *MemoryCollectionStore[T]) run(mq memoryQuery) ([]memoryDocument, time.Time, error) {
+	docs, readTime := s.snapshot()
+	results, err := mq.run(docs)
+	return results, readTime, err
+}
+
+// decode decrypts the document and decodes the fields in projection into a T, through its json tags
+// like DocSnapShotToType.
+func (s *MemoryCollectionStore[T]) decode(ctx context.Context, doc memoryDocument, projection *Projection) (*T, error) {
+	data, err := s.plaintext(ctx, doc)
+	if err != nil {
+		return nil, err
+	}
+	t, err := decodeFields[T](project(data, projection))
+	if err != nil {
+		return nil, errs.MarshalError.Wrap(err, "error unmarshalling document with ID %s", doc.id)
+	}
+	return t, nil
+}
+
+// plaintext returns a copy of the fields of the document with the encrypted fields decrypted.
+func (s *MemoryCollectionStore[T]) plaintext(ctx context.Context, doc memoryDocument) (map[string]any, error) {
+	data := cloneValue(doc.data).(map[string]any)
+	if err := s.encryption.decrypt(ctx, data); err != nil {
+		return nil, EncryptionError.Wrap(err, "could not decrypt document with ID %s", doc.id)
+	}
+	return data, nil
+}
+
+// query is the Query the builders of Query, Page, Watch and Aggregate start from, like collectionStore.query.
+func (s *MemoryCollectionStore[T]) query() Query[T] {
+	return liveQuery[T](s.collection, s.options)
+}
+
+// All yields every document in the collection ordered by ID.
+func (s *MemoryCollectionStore[T]) All() iter.Seq2[string, *T] {
+	return func(yield func(string, *T) bool) {
+		docs, _ := s.snapshot()
+		for _, doc := range docs {
+			if s.options.isTombstone(doc.data) {
+				continue
+			}
+			if !yield(doc.id, must.Must(s.decode(context.Background(), doc, nil))) {
+				return
+			}
+		}
+	}
+}
+
+// Find yields the documents matching where, the fs.Query built by where is evaluated in memory.
+// A query that can not be evaluated is logged and yields nothing.
+func (s *MemoryCollectionStore[T]) Find(where WherePredicate, selectPaths Projection) iter.Seq[*T] {
+	return func(yield func(*T) bool) {
+		live := where
+		if s.options.softDelete {
+			live = func(q fs.Query) fs.Query {
+				q = q.Where(DELETED_AT, QueryOps.Equals.String(), nil)
+				if where != nil {
+					q = where(q)
+				}
+				return q
+			}
+		}
+		mq, err := memoryQueryOfPredicate(s.collection, live)
+		var docs []memoryDocument
+		if err == nil {
+			docs, _, err = s.run(mq)
+		}
+		if err != nil {
+			log.Err(err).Msg(err.Error())
+			return
+		}
+		for _, doc := range docs {
+			t, err := s.decode(context.Background(), doc, &selectPaths)
+			if err != nil {
+				log.Err(err).Msg(err.Error())
+				return
+			}
+			if !yield(t) {
+				return
+			}
+		}
+	}
+}
+
+// Query runs the query built by build on the collection and yields the typed results.
+func (s *MemoryCollectionStore[T]) Query(ctx context.Context, build func(q Query[T]) Query[T]) iter.Seq2[*T, error] {
+	return func(yield func(*T, error) bool) {
+		mq, err := memoryQueryOf(build(s.query()))
+		var docs []memoryDocument
+		if err == nil {
+			docs, _, err = s.run(mq)
+		}
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		for _, doc := range docs {
+			if ctx.Err() != nil {
+				yield(nil, ctx.Err())
+				return
+			}
+			if !yield(s.decode(ctx, doc, mq.projection)) {
+				return
+			}
+		}
+	}
+}
+
+// Watch yields a Change for every document added to, modified in or removed from the results of the query built
+// by build, starting with every current result as added, until the consumer stops or ctx is done.
+func (s *MemoryCollectionStore[T]) Watch(ctx context.Context, build func(q Query[T]) Query[T]) iter.Seq2[Change[T], error] {
+	return func(yield func(Change[T], error) bool) {
+		mq, err := memoryQueryOf(build(s.query()))
+		if err != nil {
+			yield(Change[T]{}, err)
+			return
+		}
+		notified := make(chan struct{}, 1)
+		s.mu.Lock()
+		s.subscribers[notified] = struct{}{}
+		s.mu.Unlock()
+		defer func() {
+			s.mu.Lock()
+			delete(s.subscribers, notified)
+			s.mu.Unlock()
+		}()
+
+		seen := make(map[string]memoryDocument)
+		for {
+			docs, readTime, err := s.run(mq)
+			if err != nil {
+				yield(Change[T]{}, err)
+				return
+			}
+			for _, change := range diffResults(seen, docs) {
+				c := Change[T]{Kind: change.kind, ID: change.doc.id, UpdateTime: change.doc.updateTime, ReadTime: readTime}
+				c.Value, err = s.decode(ctx, change.doc, mq.projection)
+				if !yield(c, err) {
+					return
+				}
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-notified:
+			}
+		}
+	}
+}
+
+type memoryChange struct {
+	kind ChangeKind
+	doc  memoryDocument
+}
+
+// diffResults returns the changes from the results in seen to the results in docs and replaces seen with docs.
+// A removed document has the last value that was seen.
+func diffResults(seen map[string]memoryDocument, docs []memoryDocument) []memoryChange {
+	changes := make([]memoryChange, 0)
+	current := make(map[string]struct{}, len(docs))
+	for _, doc := range docs {
+		current[doc.id] = struct{}{}
+		previous, ok := seen[doc.id]
+		seen[doc.id] = doc
+		if !ok {
+			changes = append(changes, memoryChange{kind: ChangeKinds.Added, doc: doc})
+		} else if !previous.updateTime.Equal(doc.updateTime) {
+			changes = append(changes, memoryChange{kind: ChangeKinds.Modified, doc: doc})
+		}
+	}
+	for _, id := range slices.Sorted(maps.Keys(seen)) {
+		if _, ok := current[id]; !ok {
+			changes = append(changes, memoryChange{kind: ChangeKinds.Removed, doc: seen[id]})
+			delete(seen, id)
+		}
+	}
+	return changes
+}
+
+// Page returns pageSize results of the query built by build, starting after the document the pageToken points to.
+// Tokens are the same as the tokens of collectionStore.Page.
+func (s *MemoryCollectionStore[T]) Page(ctx context.Context, build func(q Query[T]) Query[T], pageSize int, pageToken string) (*ResultPage[T], error) {
+	return page(build(s.query()), pageSize, pageToken, s.options.pageTokenKey, s.pageSource(ctx))
+}
+
+// Aggregate runs the aggregations over the documents matching the query built by build, with the results
+// Firestore returns for them.
+func (s *MemoryCollectionStore[T]) Aggregate(ctx context.Context, build func(q Query[T]) Query[T], aggregations ...Aggregation) (AggregationResults, error) {
+	if err := validateAggregations(aggregations); err != nil {
+		return nil, err
+	}
+	mq, err := memoryQueryOf(build(s.query()))
+	if err != nil {
+		return nil, err
+	}
+	docs, _, err := s.run(mq)
+	if err != nil {
+		return nil, err
+	}
+	results := make(AggregationResults, len(aggregations))
+	for _, a := range aggregations {
+		if a.kind == countAggregation {
+			results[a.alias] = int64(len(docs))
+			continue
+		}
+		var sum float64
+		var n int
+		integers := true
+		for _, doc := range docs {
+			v, ok := lookup(doc, splitPath(a.field))
+			if !ok {
+				continue
+			}
+			f, ok := normalize(v).(float64)
+			if !ok {
+				continue
+			}
+			sum += f
+			n++
+			switch v.(type) {
+			case float32, float64:
+				integers = false
+			}
+		}
+		switch {
+		case a.kind == sumAggregation && integers:
+			results[a.alias] = int64(sum)
+		case a.kind == sumAggregation:
+			results[a.alias] = sum
+		case n == 0:
+			results[a.alias] = nil
+		default:
+			results[a.alias] = sum / float64(n)
+		}
+	}
+	return results, nil
+}
+
+// pageSource runs the query of a page in memory.
+func (s *MemoryCollectionStore[T]) pageSource(ctx context.Context) pageSource[T] {
+	return func(q Query[T]) iter.Seq2[pageResult[T], error] {
+		return func(yield func(pageResult[T], error) bool) {
+			mq, err := memoryQueryOf(q)
+			var docs []memoryDocument
+			if err == nil {
+				docs, _, err = s.run(mq)
+			}
+			if err != nil {
+				yield(pageResult[T]{}, err)
+				return
+			}
+			for _, doc := range docs {
+				pr := pageResult[T]{
+					id: doc.id,
+					dataAt: func(field string) (any, error) {
+						if v, ok := lookup(doc, splitPath(field)); ok {
+							return v, nil
+						}
+						return nil, errs.NotFoundError.New("document %s has no field %s", doc.id, field)
+					},
+					value: func() (*T, error) {
+						return s.decode(ctx, doc, mq.projection)
+					},
+				}
+				if !yield(pr, nil) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// Load reads the document with the given id, a missing document is an error, check with IsNotFound.
+func (s *MemoryCollectionStore[T]) Load(id string) (*T, error) {
+	v, err := s.LoadVersioned(context.Background(), id)
+	if err != nil {
+		return nil, err
+	}
+	return v.Value, nil
+}
+
+// mutate is collectionStore.write on the documents in memory: change gets the plain text fields of the document
+// id, nil when it does not exist or is a tombstone, the fields it returns replace the document, nil fields delete
+// it and an empty AuditOperation writes nothing. A document written since version, when it is not zero, is a
+// ConflictError. With WithAudit the AuditEntry of the write is appended to the audit trail of the document.
+// It returns the document that was written. It must be called with mu locked.
+func (s *MemoryCollectionStore[T]) mutate(ctx context.Context, id string, version time.Time, change func(live map[string]any) (map[string]any, AuditOperation, error)) (memoryDocument, error) {
+	var before, live map[string]any
+	doc, exists := s.docs[id]
+	if exists {
+		var err error
+		if before, err = s.plaintext(ctx, doc); err != nil {
+			return doc, err
+		}
+		if !s.options.isTombstone(before) {
+			live = before
+		}
+		if !version.IsZero() && !doc.updateTime.Equal(version) {
+			return doc, conflictOrErr(status.Errorf(codes.FailedPrecondition, "document %s was updated at %s", s.collection.Doc(id).Path, doc.updateTime), id)
+		}
+	}
+	after, op, err := change(live)
+	if err != nil || op == "" {
+		return doc, err
+	}
+
+	switch {
+	case after == nil && before == nil:
+		return doc, nil
+	case after == nil:
+		delete(s.docs, id)
+		doc.updateTime = time.Time{}
+		s.notify()
+	default:
+		data := cloneValue(after).(map[string]any)
+		if err = s.encryption.encrypt(ctx, data); err != nil {
+			return doc, err
+		}
+		doc = s.write(id, data)
+	}
+
+	if s.options.audit {
+		entry, err := s.options.auditEntry(ctx, op, before, after, s.encryption.fields)
+		if err != nil {
+			return doc, err
+		}
+		entry.UpdateTime = doc.updateTime
+		s.audits[id] = append(s.audits[id], entry)
+	}
+	return doc, nil
+}
+
+// Store writes v whether or not the document exists, the last writer wins.
+func (s *MemoryCollectionStore[T]) Store(v *T) (*T, error) {
+	if err := s.store(context.Background(), v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// store is Store with the context of the write.
+func (s *MemoryCollectionStore[T]) store(ctx context.Context, v *T) error {
+	m := must.MarshallMap(v)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := s.mutate(ctx, s.keyer(v), time.Time{}, func(live map[string]any) (map[string]any, AuditOperation, error) {
+		return m, s.options.stamp(ctx, m, live, time.Now()), nil
+	})
+	return err
+}
+
+// Create stores v as a new document, it fails if a document with the same key already exists,
+// check with IsAlreadyExists. In a WithSoftDelete store a tombstone with the same key is replaced.
+func (s *MemoryCollectionStore[T]) Create(ctx context.Context, v *T) (*Versioned[T], error) {
+	id := s.keyer(v)
+	m := must.MarshallMap(v)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	doc, err := s.mutate(ctx, id, time.Time{}, func(live map[string]any) (map[string]any, AuditOperation, error) {
+		if live != nil {
+			return nil, "", status.Errorf(codes.AlreadyExists, "document %s already exists", s.collection.Doc(id).Path)
+		}
+		return m, s.options.stamp(ctx, m, nil, time.Now()), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Versioned[T]{Value: v, UpdateTime: doc.updateTime}, nil
+}
+
+// LoadVersioned loads the document with its update time, to be passed to Update.
+func (s *MemoryCollectionStore[T]) LoadVersioned(ctx context.Context, id string) (*Versioned[T], error) {
+	s.mu.RLock()
+	doc, ok := s.docs[id]
+	s.mu.RUnlock()
+	if !ok || s.options.isTombstone(doc.data) {
+		return nil, s.notFound(id)
+	}
+	t, err := s.decode(ctx, doc, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &Versioned[T]{Value: t, UpdateTime: doc.updateTime}, nil
+}
+
+// Update writes the fields of current.Value to the existing document with the same precondition as
+// collectionStore.Update, a document written since current was read is a ConflictError.
+func (s *MemoryCollectionStore[T]) Update(ctx context.Context, current *Versioned[T], fields ...string) (*Versioned[T], error) {
+	doc, err := s.update(ctx, current.Value, current.UpdateTime, fields...)
+	if err != nil {
+		return nil, err
+	}
+	return &Versioned[T]{Value: current.Value, UpdateTime: doc.updateTime}, nil
+}
+
+// update writes the fields of v to the existing document, a document written since version, when it is not
+// zero, is a ConflictError. It returns the document that was written.
+func (s *MemoryCollectionStore[T]) update(ctx context.Context, v *T, version time.Time, fields ...string) (memoryDocument, error) {
+	id := s.keyer(v)
+	m := must.MarshallMap(v)
+	updated := s.options.updated(ctx, m, time.Now())
+	if len(fields) > 0 {
+		fields = append(fields, updated...)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.mutate(ctx, id, version, func(live map[string]any) (map[string]any, AuditOperation, error) {
+		if live == nil {
+			return nil, "", s.notFound(id)
+		}
+		after := cloneValue(live).(map[string]any)
+		for _, u := range fieldMask(m, fields) {
+			path := u.FieldPath
+			if path == nil {
+				path = splitPath(u.Path)
+			}
+			applyUpdate(after, path, u.Value)
+		}
+		return after, AuditOperations.Updated, nil
+	})
+}
+
+// cloneValue copies the maps and slices of a document value so an update does not change what was read before.
+func cloneValue(v any) any {
+	switch vt := v.(type) {
+	case map[string]any:
+		m := make(map[string]any, len(vt))
+		for k, e := range vt {
+			m[k] = cloneValue(e)
+		}
+		return m
+	case []any:
+		values := make([]any, 0, len(vt))
+		for _, e := range vt {
+			values = append(values, cloneValue(e))
+		}
+		return values
+	default:
+		return v
+	}
+}
+
+// applyUpdate sets the field at path to value, creating the maps on the way, fs.Delete removes the field.
+func applyUpdate(data map[string]any, path fs.FieldPath, value any) {
+	m := data
+	for _, segment := range path[:len(path)-1] {
+		next, ok := m[segment].(map[string]any)
+		if !ok {
+			if value == fs.Delete {
+				return
+			}
+			next = make(map[string]any)
+			m[segment] = next
+		}
+		m = next
+	}
+	if value == fs.Delete {
+		delete(m, path[len(path)-1])
+		return
+	}
+	m[path[len(path)-1]] = value
+}
+
+// Modify is the read-modify-write loop of collectionStore.Modify.
+func (s *MemoryCollectionStore[T]) Modify(ctx context.Context, id string, attempts int, mutate func(t *T) error, fields ...string) (*Versioned[T], error) {
+	return modify[T](ctx, s, id, attempts, mutate, fields...)
+}
+
+// InTransaction returns a TransactionStore that reads and writes the documents of this store with the same
+// metadata, encryption and audit trail as its other methods. Only the Context and ReadOnly of tx are used,
+// the Firestore transaction of tx is not, so a test can pass its own UnitOfWork. Unlike a Firestore
+// transaction the writes are applied as they are made and are not undone when the transaction fails,
+// and reads may follow writes.
+func (s *MemoryCollectionStore[T]) InTransaction(tx UnitOfWork) TransactionStore[T] {
+	return memoryTransactionStore[T]{store: s, uow: tx}
+}
+
+// memoryTransactionStore is the TransactionStore of a MemoryCollectionStore.
+type memoryTransactionStore[T any] struct {
+	store *MemoryCollectionStore[T]
+	uow   UnitOfWork
+}
+
+func (m memoryTransactionStore[T]) Load(id string) (*T, error) {
+	v, err := m.store.LoadVersioned(m.uow.Context(), id)
+	if err != nil {
+		return nil, err
+	}
+	return v.Value, nil
+}
+
+func (m memoryTransactionStore[T]) Exists(id string) (bool, error) {
+	_, err := m.Load(id)
+	if IsNotFound(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (m memoryTransactionStore[T]) LoadAll(ids ...string) ([]*T, error) {
+	items := make([]*T, len(ids))
+	for i, id := range ids {
+		t, err := m.Load(id)
+		if IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		items[i] = t
+	}
+	return items, nil
+}
+
+func (m memoryTransactionStore[T]) Query(build func(q Query[T]) Query[T]) iter.Seq2[*T, error] {
+	return m.store.Query(m.uow.Context(), build)
+}
+
+func (m memoryTransactionStore[T]) Create(v *T) error {
+	if err := writable(m.uow, m.store.collection.ID); err != nil {
+		return err
+	}
+	_, err := m.store.Create(m.uow.Context(), v)
+	return err
+}
+
+func (m memoryTransactionStore[T]) Store(v *T) error {
+	if err := writable(m.uow, m.store.collection.ID); err != nil {
+		return err
+	}
+	return m.store.store(m.uow.Context(), v)
+}
+
+func (m memoryTransactionStore[T]) Update(v *T, fields ...string) error {
+	if err := writable(m.uow, m.store.collection.ID); err != nil {
+		return err
+	}
+	_, err := m.store.update(m.uow.Context(), v, time.Time{}, fields...)
+	return err
+}
+
+func (m memoryTransactionStore[T]) Remove(id string) error {
+	if err := writable(m.uow, m.store.collection.ID); err != nil {
+		return err
+	}
+	return m.store.remove(m.uow.Context(), id)
+}
+
+// BulkStore stores every item like Store. With FAIL_ON_FIRST_ERROR it stops at the first item that fails,
+// with COLLECT_ERRORS it stores the other items and returns every error joined.
+func (s *MemoryCollectionStore[T]) BulkStore(iter iter.Seq[*T], errorHandling BulkStoreErrorHandling) error {
+	var failed []error
+	for item := range iter {
+		if _, err := s.Store(item); err != nil {
+			if errorHandling == FAIL_ON_FIRST_ERROR {
+				return err
+			}
+			failed = append(failed, err)
+		}
+	}
+	return errors.Join(failed...)
+}
+
+// Remove deletes the document with the given id, in a WithSoftDelete store by writing deleted_at and
+// deleted_by. A missing document is not an error.
+func (s *MemoryCollectionStore[T]) Remove(id string) error {
+	if err := s.remove(context.Background(), id); err != nil {
+		return errs.NotDeletedError.Wrap(err, "failed to delete %s", s.collection.Doc(id).Path)
+	}
+	return nil
+}
+
+// remove is Remove with the context of the write.
+func (s *MemoryCollectionStore[T]) remove(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := s.mutate(ctx, id, time.Time{}, func(live map[string]any) (map[string]any, AuditOperation, error) {
+		if live == nil {
+			return nil, "", nil
+		}
+		if !s.options.softDelete {
+			return nil, AuditOperations.Deleted, nil
+		}
+		after := cloneValue(live).(map[string]any)
+		after[DELETED_AT] = time.Now()
+		s.options.setActor(ctx, after, DELETED_BY)
+		return after, AuditOperations.Deleted, nil
+	})
+	return err
+}
+
+// BulkRemove deletes every document like Remove.
+func (s *MemoryCollectionStore[T]) BulkRemove(iter iter.Seq[string], errorHandling BulkStoreErrorHandling) error {
+	for id := range iter {
+		if err := s.Remove(id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AuditTrail yields the entries of the audit trail of the document id in the order they were written,
+// the store must have WithAudit for there to be any.
+func (s *MemoryCollectionStore[T]) AuditTrail(ctx context.Context, id string) iter.Seq2[*AuditEntry, error] {
+	return func(yield func(*AuditEntry, error) bool) {
+		s.mu.RLock()
+		entries := slices.Clone(s.audits[id])
+		s.mu.RUnlock()
+		for _, entry := range entries {
+			if !yield(&entry, nil) {
+				return
+			}
+		}
+	}
+}
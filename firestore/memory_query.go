@@ -0,0 +1,627 @@
+package firestore
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"maps"
+	"math"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	fs "cloud.google.com/go/firestore"
+	pb "cloud.google.com/go/firestore/apiv1/firestorepb"
+	"github.com/joomcode/errorx"
+	"google.golang.org/genproto/googleapis/type/latlng"
+	"google.golang.org/protobuf/proto"
+
+	errs "github.com/jarrodhroberson/ossgo/errors"
+)
+
+// memoryDocument is a document of a MemoryCollectionStore, data is never modified once it is stored.
+type memoryDocument struct {
+	id         string
+	data       map[string]any
+	createTime time.Time
+	updateTime time.Time
+}
+
+type memoryOrder struct {
+	path      fs.FieldPath
+	direction fs.Direction
+}
+
+// memoryQuery is a query evaluated in memory the way Firestore evaluates it.
+type memoryQuery struct {
+	filter     fs.EntityFilter
+	orders     []memoryOrder
+	offset     int
+	limit      int
+	toLast     bool
+	startAt    []any
+	startAfter []any
+	endAt      []any
+	endBefore  []any
+	projection *Projection
+}
+
+// splitPath splits a dot separated field path, fs.DocumentID is a path on its own.
+func splitPath(path string) fs.FieldPath {
+	if path == fs.DocumentID {
+		return fs.FieldPath{fs.DocumentID}
+	}
+	return strings.Split(path, ".")
+}
+
+// memoryQueryOf returns the memoryQuery of q, a query that does not build is an error.
+func memoryQueryOf[T any](q Query[T]) (memoryQuery, error) {
+	if _, err := q.Build(); err != nil {
+		return memoryQuery{}, err
+	}
+	mq := memoryQuery{
+		limit:      q.limit,
+		toLast:     q.toLast,
+		startAt:    q.startAt,
+		startAfter: q.startAfter,
+		endAt:      q.endAt,
+		endBefore:  q.endBefore,
+		projection: q.projection,
+	}
+	if len(q.filters) > 0 {
+		mq.filter = And(q.filters...).entity
+	}
+	for _, o := range q.orders {
+		mq.orders = append(mq.orders, memoryOrder{path: splitPath(o.field), direction: o.direction})
+	}
+	return mq, nil
+}
+
+// memoryQueryOfPredicate returns the memoryQuery of the fs.Query built by where on collection.
+// An fs.Query can only be read through its serialized form, which is the RunQueryRequest sent to Firestore.
+func memoryQueryOfPredicate(collection *fs.CollectionRef, where WherePredicate) (memoryQuery, error) {
+	if where == nil {
+		return memoryQuery{}, nil
+	}
+	b, err := where(collection.Query).Serialize()
+	if err != nil {
+		return memoryQuery{}, errorx.IllegalArgument.Wrap(err, "invalid query")
+	}
+	var req pb.RunQueryRequest
+	if err = proto.Unmarshal(b, &req); err != nil {
+		return memoryQuery{}, errs.UnMarshalError.Wrap(err, "could not read query")
+	}
+	sq := req.GetStructuredQuery()
+	mq := memoryQuery{offset: int(sq.GetOffset()), limit: int(sq.GetLimit().GetValue())}
+	if sq.GetWhere() != nil {
+		if mq.filter, err = entityFilterOf(sq.GetWhere()); err != nil {
+			return memoryQuery{}, err
+		}
+	}
+	for _, o := range sq.GetOrderBy() {
+		direction := fs.Asc
+		if o.GetDirection() == pb.StructuredQuery_DESCENDING {
+			direction = fs.Desc
+		}
+		mq.orders = append(mq.orders, memoryOrder{path: parseFieldPath(o.GetField().GetFieldPath()), direction: direction})
+	}
+	if cursor := sq.GetStartAt(); cursor != nil {
+		if cursor.GetBefore() {
+			mq.startAt = valuesOf(cursor.GetValues())
+		} else {
+			mq.startAfter = valuesOf(cursor.GetValues())
+		}
+	}
+	if cursor := sq.GetEndAt(); cursor != nil {
+		if cursor.GetBefore() {
+			mq.endBefore = valuesOf(cursor.GetValues())
+		} else {
+			mq.endAt = valuesOf(cursor.GetValues())
+		}
+	}
+	if sq.GetSelect() != nil {
+		projection := Projection{fieldPaths: make([]fs.FieldPath, 0, len(sq.GetSelect().GetFields()))}
+		for _, field := range sq.GetSelect().GetFields() {
+			if fp := parseFieldPath(field.GetFieldPath()); !slices.Equal(fp, fs.FieldPath{fs.DocumentID}) {
+				projection.fieldPaths = append(projection.fieldPaths, fp)
+			}
+		}
+		mq.projection = &projection
+	}
+	return mq, nil
+}
+
+// parseFieldPath parses the field path of a query, segments that are not simple names are quoted with backticks.
+func parseFieldPath(s string) fs.FieldPath {
+	path := make(fs.FieldPath, 0)
+	var segment strings.Builder
+	quoted, escaped := false, false
+	for _, r := range s {
+		switch {
+		case escaped:
+			segment.WriteRune(r)
+			escaped = false
+		case quoted && r == '\\':
+			escaped = true
+		case r == '`':
+			quoted = !quoted
+		case !quoted && r == '.':
+			path = append(path, segment.String())
+			segment.Reset()
+		default:
+			segment.WriteRune(r)
+		}
+	}
+	return append(path, segment.String())
+}
+
+var fieldFilterOps = map[pb.StructuredQuery_FieldFilter_Operator]QueryOp{
+	pb.StructuredQuery_FieldFilter_LESS_THAN:             QueryOps.LessThan,
+	pb.StructuredQuery_FieldFilter_LESS_THAN_OR_EQUAL:    QueryOps.LessThanOrEqual,
+	pb.StructuredQuery_FieldFilter_GREATER_THAN:          QueryOps.GreaterThan,
+	pb.StructuredQuery_FieldFilter_GREATER_THAN_OR_EQUAL: QueryOps.GreaterThanOrEquals,
+	pb.StructuredQuery_FieldFilter_EQUAL:                 QueryOps.Equals,
+	pb.StructuredQuery_FieldFilter_NOT_EQUAL:             QueryOps.NotEquals,
+	pb.StructuredQuery_FieldFilter_ARRAY_CONTAINS:        QueryOps.ArrayContains,
+	pb.StructuredQuery_FieldFilter_IN:                    QueryOps.In,
+	pb.StructuredQuery_FieldFilter_ARRAY_CONTAINS_ANY:    QueryOps.ArrayContainsAny,
+	pb.StructuredQuery_FieldFilter_NOT_IN:                QueryOps.NotIn,
+}
+
+// entityFilterOf converts a serialized filter back into the fs.EntityFilter it was built from.
+func entityFilterOf(f *pb.StructuredQuery_Filter) (fs.EntityFilter, error) {
+	switch ft := f.GetFilterType().(type) {
+	case *pb.StructuredQuery_Filter_CompositeFilter:
+		filters := make([]fs.EntityFilter, 0, len(ft.CompositeFilter.GetFilters()))
+		for _, cf := range ft.CompositeFilter.GetFilters() {
+			ef, err := entityFilterOf(cf)
+			if err != nil {
+				return nil, err
+			}
+			filters = append(filters, ef)
+		}
+		if ft.CompositeFilter.GetOp() == pb.StructuredQuery_CompositeFilter_OR {
+			return fs.OrFilter{Filters: filters}, nil
+		}
+		return fs.AndFilter{Filters: filters}, nil
+	case *pb.StructuredQuery_Filter_FieldFilter:
+		op, ok := fieldFilterOps[ft.FieldFilter.GetOp()]
+		if !ok {
+			return nil, errorx.IllegalArgument.New("unsupported operator %s", ft.FieldFilter.GetOp())
+		}
+		return fs.PropertyPathFilter{
+			Path:     parseFieldPath(ft.FieldFilter.GetField().GetFieldPath()),
+			Operator: op.String(),
+			Value:    valueOf(ft.FieldFilter.GetValue()),
+		}, nil
+	case *pb.StructuredQuery_Filter_UnaryFilter:
+		path := parseFieldPath(ft.UnaryFilter.GetField().GetFieldPath())
+		switch ft.UnaryFilter.GetOp() {
+		case pb.StructuredQuery_UnaryFilter_IS_NULL:
+			return fs.PropertyPathFilter{Path: path, Operator: QueryOps.Equals.String()}, nil
+		case pb.StructuredQuery_UnaryFilter_IS_NOT_NULL:
+			return fs.PropertyPathFilter{Path: path, Operator: QueryOps.NotEquals.String()}, nil
+		case pb.StructuredQuery_UnaryFilter_IS_NAN:
+			return fs.PropertyPathFilter{Path: path, Operator: QueryOps.Equals.String(), Value: math.NaN()}, nil
+		case pb.StructuredQuery_UnaryFilter_IS_NOT_NAN:
+			return fs.PropertyPathFilter{Path: path, Operator: QueryOps.NotEquals.String(), Value: math.NaN()}, nil
+		}
+	}
+	return nil, errorx.IllegalArgument.New("unsupported filter %v", f)
+}
+
+func valuesOf(values []*pb.Value) []any {
+	result := make([]any, 0, len(values))
+	for _, v := range values {
+		result = append(result, valueOf(v))
+	}
+	return result
+}
+
+// valueOf converts a serialized value into the value DocumentSnapshot.Data would return for it.
+func valueOf(v *pb.Value) any {
+	switch vt := v.GetValueType().(type) {
+	case *pb.Value_BooleanValue:
+		return vt.BooleanValue
+	case *pb.Value_IntegerValue:
+		return vt.IntegerValue
+	case *pb.Value_DoubleValue:
+		return vt.DoubleValue
+	case *pb.Value_TimestampValue:
+		return vt.TimestampValue.AsTime()
+	case *pb.Value_StringValue:
+		return vt.StringValue
+	case *pb.Value_BytesValue:
+		return vt.BytesValue
+	case *pb.Value_ReferenceValue:
+		return &fs.DocumentRef{Path: vt.ReferenceValue, ID: vt.ReferenceValue[strings.LastIndex(vt.ReferenceValue, "/")+1:]}
+	case *pb.Value_GeoPointValue:
+		return vt.GeoPointValue
+	case *pb.Value_ArrayValue:
+		return valuesOf(vt.ArrayValue.GetValues())
+	case *pb.Value_MapValue:
+		m := make(map[string]any, len(vt.MapValue.GetFields()))
+		for k, fv := range vt.MapValue.GetFields() {
+			m[k] = valueOf(fv)
+		}
+		return m
+	default:
+		return nil
+	}
+}
+
+// normalize converts a value given to a query into one of the types values are stored as, other types
+// are converted through JSON like the documents of a CollectionStore.
+func normalize(v any) any {
+	switch vt := v.(type) {
+	case nil, bool, string, []byte, time.Time, *fs.DocumentRef, *latlng.LatLng, *fs.DocumentSnapshot:
+		return v
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32:
+		return reflect.ValueOf(v).Convert(reflect.TypeOf(float64(0))).Float()
+	case float64:
+		return vt
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array {
+		values := make([]any, 0, rv.Len())
+		for i := 0; i < rv.Len(); i++ {
+			values = append(values, normalize(rv.Index(i).Interface()))
+		}
+		return values
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	var jv any
+	if err = json.Unmarshal(b, &jv); err != nil {
+		return v
+	}
+	return jv
+}
+
+// documentIDOf is the value a fs.DocumentID filter or cursor compares, the ID of the document.
+func documentIDOf(v any) any {
+	switch vt := v.(type) {
+	case *fs.DocumentRef:
+		return vt.ID
+	case string:
+		return vt[strings.LastIndex(vt, "/")+1:]
+	case []any:
+		names := make([]any, 0, len(vt))
+		for _, e := range vt {
+			names = append(names, documentIDOf(e))
+		}
+		return names
+	default:
+		return v
+	}
+}
+
+func isDocumentID(path fs.FieldPath) bool {
+	return len(path) == 1 && path[0] == fs.DocumentID
+}
+
+// lookup returns the value at path in the document, false if the field does not exist.
+func lookup(doc memoryDocument, path fs.FieldPath) (any, bool) {
+	if isDocumentID(path) {
+		return doc.id, true
+	}
+	var v any = doc.data
+	for _, segment := range path {
+		m, ok := v.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		if v, ok = m[segment]; !ok {
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+// typeOrder is the position of the type of v in the Firestore ordering of values of different types.
+func typeOrder(v any) int {
+	switch v.(type) {
+	case nil:
+		return 0
+	case bool:
+		return 1
+	case float64, int64:
+		return 2
+	case time.Time:
+		return 3
+	case string:
+		return 4
+	case []byte:
+		return 5
+	case *fs.DocumentRef:
+		return 6
+	case *latlng.LatLng:
+		return 7
+	case []any:
+		return 8
+	case map[string]any:
+		return 9
+	default:
+		return 10
+	}
+}
+
+func toFloat(v any) float64 {
+	if i, ok := v.(int64); ok {
+		return float64(i)
+	}
+	return v.(float64)
+}
+
+// compareValues orders values the way Firestore does, first by type and then by value.
+// NaN is equal to NaN and before every other number.
+func compareValues(a any, b any) int {
+	if c := cmp.Compare(typeOrder(a), typeOrder(b)); c != 0 {
+		return c
+	}
+	switch av := a.(type) {
+	case bool:
+		bv := b.(bool)
+		if av == bv {
+			return 0
+		} else if !av {
+			return -1
+		}
+		return 1
+	case float64, int64:
+		return cmp.Compare(toFloat(a), toFloat(b))
+	case time.Time:
+		return av.Compare(b.(time.Time))
+	case string:
+		return strings.Compare(av, b.(string))
+	case []byte:
+		return bytes.Compare(av, b.([]byte))
+	case *fs.DocumentRef:
+		return strings.Compare(av.Path, b.(*fs.DocumentRef).Path)
+	case *latlng.LatLng:
+		bv := b.(*latlng.LatLng)
+		if c := cmp.Compare(av.GetLatitude(), bv.GetLatitude()); c != 0 {
+			return c
+		}
+		return cmp.Compare(av.GetLongitude(), bv.GetLongitude())
+	case []any:
+		return slices.CompareFunc(av, b.([]any), compareValues)
+	case map[string]any:
+		bv := b.(map[string]any)
+		ak, bk := slices.Sorted(maps.Keys(av)), slices.Sorted(maps.Keys(bv))
+		for i := 0; i < len(ak) && i < len(bk); i++ {
+			if c := strings.Compare(ak[i], bk[i]); c != 0 {
+				return c
+			}
+			if c := compareValues(av[ak[i]], bv[bk[i]]); c != 0 {
+				return c
+			}
+		}
+		return cmp.Compare(len(ak), len(bk))
+	default:
+		return 0
+	}
+}
+
+func isNaN(v any) bool {
+	f, ok := v.(float64)
+	return ok && math.IsNaN(f)
+}
+
+// matches reports if the document matches the filter.
+func matches(filter fs.EntityFilter, doc memoryDocument) bool {
+	switch f := filter.(type) {
+	case nil:
+		return true
+	case fs.AndFilter:
+		for _, ef := range f.Filters {
+			if !matches(ef, doc) {
+				return false
+			}
+		}
+		return true
+	case fs.OrFilter:
+		for _, ef := range f.Filters {
+			if matches(ef, doc) {
+				return true
+			}
+		}
+		return false
+	case fs.PropertyFilter:
+		return matchesField(doc, splitPath(f.Path), QueryOp(f.Operator), f.Value)
+	case fs.PropertyPathFilter:
+		return matchesField(doc, f.Path, QueryOp(f.Operator), f.Value)
+	default:
+		return false
+	}
+}
+
+// matchesField evaluates a condition on a single field, a document that does not have the field never matches.
+func matchesField(doc memoryDocument, path fs.FieldPath, op QueryOp, value any) bool {
+	v, ok := lookup(doc, path)
+	if !ok {
+		return false
+	}
+	value = normalize(value)
+	if isDocumentID(path) {
+		value = documentIDOf(value)
+	}
+	equals := func(a any) bool {
+		return compareValues(a, value) == 0
+	}
+	in := func(a any) bool {
+		values, _ := value.([]any)
+		return slices.ContainsFunc(values, func(e any) bool { return compareValues(a, e) == 0 })
+	}
+	switch op {
+	case QueryOps.Equals:
+		return equals(v)
+	case QueryOps.NotEquals:
+		return v != nil && !equals(v)
+	case QueryOps.LessThan, QueryOps.LessThanOrEqual, QueryOps.GreaterThan, QueryOps.GreaterThanOrEquals:
+		if value == nil || isNaN(value) || isNaN(v) || typeOrder(v) != typeOrder(value) {
+			return false
+		}
+		c := compareValues(v, value)
+		switch op {
+		case QueryOps.LessThan:
+			return c < 0
+		case QueryOps.LessThanOrEqual:
+			return c <= 0
+		case QueryOps.GreaterThan:
+			return c > 0
+		default:
+			return c >= 0
+		}
+	case QueryOps.ArrayContains:
+		array, _ := v.([]any)
+		return slices.ContainsFunc(array, equals)
+	case QueryOps.ArrayContainsAny:
+		array, _ := v.([]any)
+		return slices.ContainsFunc(array, in)
+	case QueryOps.In:
+		return in(v)
+	case QueryOps.NotIn:
+		return v != nil && !in(v)
+	default:
+		return false
+	}
+}
+
+// compareAt compares two documents on the orders.
+func compareAt(orders []memoryOrder, a memoryDocument, b memoryDocument) int {
+	for _, o := range orders {
+		av, _ := lookup(a, o.path)
+		bv, _ := lookup(b, o.path)
+		c := compareValues(av, bv)
+		if o.direction == fs.Desc {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// compareCursor compares a document with the values of a cursor, which are the values of the first orders.
+func compareCursor(orders []memoryOrder, doc memoryDocument, cursor []any) (int, error) {
+	if len(cursor) > len(orders) {
+		return 0, errorx.IllegalArgument.New("cursor has %d values but the query only has %d orders", len(cursor), len(orders))
+	}
+	for i, cv := range cursor {
+		if _, ok := cv.(*fs.DocumentSnapshot); ok {
+			return 0, errorx.IllegalArgument.New("a DocumentSnapshot cursor is not supported in memory, use field values")
+		}
+		cv = normalize(cv)
+		if isDocumentID(orders[i].path) {
+			cv = documentIDOf(cv)
+		}
+		v, _ := lookup(doc, orders[i].path)
+		c := compareValues(v, cv)
+		if orders[i].direction == fs.Desc {
+			c = -c
+		}
+		if c != 0 {
+			return c, nil
+		}
+	}
+	return 0, nil
+}
+
+// run returns the documents that match the query in the order of the query.
+// Like Firestore, the document ID is the implicit last order and documents missing an OrderBy field are excluded.
+func (mq memoryQuery) run(docs []memoryDocument) ([]memoryDocument, error) {
+	orders := slices.Clone(mq.orders)
+	if len(orders) == 0 || !isDocumentID(orders[len(orders)-1].path) {
+		direction := fs.Asc
+		if len(orders) > 0 {
+			direction = orders[len(orders)-1].direction
+		}
+		orders = append(orders, memoryOrder{path: fs.FieldPath{fs.DocumentID}, direction: direction})
+	}
+
+	results := make([]memoryDocument, 0, len(docs))
+	for _, doc := range docs {
+		if !matches(mq.filter, doc) {
+			continue
+		}
+		ordered := true
+		for _, o := range orders {
+			if _, ok := lookup(doc, o.path); !ok {
+				ordered = false
+				break
+			}
+		}
+		if ordered {
+			results = append(results, doc)
+		}
+	}
+	slices.SortStableFunc(results, func(a memoryDocument, b memoryDocument) int {
+		return compareAt(orders, a, b)
+	})
+
+	bounded := make([]memoryDocument, 0, len(results))
+	for _, doc := range results {
+		keep := true
+		for _, bound := range []struct {
+			cursor []any
+			keep   func(c int) bool
+		}{
+			{mq.startAt, func(c int) bool { return c >= 0 }},
+			{mq.startAfter, func(c int) bool { return c > 0 }},
+			{mq.endAt, func(c int) bool { return c <= 0 }},
+			{mq.endBefore, func(c int) bool { return c < 0 }},
+		} {
+			if bound.cursor == nil {
+				continue
+			}
+			c, err := compareCursor(orders, doc, bound.cursor)
+			if err != nil {
+				return nil, err
+			}
+			keep = keep && bound.keep(c)
+		}
+		if keep {
+			bounded = append(bounded, doc)
+		}
+	}
+
+	bounded = bounded[min(mq.offset, len(bounded)):]
+	if mq.limit > 0 && len(bounded) > mq.limit {
+		if mq.toLast {
+			bounded = bounded[len(bounded)-mq.limit:]
+		} else {
+			bounded = bounded[:mq.limit]
+		}
+	}
+	return bounded, nil
+}
+
+// project returns the fields of data in the projection, all of them for a nil projection or All.
+func project(data map[string]any, projection *Projection) map[string]any {
+	if projection == nil || slices.Equal(projection.paths(), All.paths()) {
+		return data
+	}
+	projected := make(map[string]any)
+	for _, path := range projection.fieldPaths {
+		v, ok := lookup(memoryDocument{data: data}, path)
+		if !ok {
+			continue
+		}
+		m := projected
+		for _, segment := range path[:len(path)-1] {
+			next, ok := m[segment].(map[string]any)
+			if !ok {
+				next = make(map[string]any)
+				m[segment] = next
+			}
+			m = next
+		}
+		m[path[len(path)-1]] = v
+	}
+	return projected
+}
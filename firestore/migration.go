@@ -0,0 +1,435 @@
+package firestore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"sync/atomic"
+	"time"
+
+	fs "cloud.google.com/go/firestore"
+	"github.com/joomcode/errorx"
+	"golang.org/x/sync/errgroup"
+
+	errs "github.com/jarrodhroberson/ossgo/errors"
+	"github.com/jarrodhroberson/ossgo/seq"
+)
+
+// MIGRATIONS_COLLECTION is the default collection MigrationRunner records the progress of migrations in.
+const MIGRATIONS_COLLECTION = "_migrations"
+
+// MigrateFunc changes the fields of the document with id in place. It returns false if the document
+// does not need to be migrated, in which case it is not written.
+type MigrateFunc func(ctx context.Context, id string, data map[string]any) (bool, error)
+
+// Migration migrates every document of Collection. Version orders the migrations of a MigrationRunner,
+// a migration is applied once and must not change its Version after it has been applied.
+//
+// Migrate must be idempotent, a migration that is interrupted resumes from its last checkpoint and
+// the documents of the unfinished batch are migrated again.
+type Migration struct {
+	Version    int
+	Name       string
+	Collection string
+	Migrate    MigrateFunc
+}
+
+// TypedMigration creates a Migration that decodes each document into a T, through its json tags like
+// CollectionStore does, and writes back the top level fields of T that migrate changed when it returns true.
+// The other fields keep the values and Firestore types they have, fields that T does not have are kept and
+// the created_at, last_updated_at and deleted_at metadata is never changed.
+//
+// The encrypted fields of T are decrypted and encrypted with the KeyRing of WithKeyRing. When migrate returns
+// true the encrypted fields that are not encrypted with the current key are written too, so a migration that
+// always returns true re-encrypts the documents after a key rotation.
+//
+// Example usage:
+//
+//	split := firestore.TypedMigration[User](2, "split name", "users", func(ctx context.Context, id string, u *User) (bool, error) {
+//		if u.FirstName != "" {
+//			return false, nil
+//		}
+//		u.FirstName, u.LastName, _ = strings.Cut(u.Name, " ")
+//		return true, nil
+//	})
+//	reencrypt := firestore.TypedMigration[Patient](3, "rotate key", "patients", func(ctx context.Context, id string, p *Patient) (bool, error) {
+//		return true, nil
+//	}, firestore.WithKeyRing(keys))
+func TypedMigration[T any](version int, name string, collection string, migrate func(ctx context.Context, id string, t *T) (bool, error), options ...DocumentOption) Migration {
+	fe := newFieldEncryption[T](newDocumentOptions(options).keys)
+	return Migration{
+		Version:    version,
+		Name:       name,
+		Collection: collection,
+		Migrate: func(ctx context.Context, id string, data map[string]any) (bool, error) {
+			plain := maps.Clone(data)
+			if err := fe.decrypt(ctx, plain); err != nil {
+				return false, EncryptionError.Wrap(err, "could not decrypt document %s", id)
+			}
+			t, err := decodeFields[T](plain)
+			if err != nil {
+				return false, err
+			}
+			before, err := typedFields(t)
+			if err != nil {
+				return false, err
+			}
+			changed, err := migrate(ctx, id, t)
+			if err != nil || !changed {
+				return changed, err
+			}
+			after, err := typedFields(t)
+			if err != nil {
+				return false, errorx.Decorate(err, "could not encode migrated document %s", id)
+			}
+			set, deleted := migratedFields(before, after)
+			stale, err := fe.stale(ctx, data)
+			if err != nil {
+				return false, err
+			}
+			for _, field := range stale {
+				if v, ok := after[field]; ok {
+					set[field] = v
+				}
+			}
+			if err = fe.encrypt(ctx, set); err != nil {
+				return false, err
+			}
+			maps.Copy(data, set)
+			for _, field := range deleted {
+				delete(data, field)
+			}
+			return true, nil
+		},
+	}
+}
+
+// typedFields returns the top level fields of t as CollectionStore writes them, through its json tags.
+func typedFields[T any](t *T) (map[string]any, error) {
+	b, err := json.Marshal(t)
+	if err != nil {
+		return nil, errs.MarshalError.Wrap(err, "could not marshal %T", t)
+	}
+	fields := make(map[string]any)
+	if err = json.Unmarshal(b, &fields); err != nil {
+		return nil, errs.UnMarshalError.Wrap(err, "could not unmarshal %T", t)
+	}
+	return fields, nil
+}
+
+// migratedFields returns the fields that are different in after than in before, to be set, and the fields
+// that are no longer in after, like an omitempty field that was cleared, to be deleted. The temporal fields
+// are never set or deleted.
+func migratedFields(before map[string]any, after map[string]any) (map[string]any, []string) {
+	set := make(map[string]any)
+	for k, v := range after {
+		if slices.Contains(temporalFields, k) {
+			continue
+		}
+		if old, ok := before[k]; !ok || !reflect.DeepEqual(old, v) {
+			set[k] = v
+		}
+	}
+	deleted := make([]string, 0)
+	for k := range before {
+		if _, ok := after[k]; !ok && !slices.Contains(temporalFields, k) {
+			deleted = append(deleted, k)
+		}
+	}
+	return set, deleted
+}
+
+// MigrationResult is what a migration did, or would have done in a dry run.
+// Scanned and Migrated include the documents of previous runs that were interrupted.
+type MigrationResult struct {
+	Version  int
+	Name     string
+	Scanned  int
+	Migrated int
+	DryRun   bool
+}
+
+// migrationRecord is the document MigrationRunner keeps for each migration.
+// Cursor is the ID of the last document of the last batch that was written.
+type migrationRecord struct {
+	Version    int       `firestore:"version"`
+	Name       string    `firestore:"name"`
+	Collection string    `firestore:"collection"`
+	Cursor     string    `firestore:"cursor"`
+	Scanned    int       `firestore:"scanned"`
+	Migrated   int       `firestore:"migrated"`
+	Applied    bool      `firestore:"applied"`
+	StartedAt  time.Time `firestore:"started_at"`
+	AppliedAt  time.Time `firestore:"applied_at,omitempty"`
+}
+
+type migrationOptions struct {
+	collection  string
+	dryRun      bool
+	batchSize   int
+	concurrency int
+}
+
+// MigrationOption configures a MigrationRunner.
+type MigrationOption func(o *migrationOptions)
+
+// DryRun runs the migrations without writing the documents or recording any progress.
+// Every pending migration runs against the documents as they are, not as the previous migrations would leave them.
+func DryRun() MigrationOption {
+	return func(o *migrationOptions) {
+		o.dryRun = true
+	}
+}
+
+// WithMigrationsCollection records the progress of the migrations in collection instead of MIGRATIONS_COLLECTION.
+func WithMigrationsCollection(collection string) MigrationOption {
+	return func(o *migrationOptions) {
+		o.collection = collection
+	}
+}
+
+// WithBatchSize sets how many documents are migrated between checkpoints, the default is MAX_BULK_WRITE_SIZE.
+func WithBatchSize(n int) MigrationOption {
+	return func(o *migrationOptions) {
+		o.batchSize = n
+	}
+}
+
+// WithConcurrency sets how many documents of a batch are migrated at the same time, the default is 8.
+func WithConcurrency(n int) MigrationOption {
+	return func(o *migrationOptions) {
+		o.concurrency = n
+	}
+}
+
+// MigrationRunner applies registered migrations in Version order and records their progress in a
+// metadata collection, so each migration is applied once and an interrupted migration resumes from
+// its last checkpoint. Documents are read in document ID order in batches with DocumentIteratorToSeqErr
+// and seq.ChunkErr, migrated concurrently and written with a BulkWriter.
+//
+// A document is only written if it has not been written since it was read, otherwise the migration
+// stops with a ConflictError and resumes from the last checkpoint when it runs again.
+// Only one MigrationRunner should run against a database at a time.
+//
+// Example usage:
+//
+//	runner := firestore.NewMigrationRunner(client, firestore.WithConcurrency(16))
+//	if err := runner.Register(addCountry, split); err != nil {
+//		return err
+//	}
+//	results, err := runner.Run(ctx)
+type MigrationRunner struct {
+	client     *fs.Client
+	migrations map[int]Migration
+	options    migrationOptions
+}
+
+// NewMigrationRunner creates a MigrationRunner without migrations.
+func NewMigrationRunner(client *fs.Client, options ...MigrationOption) *MigrationRunner {
+	o := migrationOptions{collection: MIGRATIONS_COLLECTION, batchSize: MAX_BULK_WRITE_SIZE, concurrency: 8}
+	for _, option := range options {
+		option(&o)
+	}
+	return &MigrationRunner{client: client, migrations: make(map[int]Migration), options: o}
+}
+
+// Register adds migrations to the runner, a Version must be positive and can only be registered once.
+func (r *MigrationRunner) Register(migrations ...Migration) error {
+	for _, m := range migrations {
+		if m.Version < 1 {
+			return MigrationError.New("migration %q version %d must be >= 1", m.Name, m.Version)
+		}
+		if m.Collection == "" || m.Migrate == nil {
+			return MigrationError.New("migration %d %q must have a collection and a migrate function", m.Version, m.Name)
+		}
+		if existing, ok := r.migrations[m.Version]; ok {
+			return MigrationError.New("migration %d %q is already registered as %q", m.Version, m.Name, existing.Name)
+		}
+		r.migrations[m.Version] = m
+	}
+	return nil
+}
+
+// migrationID is the ID of the record of a migration, zero padded so the records sort by version.
+func migrationID(version int) string {
+	return fmt.Sprintf("%010d", version)
+}
+
+func (r *MigrationRunner) record(ctx context.Context, m Migration) (migrationRecord, error) {
+	rec := migrationRecord{Version: m.Version, Name: m.Name, Collection: m.Collection}
+	dss, err := r.client.Collection(r.options.collection).Doc(migrationID(m.Version)).Get(ctx)
+	if IsNotFound(err) {
+		return rec, nil
+	}
+	if err != nil {
+		return rec, errs.NotReadError.Wrap(err, "could not read the record of migration %d", m.Version)
+	}
+	if err = dss.DataTo(&rec); err != nil {
+		return rec, errs.UnMarshalError.Wrap(err, "could not decode the record of migration %d", m.Version)
+	}
+	return rec, nil
+}
+
+func (r *MigrationRunner) sorted() []Migration {
+	return slices.SortedFunc(maps.Values(r.migrations), func(a Migration, b Migration) int {
+		return a.Version - b.Version
+	})
+}
+
+// Pending returns the migrations that have not been applied, in Version order.
+func (r *MigrationRunner) Pending(ctx context.Context) ([]Migration, error) {
+	pending := make([]Migration, 0, len(r.migrations))
+	for _, m := range r.sorted() {
+		rec, err := r.record(ctx, m)
+		if err != nil {
+			return nil, err
+		}
+		if !rec.Applied {
+			pending = append(pending, m)
+		}
+	}
+	return pending, nil
+}
+
+// Run applies the pending migrations in Version order and stops at the first one that fails.
+// It returns the results of the migrations that ran, including the one that failed.
+func (r *MigrationRunner) Run(ctx context.Context) ([]MigrationResult, error) {
+	if r.options.batchSize < 1 || r.options.concurrency < 1 {
+		return nil, errs.MinSizeExceededError.New("batch size %d and concurrency %d must be >= 1", r.options.batchSize, r.options.concurrency)
+	}
+	pending, err := r.Pending(ctx)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]MigrationResult, 0, len(pending))
+	for _, m := range pending {
+		result, err := r.run(ctx, m)
+		results = append(results, result)
+		if err != nil {
+			return results, MigrationError.Wrap(err, "migration %d %q failed", m.Version, m.Name)
+		}
+	}
+	return results, nil
+}
+
+func (r *MigrationRunner) run(ctx context.Context, m Migration) (MigrationResult, error) {
+	rec, err := r.record(ctx, m)
+	if err != nil {
+		return MigrationResult{Version: m.Version, Name: m.Name, DryRun: r.options.dryRun}, err
+	}
+	result := MigrationResult{Version: m.Version, Name: m.Name, Scanned: rec.Scanned, Migrated: rec.Migrated, DryRun: r.options.dryRun}
+	ref := r.client.Collection(r.options.collection).Doc(migrationID(m.Version))
+	checkpoint := func() error {
+		if r.options.dryRun {
+			return nil
+		}
+		rec.Scanned, rec.Migrated = result.Scanned, result.Migrated
+		if _, err := ref.Set(ctx, rec); err != nil {
+			return errs.NotWrittenError.Wrap(err, "could not record the progress of migration %d", m.Version)
+		}
+		return nil
+	}
+	if rec.StartedAt.IsZero() {
+		rec.StartedAt = time.Now()
+		if err = checkpoint(); err != nil {
+			return result, err
+		}
+	}
+
+	var bw *fs.BulkWriter
+	if !r.options.dryRun {
+		bw = r.client.BulkWriter(ctx)
+		defer bw.End()
+	}
+	collection := r.client.Collection(m.Collection)
+	q := collection.OrderBy(fs.DocumentID, fs.Asc)
+	if rec.Cursor != "" {
+		q = q.StartAfter(collection.Doc(rec.Cursor))
+	}
+	docs := DocumentIteratorToSeqErr(q.Documents(ctx))
+	for chunk, err := range seq.ChunkErr(ctx, docs, r.options.batchSize, seq.FAIL_ON_FIRST_ERROR) {
+		if err != nil {
+			return result, err
+		}
+		batch := slices.Collect(chunk)
+		migrated, err := r.migrateBatch(ctx, m, bw, batch)
+		if err != nil {
+			return result, err
+		}
+		result.Scanned += len(batch)
+		result.Migrated += migrated
+		rec.Cursor = batch[len(batch)-1].Ref.ID
+		if err = checkpoint(); err != nil {
+			return result, err
+		}
+	}
+	rec.Applied = true
+	rec.AppliedAt = time.Now()
+	return result, checkpoint()
+}
+
+// migrationUpdates returns the updates that turn a document with fields into data,
+// the fields that are no longer in data are deleted.
+func migrationUpdates(fields []string, data map[string]any) []fs.Update {
+	updates := make([]fs.Update, 0, len(data)+len(fields))
+	for _, k := range slices.Sorted(maps.Keys(data)) {
+		updates = append(updates, fs.Update{FieldPath: fs.FieldPath{k}, Value: data[k]})
+	}
+	for _, k := range fields {
+		if _, ok := data[k]; !ok {
+			updates = append(updates, fs.Update{FieldPath: fs.FieldPath{k}, Value: fs.Delete})
+		}
+	}
+	return updates
+}
+
+// migrateBatch migrates the documents of a batch on up to concurrency goroutines and waits for their writes,
+// bw is nil in a dry run. It returns the number of documents that were migrated.
+func (r *MigrationRunner) migrateBatch(ctx context.Context, m Migration, bw *fs.BulkWriter, batch []*fs.DocumentSnapshot) (int, error) {
+	var migrated atomic.Int64
+	jobs := make([]*fs.BulkWriterJob, len(batch))
+	errgp, gctx := errgroup.WithContext(ctx)
+	errgp.SetLimit(r.options.concurrency)
+	for i, dss := range batch {
+		errgp.Go(func() error {
+			data := dss.Data()
+			fields := slices.Collect(maps.Keys(data))
+			changed, err := m.Migrate(gctx, dss.Ref.ID, data)
+			if err != nil {
+				return MigrationError.Wrap(err, "could not migrate document %s", dss.Ref.ID)
+			}
+			if !changed {
+				return nil
+			}
+			migrated.Add(1)
+			updates := migrationUpdates(fields, data)
+			if bw == nil || len(updates) == 0 {
+				return nil
+			}
+			jobs[i], err = bw.Update(dss.Ref, updates, fs.LastUpdateTime(dss.UpdateTime))
+			if err != nil {
+				return BulkWriterError.Wrap(err, "error migrating document %s", dss.Ref.ID)
+			}
+			return nil
+		})
+	}
+	err := errgp.Wait()
+	if bw != nil {
+		bw.Flush()
+	}
+	if err != nil {
+		return 0, err
+	}
+	for i, job := range jobs {
+		if job == nil {
+			continue
+		}
+		if _, err = job.Results(); err != nil {
+			return 0, conflictOrErr(err, batch[i].Ref.ID)
+		}
+	}
+	return int(migrated.Load()), nil
+}
@@ -0,0 +1,128 @@
+package firestore
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	fs "cloud.google.com/go/firestore"
+	"github.com/joomcode/errorx"
+)
+
+func noopMigrate(ctx context.Context, id string, data map[string]any) (bool, error) {
+	return false, nil
+}
+
+func TestMigrationRunnerRegister(t *testing.T) {
+	r := NewMigrationRunner(nil)
+	if err := r.Register(Migration{Version: 2, Name: "b", Collection: "users", Migrate: noopMigrate}, Migration{Version: 1, Name: "a", Collection: "users", Migrate: noopMigrate}); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	tests := []struct {
+		name      string
+		migration Migration
+	}{
+		{"duplicate version", Migration{Version: 1, Name: "c", Collection: "users", Migrate: noopMigrate}},
+		{"zero version", Migration{Name: "d", Collection: "users", Migrate: noopMigrate}},
+		{"no collection", Migration{Version: 3, Name: "e", Migrate: noopMigrate}},
+		{"no migrate", Migration{Version: 4, Name: "f", Collection: "users"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := r.Register(tt.migration); !errorx.IsOfType(err, MigrationError) {
+				t.Errorf("Register() error = %v, want MigrationError", err)
+			}
+		})
+	}
+	sorted := r.sorted()
+	if len(sorted) != 2 || sorted[0].Version != 1 || sorted[1].Version != 2 {
+		t.Errorf("sorted() = %v, want versions 1, 2", sorted)
+	}
+	if migrationID(2) >= migrationID(10) {
+		t.Errorf("migrationID(2) = %s should sort before migrationID(10) = %s", migrationID(2), migrationID(10))
+	}
+}
+
+func TestMigrationUpdates(t *testing.T) {
+	got := migrationUpdates([]string{"name", "a.b"}, map[string]any{"first": "Ann", "last": "Lee"})
+	want := []fs.Update{
+		{FieldPath: fs.FieldPath{"first"}, Value: "Ann"},
+		{FieldPath: fs.FieldPath{"last"}, Value: "Lee"},
+		{FieldPath: fs.FieldPath{"name"}, Value: fs.Delete},
+		{FieldPath: fs.FieldPath{"a.b"}, Value: fs.Delete},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("migrationUpdates() = %v, want %v", got, want)
+	}
+}
+
+func TestTypedMigration(t *testing.T) {
+	type user struct {
+		First string `json:"first"`
+		Last  string `json:"last"`
+	}
+	m := TypedMigration[user](1, "split", "users", func(ctx context.Context, id string, u *user) (bool, error) {
+		if u.First == "" {
+			return false, nil
+		}
+		u.Last = "Lee"
+		return true, nil
+	})
+
+	created := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	data := map[string]any{"first": "Ann", "name": "Ann Lee", "age": int64(36), CREATED_AT: created}
+	changed, err := m.Migrate(context.Background(), "u1", data)
+	if err != nil || !changed {
+		t.Fatalf("Migrate() = %v, %v, want true", changed, err)
+	}
+	// only last changed, the fields user does not have and their types are kept
+	if want := map[string]any{"first": "Ann", "last": "Lee", "name": "Ann Lee", "age": int64(36), CREATED_AT: created}; !reflect.DeepEqual(data, want) {
+		t.Errorf("Migrate() data = %v, want %v", data, want)
+	}
+
+	data = map[string]any{"name": "Ann Lee"}
+	if changed, err = m.Migrate(context.Background(), "u2", data); err != nil || changed {
+		t.Errorf("Migrate() = %v, %v, want false", changed, err)
+	}
+	if len(data) != 1 {
+		t.Errorf("Migrate() changed data of an unchanged document: %v", data)
+	}
+}
+
+func TestTypedMigrationReencrypts(t *testing.T) {
+	ctx := context.Background()
+	old := StaticKeyRing(map[int][]byte{1: []byte("key one")})
+	data, err := encodeDocument(ctx, newFieldEncryption[patient](old), &patient{ID: "p1", Name: "Ann", Email: "ann@example.com", Ward: 3})
+	if err != nil {
+		t.Fatalf("encodeDocument() = %v", err)
+	}
+	data[CREATED_AT] = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	rotated := StaticKeyRing(map[int][]byte{1: []byte("key one"), 2: []byte("key two")})
+	m := TypedMigration[patient](1, "rotate key", "patients", func(ctx context.Context, id string, p *patient) (bool, error) {
+		if p.Name != "Ann" {
+			t.Errorf("migrate() got %+v, want the decrypted patient", p)
+		}
+		return true, nil
+	}, WithKeyRing(rotated))
+	changed, err := m.Migrate(ctx, "p1", data)
+	if err != nil || !changed {
+		t.Fatalf("Migrate() = %v, %v, want true", changed, err)
+	}
+	for _, field := range []string{"name", "email"} {
+		if _, version, ok := encryptedValueOf(data[field]); !ok || version != 2 {
+			t.Errorf("Migrate() field %s = %v, want it encrypted with key version 2", field, data[field])
+		}
+	}
+	if data["ward"] != float64(3) || data[CREATED_AT] == nil {
+		t.Errorf("Migrate() changed the fields that are not encrypted: %v", data)
+	}
+
+	without := TypedMigration[patient](2, "no keys", "patients", func(ctx context.Context, id string, p *patient) (bool, error) {
+		return true, nil
+	})
+	if _, err = without.Migrate(ctx, "p1", data); !errorx.IsOfType(err, EncryptionError) {
+		t.Errorf("Migrate() of an encrypted document without a KeyRing = %v, want an EncryptionError", err)
+	}
+}
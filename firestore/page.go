@@ -0,0 +1,355 @@
+package firestore
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"iter"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	fs "cloud.google.com/go/firestore"
+	"github.com/joomcode/errorx"
+	"google.golang.org/genproto/googleapis/type/latlng"
+
+	errs "github.com/jarrodhroberson/ossgo/errors"
+)
+
+// ResultPage is one page of results from CollectionStore.Page.
+// NextPageToken is empty when there are no more results.
+type ResultPage[T any] struct {
+	Items         []*T   `json:"items"`
+	NextPageToken string `json:"next_page_token,omitempty"`
+}
+
+// pageCursor is the content of a page token, the values of the OrderBy fields and the ID of the last
+// document of the page, and a fingerprint of the query so a token can not be used with a different query.
+type pageCursor struct {
+	Query  string        `json:"q"`
+	Values []cursorValue `json:"v,omitempty"`
+	ID     string        `json:"id"`
+}
+
+// cursorValue is a field value tagged with its type so it decodes to the same Firestore type it was read as.
+type cursorValue struct {
+	Type  string `json:"t"`
+	Value string `json:"v,omitempty"`
+}
+
+func newCursorValue(v any) (cursorValue, error) {
+	switch v := v.(type) {
+	case nil:
+		return cursorValue{Type: "null"}, nil
+	case bool:
+		return cursorValue{Type: "bool", Value: strconv.FormatBool(v)}, nil
+	case string:
+		return cursorValue{Type: "string", Value: v}, nil
+	case int64:
+		return cursorValue{Type: "int", Value: strconv.FormatInt(v, 10)}, nil
+	case float64:
+		return cursorValue{Type: "float", Value: strconv.FormatFloat(v, 'g', -1, 64)}, nil
+	case time.Time:
+		return cursorValue{Type: "time", Value: v.Format(time.RFC3339Nano)}, nil
+	case []byte:
+		return cursorValue{Type: "bytes", Value: base64.RawURLEncoding.EncodeToString(v)}, nil
+	default:
+		return cursorValue{}, errorx.IllegalArgument.New("can not page on a field of type %T", v)
+	}
+}
+
+func (cv cursorValue) value() (any, error) {
+	switch cv.Type {
+	case "null":
+		return nil, nil
+	case "bool":
+		return strconv.ParseBool(cv.Value)
+	case "string":
+		return cv.Value, nil
+	case "int":
+		return strconv.ParseInt(cv.Value, 10, 64)
+	case "float":
+		return strconv.ParseFloat(cv.Value, 64)
+	case "time":
+		return time.Parse(time.RFC3339Nano, cv.Value)
+	case "bytes":
+		return base64.RawURLEncoding.DecodeString(cv.Value)
+	default:
+		return nil, InvalidPageTokenError.New("unknown cursor value type %q", cv.Type)
+	}
+}
+
+// signPageToken encodes the cursor as base64url JSON followed by its HMAC-SHA256 signature.
+func signPageToken(key []byte, cursor pageCursor) (string, error) {
+	b, err := json.Marshal(cursor)
+	if err != nil {
+		return "", errs.MarshalError.Wrap(err, "could not marshal page cursor")
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write(b)
+	return base64.RawURLEncoding.EncodeToString(b) + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil)), nil
+}
+
+// verifyPageToken checks the signature of the token before decoding the cursor.
+func verifyPageToken(key []byte, token string) (pageCursor, error) {
+	var cursor pageCursor
+	payload, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return cursor, InvalidPageTokenError.New("malformed page token")
+	}
+	b, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return cursor, InvalidPageTokenError.Wrap(err, "malformed page token")
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil {
+		return cursor, InvalidPageTokenError.Wrap(err, "malformed page token")
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write(b)
+	if !hmac.Equal(sig, mac.Sum(nil)) {
+		return cursor, InvalidPageTokenError.New("page token signature does not match")
+	}
+	if err = json.Unmarshal(b, &cursor); err != nil {
+		return cursor, InvalidPageTokenError.Wrap(err, "malformed page token")
+	}
+	return cursor, nil
+}
+
+// describeFilter renders a filter the same way every time, see describeValue.
+func describeFilter(entity fs.EntityFilter) string {
+	describeAll := func(op string, filters []fs.EntityFilter) string {
+		parts := make([]string, 0, len(filters))
+		for _, f := range filters {
+			parts = append(parts, describeFilter(f))
+		}
+		return op + "(" + strings.Join(parts, ",") + ")"
+	}
+	switch f := entity.(type) {
+	case fs.PropertyFilter:
+		return fmt.Sprintf("%s %s %s", f.Path, f.Operator, describeValue(reflect.ValueOf(f.Value)))
+	case fs.PropertyPathFilter:
+		return fmt.Sprintf("%s %s %s", f.Path, f.Operator, describeValue(reflect.ValueOf(f.Value)))
+	case fs.AndFilter:
+		return describeAll("and", f.Filters)
+	case fs.OrFilter:
+		return describeAll("or", f.Filters)
+	default:
+		return fmt.Sprintf("%#v", f)
+	}
+}
+
+// describeValue renders a filter value the same way every time it has the same content. Pointers are
+// followed instead of rendered as their address, document references are their path and maps are
+// ordered by key, all the way down through slices, maps and structs.
+func describeValue(v reflect.Value) string {
+	if !v.IsValid() {
+		return "nil"
+	}
+	if v.CanInterface() {
+		switch vt := v.Interface().(type) {
+		case *fs.DocumentRef:
+			if vt == nil {
+				return "ref:nil"
+			}
+			return "ref:" + vt.Path
+		case *latlng.LatLng:
+			return fmt.Sprintf("latlng:%v,%v", vt.GetLatitude(), vt.GetLongitude())
+		case time.Time:
+			return "time:" + vt.UTC().Format(time.RFC3339Nano)
+		case []byte:
+			return "bytes:" + base64.RawURLEncoding.EncodeToString(vt)
+		}
+	}
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return "nil"
+		}
+		return describeValue(v.Elem())
+	case reflect.Slice, reflect.Array:
+		parts := make([]string, 0, v.Len())
+		for i := range v.Len() {
+			parts = append(parts, describeValue(v.Index(i)))
+		}
+		return "[" + strings.Join(parts, ",") + "]"
+	case reflect.Map:
+		parts := make([]string, 0, v.Len())
+		for it := v.MapRange(); it.Next(); {
+			parts = append(parts, describeValue(it.Key())+":"+describeValue(it.Value()))
+		}
+		slices.Sort(parts)
+		return "{" + strings.Join(parts, ",") + "}"
+	case reflect.Struct:
+		parts := make([]string, 0, v.NumField())
+		for i := range v.NumField() {
+			parts = append(parts, v.Type().Field(i).Name+":"+describeValue(v.Field(i)))
+		}
+		return v.Type().String() + "{" + strings.Join(parts, ",") + "}"
+	case reflect.String:
+		return strconv.Quote(v.String())
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Type().String() + "(" + strconv.FormatInt(v.Int(), 10) + ")"
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Type().String() + "(" + strconv.FormatUint(v.Uint(), 10) + ")"
+	case reflect.Float32, reflect.Float64:
+		return v.Type().String() + "(" + strconv.FormatFloat(v.Float(), 'g', -1, 64) + ")"
+	default:
+		// channels, functions and complex numbers are not Firestore values
+		return v.Type().String()
+	}
+}
+
+// fingerprint identifies the collection, filters and orderings of the query, the parts a page token
+// depends on. Limits, cursors and projections are not part of it.
+func (q Query[T]) fingerprint() string {
+	h := sha256.New()
+	fmt.Fprintln(h, q.collection.Path)
+	for _, f := range q.filters {
+		fmt.Fprintln(h, describeFilter(f.entity))
+	}
+	for _, o := range q.orders {
+		fmt.Fprintln(h, o.field, o.direction)
+	}
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil)[:12])
+}
+
+// page runs one page of the query after the cursor in pageToken.
+//
+// The document ID is added as the last ordering unless q already orders by fs.DocumentID, so documents with
+// equal values of the OrderBy fields are always in the same order and the cursor points at exactly one document. The next page starts after that
+// document, so a token stays valid, and no document is skipped or repeated, when documents are inserted
+// before or after it. One extra document is read to know whether there is a next page.
+// Any Limit or start cursor of q is replaced, end cursors are kept. source runs the query.
+func page[T any](q Query[T], pageSize int, pageToken string, key []byte, source pageSource[T]) (*ResultPage[T], error) {
+	if pageSize < 1 {
+		return nil, errs.MinSizeExceededError.New("pageSize %d must be >= 1", pageSize)
+	}
+	if q.toLast {
+		return nil, errorx.IllegalArgument.New("a query paged with a page token can not use LimitToLast")
+	}
+	if q.collection == nil {
+		return nil, errs.MustNotBeNil.New("query has no collection")
+	}
+	// fields are the orderings the cursor holds the values of, the document ID is its own part of the cursor
+	fields := make([]order, 0, len(q.orders))
+	for _, o := range q.orders {
+		if o.field != fs.DocumentID {
+			fields = append(fields, o)
+		}
+	}
+	if len(fields) == len(q.orders) {
+		direction := fs.Asc
+		if len(q.orders) > 0 {
+			direction = q.orders[len(q.orders)-1].direction
+		}
+		q = q.OrderBy(fs.DocumentID, direction)
+	}
+	fingerprint := q.fingerprint()
+
+	q.startAt, q.startAfter = nil, nil
+	if pageToken != "" {
+		cursor, err := verifyPageToken(key, pageToken)
+		if err != nil {
+			return nil, err
+		}
+		if cursor.Query != fingerprint || len(cursor.Values) != len(fields) {
+			return nil, InvalidPageTokenError.New("page token is for a different query")
+		}
+		values := make([]any, 0, len(q.orders))
+		next := cursor.Values
+		for _, o := range q.orders {
+			if o.field == fs.DocumentID {
+				values = append(values, q.collection.Doc(cursor.ID))
+				continue
+			}
+			v, err := next[0].value()
+			if err != nil {
+				return nil, InvalidPageTokenError.Wrap(err, "malformed page token")
+			}
+			values, next = append(values, v), next[1:]
+		}
+		q = q.StartAfter(values...)
+	}
+
+	result := &ResultPage[T]{Items: make([]*T, 0, pageSize)}
+	var last pageResult[T]
+	for pr, err := range source(q.Limit(pageSize + 1)) {
+		if err != nil {
+			return nil, err
+		}
+		if len(result.Items) == pageSize {
+			cursor := pageCursor{Query: fingerprint, Values: make([]cursorValue, 0, len(fields)), ID: last.id}
+			for _, o := range fields {
+				v, err := last.dataAt(o.field)
+				if err != nil {
+					return nil, errs.NotReadError.Wrap(err, "could not read OrderBy field %s of document %s", o.field, last.id)
+				}
+				cv, err := newCursorValue(v)
+				if err != nil {
+					return nil, err
+				}
+				cursor.Values = append(cursor.Values, cv)
+			}
+			if result.NextPageToken, err = signPageToken(key, cursor); err != nil {
+				return nil, err
+			}
+			break
+		}
+		t, err := pr.value()
+		if err != nil {
+			return nil, err
+		}
+		result.Items = append(result.Items, t)
+		last = pr
+	}
+	return result, nil
+}
+
+// pageResult is a document read by page, with the values of its fields to build the cursor of the next page from.
+type pageResult[T any] struct {
+	id     string
+	dataAt func(field string) (any, error)
+	value  func() (*T, error)
+}
+
+// pageSource runs the query of a page and yields its results.
+type pageSource[T any] func(q Query[T]) iter.Seq2[pageResult[T], error]
+
+// firestorePageSource runs the query of a page against Firestore, decode decodes the documents.
+func firestorePageSource[T any](ctx context.Context, decode func(dss *fs.DocumentSnapshot) (*T, error)) pageSource[T] {
+	return func(q Query[T]) iter.Seq2[pageResult[T], error] {
+		return func(yield func(pageResult[T], error) bool) {
+			for dss, err := range q.DocumentSnapshots(ctx) {
+				var pr pageResult[T]
+				if err == nil {
+					pr = pageResult[T]{id: dss.Ref.ID, dataAt: dss.DataAt, value: func() (*T, error) {
+						return decode(dss)
+					}}
+				}
+				if !yield(pr, err) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// processPageTokenKey is the key page tokens are signed with when a store has no WithPageTokenKey.
+// It is generated once, so tokens are valid for every store of this process but not in other processes.
+var processPageTokenKey = sync.OnceValue(func() []byte {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		panic(errs.NotCreatedError.Wrap(err, "could not generate page token key"))
+	}
+	return key
+})
@@ -0,0 +1,157 @@
+package firestore
+
+import (
+	"iter"
+	"slices"
+	"testing"
+	"time"
+
+	fs "cloud.google.com/go/firestore"
+	"github.com/joomcode/errorx"
+)
+
+func TestPageToken(t *testing.T) {
+	key := []byte("secret")
+	when := time.Date(2024, 5, 1, 12, 30, 0, 123, time.UTC)
+	values := []any{nil, true, "name", int64(42), 1.5, when, []byte{1, 2}}
+	cursor := pageCursor{Query: "q", ID: "doc-1"}
+	for _, v := range values {
+		cv, err := newCursorValue(v)
+		if err != nil {
+			t.Fatalf("newCursorValue(%v) error = %v", v, err)
+		}
+		cursor.Values = append(cursor.Values, cv)
+	}
+	token, err := signPageToken(key, cursor)
+	if err != nil {
+		t.Fatalf("signPageToken() error = %v", err)
+	}
+
+	got, err := verifyPageToken(key, token)
+	if err != nil {
+		t.Fatalf("verifyPageToken() error = %v", err)
+	}
+	if got.ID != "doc-1" || len(got.Values) != len(values) {
+		t.Fatalf("verifyPageToken() = %+v, want %+v", got, cursor)
+	}
+	if v, _ := got.Values[5].value(); !v.(time.Time).Equal(when) {
+		t.Errorf("time cursor value = %v, want %v", v, when)
+	}
+	if v, _ := got.Values[3].value(); v != int64(42) {
+		t.Errorf("int cursor value = %#v, want int64(42)", v)
+	}
+
+	tests := []struct {
+		name  string
+		key   []byte
+		token string
+	}{
+		{name: "wrong key", key: []byte("other"), token: token},
+		{name: "tampered", key: key, token: "x" + token[1:]},
+		{name: "malformed", key: key, token: "not-a-token"},
+	}
+	for _, tt := range tests {
+		if _, err := verifyPageToken(tt.key, tt.token); !errorx.IsOfType(err, InvalidPageTokenError) {
+			t.Errorf("verifyPageToken() %s error = %v, want InvalidPageTokenError", tt.name, err)
+		}
+	}
+
+	if _, err := newCursorValue(map[string]any{}); err == nil {
+		t.Error("newCursorValue() of a map error = nil")
+	}
+}
+
+func TestQueryFingerprint(t *testing.T) {
+	type user struct{}
+	users := &fs.CollectionRef{Path: "projects/p/databases/d/documents/users"}
+	base := NewQuery[user](users).Where("age", QueryOps.GreaterThan, 21).OrderBy("age", fs.Asc)
+	if base.fingerprint() != base.Limit(10).StartAfter(int64(30)).fingerprint() {
+		t.Error("fingerprint() must not depend on limits or cursors")
+	}
+	if base.fingerprint() == base.Where("age", QueryOps.LessThan, 65).fingerprint() {
+		t.Error("fingerprint() must depend on the filters")
+	}
+	if base.fingerprint() == base.OrderBy("name", fs.Desc).fingerprint() {
+		t.Error("fingerprint() must depend on the orderings")
+	}
+
+	// values with the same content have the same fingerprint, whatever the addresses of their pointers
+	type team struct {
+		Lead *fs.DocumentRef
+		Tags map[string]*int
+	}
+	values := func() any {
+		one, two := 1, 2
+		return []any{
+			[]*fs.DocumentRef{users.Doc("a"), users.Doc("b")},
+			&team{Lead: users.Doc("a"), Tags: map[string]*int{"x": &one, "y": &two}},
+		}
+	}
+	filtered := func() Query[user] {
+		return NewQuery[user](users).Where("manager", QueryOps.In, values())
+	}
+	if filtered().fingerprint() != filtered().fingerprint() {
+		t.Errorf("fingerprint() depends on the addresses of the pointers in %s", describeFilter(filtered().filters[0].entity))
+	}
+	if filtered().fingerprint() == NewQuery[user](users).Where("manager", QueryOps.In, []*fs.DocumentRef{users.Doc("c")}).fingerprint() {
+		t.Error("fingerprint() must depend on the paths of the references")
+	}
+}
+
+func TestPageTokenKeyIsSharedByStores(t *testing.T) {
+	keyer := func(d *conformanceDoc) string { return d.ID }
+	first := NewMemoryCollectionStore[conformanceDoc]("people", keyer)
+	second := NewMemoryCollectionStore[conformanceDoc]("people", keyer)
+	for _, store := range []*MemoryCollectionStore[conformanceDoc]{first, second} {
+		if err := store.BulkStore(slices.Values(conformanceDocs()), FAIL_ON_FIRST_ERROR); err != nil {
+			t.Fatalf("BulkStore() = %v", err)
+		}
+	}
+	build := func(q Query[conformanceDoc]) Query[conformanceDoc] { return q }
+	page, err := first.Page(t.Context(), build, 2, "")
+	if err != nil {
+		t.Fatalf("Page() = %v", err)
+	}
+	if _, err = second.Page(t.Context(), build, 2, page.NextPageToken); err != nil {
+		t.Errorf("Page() of another store without WithPageTokenKey rejected the token: %v", err)
+	}
+}
+
+func TestPageOrderedByDocumentID(t *testing.T) {
+	store := NewMemoryCollectionStore[conformanceDoc]("people", conformanceKeyer)
+	if err := store.BulkStore(slices.Values(conformanceDocs()), FAIL_ON_FIRST_ERROR); err != nil {
+		t.Fatalf("BulkStore() = %v", err)
+	}
+	build := func(q Query[conformanceDoc]) Query[conformanceDoc] {
+		return q.Where("age", QueryOps.GreaterThan, 0).OrderBy(fs.DocumentID, fs.Desc)
+	}
+
+	got := make([]string, 0)
+	token := ""
+	for range 5 {
+		page, err := store.Page(t.Context(), build, 2, token)
+		if err != nil {
+			t.Fatalf("Page() = %v", err)
+		}
+		got = append(got, ids(page.Items)...)
+		if token = page.NextPageToken; token == "" {
+			break
+		}
+	}
+	if want := []string{"e", "d", "c", "b", "a"}; !slices.Equal(got, want) {
+		t.Errorf("Page() by document ID = %v, want %v", got, want)
+	}
+
+	// the ordering by document ID is not added a second time
+	var orders []order
+	source := func(q Query[conformanceDoc]) iter.Seq2[pageResult[conformanceDoc], error] {
+		orders = q.orders
+		return func(yield func(pageResult[conformanceDoc], error) bool) {}
+	}
+	if _, err := page(build(NewQuery[conformanceDoc](store.collection)), 2, "", processPageTokenKey(), source); err != nil {
+		t.Fatalf("page() = %v", err)
+	}
+	if len(orders) != 1 || orders[0].field != fs.DocumentID {
+		t.Errorf("page() ordered by %v, want only the document ID", orders)
+	}
+}
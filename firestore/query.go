@@ -2,170 +2,250 @@ package firestore
 
 import (
 	"context"
-	"time"
+	"iter"
+	"slices"
 
 	fs "cloud.google.com/go/firestore"
-)
-
-// Queries interface defines the methods for creating query instances.
-type Queries interface {
-	ByKey(key string) *byKeyQuery
-	BeforeTime(field string, before time.Time) *timeBeforeQuery
-	AfterTime(field string, after time.Time) *timeAfterQuery
-	TimeBetween(field string, start time.Time, end time.Time) *timeBetweenQuery
-	TimeEquals(field string, value time.Time) *timeEqualsQuery
-	StringEquals(field string, value string) *stringEqualsQuery
-	IntEquals(field string, value int) *intEqualsQuery
-	BoolEquals(field string, value bool) *boolEqualsQuery
-}
-
-// Query interface defines the Execute method for all query types.
-type Query interface {
-	Execute(ctx context.Context) (*fs.DocumentIterator, error)
-}
-
-// byKeyQuery represents a query to retrieve a document by key (document ID).
-type byKeyQuery struct {
-	collection *fs.CollectionRef // Firestore collection name
-	key        string            // Firestore document ID
-}
-
-// Execute executes the byKeyQuery against Firestore.
-func (q *byKeyQuery) Execute(ctx context.Context) (*fs.DocumentIterator, error) {
-	return q.collection.Limit(1).Documents(ctx), nil
-}
-
-// timeBeforeQuery represents a query to retrieve documents before a time.
-type timeBeforeQuery struct {
-	collection *fs.CollectionRef // Firestore collection name
-	field      string            // Field name to query
-	before     time.Time         // Time before (exclusive)
-}
-
-// Execute executes the timeBeforeQuery against Firestore.
-func (q *timeBeforeQuery) Execute(ctx context.Context) (*fs.DocumentIterator, error) {
-	return q.collection.Where(q.field, "<", q.before).Documents(ctx), nil
-}
-
-// timeAfterQuery represents a query to retrieve documents after a time.
-type timeAfterQuery struct {
-	collection *fs.CollectionRef // Firestore collection name
-	field      string            // Field name to query
-	after      time.Time         // Time after (exclusive)
-}
-
-// Execute executes the timeAfterQuery against Firestore.
-func (q *timeAfterQuery) Execute(ctx context.Context) (*fs.DocumentIterator, error) {
-	return q.collection.Where(q.field, ">", q.after).Documents(ctx), nil
-}
-
-// timeBetweenQuery represents a query to retrieve documents between two times.
-type timeBetweenQuery struct {
-	collection *fs.CollectionRef // Firestore collection name
-	field      string            // Field name to query
-	start      time.Time         // Time start (inclusive)
-	end        time.Time         // Time end (inclusive)
-}
-
-// Execute executes the timeBetweenQuery against Firestore.
-func (q *timeBetweenQuery) Execute(ctx context.Context) (*fs.DocumentIterator, error) {
-	return q.collection.Where(q.field, ">=", q.start).Where(q.field, "<=", q.end).Documents(ctx), nil
-}
-
-// timeEqualsQuery represents a query to retrieve documents based on a time field.
-type timeEqualsQuery struct {
-	collection *fs.CollectionRef // Firestore collection name
-	field      string            // Field name to query
-	value      time.Time         // Value to match
-}
-
-// Execute executes the timeEqualsQuery against Firestore.
-func (q *timeEqualsQuery) Execute(ctx context.Context) (*fs.DocumentIterator, error) {
-	return q.collection.Where(q.field, "==", q.value).Documents(ctx), nil
-}
-
-// stringEqualsQuery represents a query to retrieve documents based on a string field.
-type stringEqualsQuery struct {
-	collection *fs.CollectionRef // Firestore collection name
-	field      string            // Field name to query
-	value      string            // Value to match
-}
+	"github.com/joomcode/errorx"
 
-// Execute executes the stringEqualsQuery against Firestore.
-func (q *stringEqualsQuery) Execute(ctx context.Context) (*fs.DocumentIterator, error) {
-	return q.collection.Where(q.field, "==", q.value).Documents(ctx), nil
-}
-
-// IntEqualsQuery represents a query to retrieve documents based on an int field.
-type intEqualsQuery struct {
-	collection *fs.CollectionRef // Firestore collection name
-	field      string            // Field name to query
-	value      int               // Value to match
-}
-
-// Execute executes the IntEqualsQuery against Firestore.
-func (q *intEqualsQuery) Execute(ctx context.Context) (*fs.DocumentIterator, error) {
-	return q.collection.Where(q.field, "==", q.value).Documents(ctx), nil
-}
-
-// BoolEqualsQuery represents a query to retrieve documents based on a bool field.
-type boolEqualsQuery struct {
-	collection *fs.CollectionRef // Firestore collection name
-	field      string            // Field name to query
-	value      bool              // Value to match
-}
-
-// Execute executes the BoolEqualsQuery against Firestore.
-func (q *boolEqualsQuery) Execute(ctx context.Context) (*fs.DocumentIterator, error) {
-	return q.collection.Where(q.field, "==", q.value).Documents(ctx), nil
-}
-
-// newQuery struct to hold the query creation functions.
-type newQuery struct {
-	collection *fs.CollectionRef // Firestore collection name
-}
-
-func (nq newQuery) Execute(ctx context.Context) (*fs.DocumentIterator, error) {
-	//TODO implement me
-	panic("implement me")
-}
-
-// ByKey creates a new ByKeyQuery instance.
-func (nq newQuery) ByKey(key string) *byKeyQuery {
-	return &byKeyQuery{collection: nq.collection, key: key}
-}
-
-// BeforeTime creates a new TimeBeforeQuery.
-func (nq newQuery) BeforeTime(field string, before time.Time) *timeBeforeQuery {
-	return &timeBeforeQuery{collection: nq.collection, field: field, before: before}
-}
-
-// AfterTime creates a new TimeAfterQuery.
-func (nq newQuery) AfterTime(field string, after time.Time) *timeAfterQuery {
-	return &timeAfterQuery{collection: nq.collection, field: field, after: after}
-}
-
-// TimeBetween creates a new TimeBetweenQuery.
-func (nq newQuery) TimeBetween(field string, start time.Time, end time.Time) *timeBetweenQuery {
-	return &timeBetweenQuery{collection: nq.collection, field: field, start: start, end: end}
-}
-
-// TimeEquals creates a new TimeEqualsQuery.
-func (nq newQuery) TimeEquals(field string, value time.Time) *timeEqualsQuery {
-	return &timeEqualsQuery{collection: nq.collection, field: field, value: value}
-}
-
-// StringEquals creates a new stringEqualsQuery for equality checks.
-func (nq newQuery) StringEquals(field string, value string) *stringEqualsQuery {
-	return &stringEqualsQuery{collection: nq.collection, field: field, value: value}
-}
+	errs "github.com/jarrodhroberson/ossgo/errors"
+)
 
-// IntEquals creates a new IntEqualsQuery for equality checks.
-func (nq newQuery) IntEquals(field string, value int) *intEqualsQuery {
-	return &intEqualsQuery{collection: nq.collection, field: field, value: value}
+// Filter is a condition of a Query, conditions are combined with And and Or.
+type Filter struct {
+	entity fs.EntityFilter
+	err    error
+}
+
+// Condition returns a Filter on a single field, field can be a dot separated path to a nested field.
+// value must be a slice for QueryOps.In, QueryOps.NotIn and QueryOps.ArrayContainsAny.
+func Condition(field string, op QueryOp, value any) Filter {
+	if !slices.Contains(queryOps, op) {
+		return Filter{err: errorx.IllegalArgument.New("%q is not a supported QueryOp", op)}
+	}
+	return Filter{entity: fs.PropertyFilter{Path: field, Operator: op.String(), Value: value}}
+}
+
+// composite combines filters with newFilter, errors of the filters are carried forward.
+func composite(filters []Filter, newFilter func(entities []fs.EntityFilter) fs.EntityFilter) Filter {
+	entities := make([]fs.EntityFilter, 0, len(filters))
+	for _, f := range filters {
+		if f.err != nil {
+			return f
+		}
+		entities = append(entities, f.entity)
+	}
+	if len(entities) == 1 {
+		return Filter{entity: entities[0]}
+	}
+	return Filter{entity: newFilter(entities)}
+}
+
+// And returns a Filter that matches documents that match all the filters.
+func And(filters ...Filter) Filter {
+	return composite(filters, func(entities []fs.EntityFilter) fs.EntityFilter {
+		return fs.AndFilter{Filters: entities}
+	})
+}
+
+// Or returns a Filter that matches documents that match any of the filters.
+func Or(filters ...Filter) Filter {
+	return composite(filters, func(entities []fs.EntityFilter) fs.EntityFilter {
+		return fs.OrFilter{Filters: entities}
+	})
+}
+
+// queryOps are all the operators in QueryOps.
+var queryOps = []QueryOp{
+	QueryOps.Equals, QueryOps.NotEquals,
+	QueryOps.LessThan, QueryOps.LessThanOrEqual,
+	QueryOps.GreaterThan, QueryOps.GreaterThanOrEquals,
+	QueryOps.ArrayContains, QueryOps.ArrayContainsAny,
+	QueryOps.In, QueryOps.NotIn,
+}
+
+type order struct {
+	field     string
+	direction fs.Direction
 }
 
-// BoolEquals creates a new BoolEqualsQuery for equality checks.
-func (nq newQuery) BoolEquals(field string, value bool) *boolEqualsQuery {
-	return &boolEqualsQuery{collection: nq.collection, field: field, value: value}
+// Query is a typed, immutable Firestore query builder for documents of type T.
+// Every method returns a new Query so a partially built query can be safely reused.
+//
+// Example usage:
+//
+//	users := firestore.NewQuery[User](client.Collection("users")).
+//		Where("age", firestore.QueryOps.GreaterThanOrEquals, 21).
+//		Filter(firestore.Or(
+//			firestore.Condition("country", firestore.QueryOps.Equals, "NZ"),
+//			firestore.Condition("country", firestore.QueryOps.Equals, "AU"),
+//		)).
+//		OrderBy("age", fs.Desc).
+//		Limit(10)
+//	for u, err := range users.Documents(ctx) {
+//		// handle each user or error
+//	}
+type Query[T any] struct {
+	collection *fs.CollectionRef
+	filters    []Filter
+	orders     []order
+	limit      int
+	toLast     bool
+	startAfter []any
+	startAt    []any
+	endBefore  []any
+	endAt      []any
+	projection *Projection
+}
+
+// NewQuery creates a new Query for documents of type T in collection.
+func NewQuery[T any](collection *fs.CollectionRef) Query[T] {
+	return Query[T]{collection: collection}
+}
+
+// Filter adds filters that documents must all match, on top of any filters already added.
+func (q Query[T]) Filter(filters ...Filter) Query[T] {
+	q.filters = append(slices.Clip(q.filters), filters...)
+	return q
+}
+
+// Where adds a Condition on a single field.
+func (q Query[T]) Where(field string, op QueryOp, value any) Query[T] {
+	return q.Filter(Condition(field, op, value))
+}
+
+// ByKey matches the document with the given ID.
+func (q Query[T]) ByKey(key string) Query[T] {
+	if q.collection == nil {
+		return q.Filter(Filter{err: errs.MustNotBeNil.New("query has no collection")})
+	}
+	return q.Filter(Filter{entity: fs.PropertyFilter{Path: fs.DocumentID, Operator: QueryOps.Equals.String(), Value: q.collection.Doc(key)}})
+}
+
+// OrderBy adds an ordering on field, orderings are applied in the order they are added.
+func (q Query[T]) OrderBy(field string, direction fs.Direction) Query[T] {
+	q.orders = append(slices.Clip(q.orders), order{field: field, direction: direction})
+	return q
+}
+
+// Limit returns at most n documents from the start of the results.
+func (q Query[T]) Limit(n int) Query[T] {
+	q.limit, q.toLast = n, false
+	return q
+}
+
+// LimitToLast returns at most n documents from the end of the results, it requires at least one OrderBy.
+func (q Query[T]) LimitToLast(n int) Query[T] {
+	q.limit, q.toLast = n, true
+	return q
+}
+
+// StartAfter starts the results after the document with the given values of the OrderBy fields, or after the
+// given *fs.DocumentSnapshot.
+func (q Query[T]) StartAfter(docSnapshotOrFieldValues ...any) Query[T] {
+	q.startAfter, q.startAt = docSnapshotOrFieldValues, nil
+	return q
+}
+
+// StartAt is StartAfter including the document the cursor points to.
+func (q Query[T]) StartAt(docSnapshotOrFieldValues ...any) Query[T] {
+	q.startAt, q.startAfter = docSnapshotOrFieldValues, nil
+	return q
+}
+
+// EndBefore ends the results before the document with the given values of the OrderBy fields, or before the
+// given *fs.DocumentSnapshot.
+func (q Query[T]) EndBefore(docSnapshotOrFieldValues ...any) Query[T] {
+	q.endBefore, q.endAt = docSnapshotOrFieldValues, nil
+	return q
+}
+
+// EndAt is EndBefore including the document the cursor points to.
+func (q Query[T]) EndAt(docSnapshotOrFieldValues ...any) Query[T] {
+	q.endAt, q.endBefore = docSnapshotOrFieldValues, nil
+	return q
+}
+
+// Select only returns the fields in the projection, see NewProjection, All and OnlyDocumentId.
+func (q Query[T]) Select(projection Projection) Query[T] {
+	q.projection = &projection
+	return q
+}
+
+// Build returns the fs.Query, or the first error from building one of its filters.
+func (q Query[T]) Build() (fs.Query, error) {
+	if q.collection == nil {
+		return fs.Query{}, errs.MustNotBeNil.New("query has no collection")
+	}
+	query := q.collection.Query
+	if len(q.filters) > 0 {
+		filter := And(q.filters...)
+		if filter.err != nil {
+			return fs.Query{}, filter.err
+		}
+		query = query.WhereEntity(filter.entity)
+	}
+	for _, o := range q.orders {
+		query = query.OrderBy(o.field, o.direction)
+	}
+	if q.limit > 0 {
+		if q.toLast {
+			if len(q.orders) == 0 {
+				return fs.Query{}, errorx.IllegalArgument.New("LimitToLast requires at least one OrderBy")
+			}
+			query = query.LimitToLast(q.limit)
+		} else {
+			query = query.Limit(q.limit)
+		}
+	}
+	if q.startAfter != nil {
+		query = query.StartAfter(q.startAfter...)
+	}
+	if q.startAt != nil {
+		query = query.StartAt(q.startAt...)
+	}
+	if q.endBefore != nil {
+		query = query.EndBefore(q.endBefore...)
+	}
+	if q.endAt != nil {
+		query = query.EndAt(q.endAt...)
+	}
+	if q.projection != nil {
+		query = q.projection.apply(query)
+	}
+	return query, nil
+}
+
+// DocumentSnapshots runs the query and yields the document snapshots.
+// A failure to build or run the query is yielded as the final error.
+func (q Query[T]) DocumentSnapshots(ctx context.Context) iter.Seq2[*fs.DocumentSnapshot, error] {
+	return func(yield func(*fs.DocumentSnapshot, error) bool) {
+		query, err := q.Build()
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		for dss, err := range DocumentIteratorToSeqErr(query.Documents(ctx)) {
+			if !yield(dss, err) {
+				return
+			}
+		}
+	}
+}
+
+// Documents runs the query and yields each document decoded into a *T.
+// A failure to build or run the query is yielded as the final error, a document that can not be
+// decoded is yielded as an error and iteration continues with the next document.
+func (q Query[T]) Documents(ctx context.Context) iter.Seq2[*T, error] {
+	return func(yield func(*T, error) bool) {
+		for dss, err := range q.DocumentSnapshots(ctx) {
+			var t *T
+			if err == nil {
+				t, err = DocSnapShotToType[T](dss)
+			}
+			if !yield(t, err) {
+				return
+			}
+		}
+	}
 }
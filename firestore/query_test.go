@@ -0,0 +1,50 @@
+package firestore
+
+import (
+	"testing"
+
+	fs "cloud.google.com/go/firestore"
+)
+
+func TestCondition(t *testing.T) {
+	if f := Condition("age", QueryOp("~="), 1); f.err == nil {
+		t.Error("Condition() with unsupported op error = nil")
+	}
+	f := Condition("age", QueryOps.GreaterThan, 21)
+	want := fs.PropertyFilter{Path: "age", Operator: ">", Value: 21}
+	if f.err != nil || f.entity != want {
+		t.Errorf("Condition() = %+v, want %+v", f.entity, want)
+	}
+
+	or := Or(f, Condition("country", QueryOps.In, []string{"NZ", "AU"}))
+	if of, ok := or.entity.(fs.OrFilter); !ok || len(of.Filters) != 2 {
+		t.Errorf("Or() = %+v, want an OrFilter of 2 filters", or.entity)
+	}
+	if single := And(f); single.entity != want {
+		t.Errorf("And() of a single filter = %+v, want %+v", single.entity, want)
+	}
+	if bad := And(f, Condition("age", QueryOp("~="), 1)); bad.err == nil {
+		t.Error("And() with an invalid filter error = nil")
+	}
+}
+
+func TestQueryBuild(t *testing.T) {
+	type user struct{}
+	if _, err := (Query[user]{}).Build(); err == nil {
+		t.Error("Build() without a collection error = nil")
+	}
+	q := NewQuery[user](&fs.CollectionRef{}).Where("age", QueryOp("~="), 1)
+	if _, err := q.Build(); err == nil {
+		t.Error("Build() with an invalid filter error = nil")
+	}
+	if _, err := NewQuery[user](&fs.CollectionRef{}).LimitToLast(1).Build(); err == nil {
+		t.Error("Build() with LimitToLast and no OrderBy error = nil")
+	}
+
+	base := NewQuery[user](&fs.CollectionRef{}).OrderBy("age", fs.Asc)
+	a := base.OrderBy("name", fs.Asc)
+	b := base.OrderBy("email", fs.Desc)
+	if a.orders[1].field != "name" || b.orders[1].field != "email" || len(base.orders) != 1 {
+		t.Error("Query methods must not modify the query they are called on")
+	}
+}
@@ -0,0 +1,134 @@
+package firestore
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	fs "cloud.google.com/go/firestore"
+	"github.com/joomcode/errorx"
+	"golang.org/x/sync/singleflight"
+
+	errs "github.com/jarrodhroberson/ossgo/errors"
+)
+
+// HEALTH_CHECK_COLLECTION is the collection read by ClientRegistry.Check, the document does not need to exist.
+const HEALTH_CHECK_COLLECTION = "_health"
+
+// ClientFactory creates the client for a database, Client is the default.
+type ClientFactory func(ctx context.Context, database DatabaseName) (*fs.Client, error)
+
+// ClientRegistry shares one client per database. A client holds a pool of gRPC connections and is safe for
+// concurrent use, creating one per operation costs a dial every time. Clients are created on first use
+// and live until Close, which should be called once at shutdown.
+//
+// Example usage:
+//
+//	registry := firestore.NewClientRegistry(nil)
+//	defer registry.Close()
+//	users := firestore.NewCollectionStore[User]("default", "users", keyer, firestore.WithClientRegistry(registry))
+type ClientRegistry struct {
+	mu       sync.Mutex
+	factory  ClientFactory
+	clients  map[DatabaseName]*fs.Client
+	closed   bool
+	creating singleflight.Group
+}
+
+// DefaultClientRegistry is used by stores created without WithClientRegistry and by RunInTransaction.
+var DefaultClientRegistry = NewClientRegistry(nil)
+
+// NewClientRegistry creates a ClientRegistry that creates clients with factory, a nil factory uses Client.
+func NewClientRegistry(factory ClientFactory) *ClientRegistry {
+	if factory == nil {
+		factory = Client
+	}
+	return &ClientRegistry{factory: factory, clients: make(map[DatabaseName]*fs.Client)}
+}
+
+// Get returns the client for database, creating it the first time. A client that fails to be created
+// is not remembered, the next Get tries again. The client must not be closed by the caller.
+// Clients are created outside the lock, a slow dial only holds up the callers that wait for the same database.
+func (r *ClientRegistry) Get(ctx context.Context, database DatabaseName) (*fs.Client, error) {
+	if client, err := r.cached(database); client != nil || err != nil {
+		return client, err
+	}
+	client, err, _ := r.creating.Do(string(database), func() (any, error) {
+		client, err := r.factory(ctx, database)
+		if err != nil {
+			return nil, err
+		}
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		if r.closed {
+			_ = client.Close()
+			return nil, errorx.IllegalState.New("client registry is closed, can not get a client for database %s", database)
+		}
+		if existing, ok := r.clients[database]; ok {
+			_ = client.Close()
+			return existing, nil
+		}
+		r.clients[database] = client
+		return client, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return client.(*fs.Client), nil
+}
+
+// cached returns the client for database if it was already created, an error if the registry is closed.
+func (r *ClientRegistry) cached(database DatabaseName) (*fs.Client, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return nil, errorx.IllegalState.New("client registry is closed, can not get a client for database %s", database)
+	}
+	return r.clients[database], nil
+}
+
+// Check reads a document of HEALTH_CHECK_COLLECTION with the client for database, creating it if needed.
+// It returns nil if Firestore answered, a missing document is a healthy answer.
+func (r *ClientRegistry) Check(ctx context.Context, database DatabaseName) error {
+	client, err := r.Get(ctx, database)
+	if err != nil {
+		return err
+	}
+	_, err = client.Collection(HEALTH_CHECK_COLLECTION).Doc("ping").Get(ctx)
+	if err != nil && !IsNotFound(err) {
+		return errs.NotReadError.Wrap(err, "health check of database %s failed", database)
+	}
+	return nil
+}
+
+// HealthCheck runs Check for every database that has a client, the failures are joined.
+// It is meant to back a readiness probe.
+func (r *ClientRegistry) HealthCheck(ctx context.Context) error {
+	r.mu.Lock()
+	databases := make([]DatabaseName, 0, len(r.clients))
+	for database := range r.clients {
+		databases = append(databases, database)
+	}
+	r.mu.Unlock()
+
+	results := make([]error, 0, len(databases))
+	for _, database := range databases {
+		results = append(results, r.Check(ctx, database))
+	}
+	return errors.Join(results...)
+}
+
+// Close closes every client, after Close every Get is an error. The close errors are joined.
+func (r *ClientRegistry) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.closed = true
+	results := make([]error, 0, len(r.clients))
+	for database, client := range r.clients {
+		if err := client.Close(); err != nil {
+			results = append(results, errs.NotClosedError.Wrap(err, "could not close client for database %s", database))
+		}
+		delete(r.clients, database)
+	}
+	return errors.Join(results...)
+}
@@ -0,0 +1,88 @@
+package firestore
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	fs "cloud.google.com/go/firestore"
+)
+
+func TestClientRegistry(t *testing.T) {
+	created := 0
+	fail := true
+	r := NewClientRegistry(func(ctx context.Context, database DatabaseName) (*fs.Client, error) {
+		if fail {
+			return nil, errors.New("unavailable")
+		}
+		created++
+		return new(fs.Client), nil
+	})
+
+	if _, err := r.Get(t.Context(), "db"); err == nil {
+		t.Fatal("Get() with a failing factory error = nil")
+	}
+	fail = false
+	first, err := r.Get(t.Context(), "db")
+	if err != nil {
+		t.Fatalf("Get() after a failure error = %v, want the client to be created again", err)
+	}
+	second, _ := r.Get(t.Context(), "db")
+	if first != second || created != 1 {
+		t.Errorf("Get() created %d clients, want 1 shared client", created)
+	}
+
+	// a zero fs.Client can not be closed, forget it before closing the registry
+	r.clients = map[DatabaseName]*fs.Client{}
+	if err = r.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if _, err = r.Get(t.Context(), "db"); err == nil {
+		t.Error("Get() after Close() error = nil")
+	}
+}
+
+func TestClientRegistryDialsOutsideTheLock(t *testing.T) {
+	var mu sync.Mutex
+	created := map[DatabaseName]int{}
+	release := make(chan struct{})
+	r := NewClientRegistry(func(ctx context.Context, database DatabaseName) (*fs.Client, error) {
+		if database == "slow" {
+			<-release
+		}
+		mu.Lock()
+		created[database]++
+		mu.Unlock()
+		return new(fs.Client), nil
+	})
+
+	var wg sync.WaitGroup
+	clients := make([]*fs.Client, 3)
+	for i := range clients {
+		wg.Go(func() {
+			clients[i], _ = r.Get(t.Context(), "slow")
+		})
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := r.Get(t.Context(), "fast")
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Get() of another database waited for a slow dial")
+	}
+
+	close(release)
+	wg.Wait()
+	if clients[0] == nil || clients[0] != clients[1] || clients[1] != clients[2] || created["slow"] != 1 {
+		t.Errorf("concurrent Get() created %d clients, want 1 shared client", created["slow"])
+	}
+}
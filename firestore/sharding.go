@@ -0,0 +1,153 @@
+package firestore
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"strconv"
+
+	fs "cloud.google.com/go/firestore"
+
+	errs "github.com/jarrodhroberson/ossgo/errors"
+)
+
+// SHARDS_COLLECTION is the subcollection of a ShardedCounter document its shards are in.
+const SHARDS_COLLECTION = "shards"
+
+// SHARD_COUNT_FIELD is the field of a counter shard that holds its part of the count.
+const SHARD_COUNT_FIELD = "count"
+
+// ShardedCounter is a counter for more increments than a single document can take, Firestore sustains about
+// one write per second to a document. Increments go to one of shards documents at random and the value is
+// the sum of the shards, read with an aggregation query. The counter document itself is never written.
+type ShardedCounter struct {
+	doc    *fs.DocumentRef
+	shards int
+}
+
+// NewShardedCounter returns the counter kept in the SHARDS_COLLECTION subcollection of doc, spread over
+// shards documents. The number of shards can be raised later, shards that are no longer written still count.
+//
+// Example usage:
+//
+//	views, err := firestore.NewShardedCounter(client.Collection("pages").Doc("home"), 20)
+//	err = views.Increment(ctx, 1)
+//	total, err := views.Value(ctx)
+func NewShardedCounter(doc *fs.DocumentRef, shards int) (*ShardedCounter, error) {
+	if doc == nil {
+		return nil, errs.MustNotBeNil.New("doc")
+	}
+	if shards < 1 {
+		return nil, errs.MinSizeExceededError.New("shards %d must be >= 1", shards)
+	}
+	return &ShardedCounter{doc: doc, shards: shards}, nil
+}
+
+// shard returns the document of a random shard.
+func (c *ShardedCounter) shard() *fs.DocumentRef {
+	return c.doc.Collection(SHARDS_COLLECTION).Doc(strconv.Itoa(rand.IntN(c.shards)))
+}
+
+// Increment adds n to the counter, n can be negative. The shard is created when it does not exist yet.
+func (c *ShardedCounter) Increment(ctx context.Context, n int64) error {
+	shard := c.shard()
+	_, err := shard.Set(ctx, map[string]any{SHARD_COUNT_FIELD: fs.Increment(n)}, fs.MergeAll)
+	if err != nil {
+		return errs.NotUpdatedError.Wrap(err, "could not increment shard %s", shard.Path)
+	}
+	return nil
+}
+
+// Value returns the sum of the shards, a counter that was never incremented is 0.
+func (c *ShardedCounter) Value(ctx context.Context) (int64, error) {
+	results, err := Aggregate(ctx, c.doc.Collection(SHARDS_COLLECTION).Query, SumOf("value", SHARD_COUNT_FIELD))
+	if err != nil {
+		return 0, err
+	}
+	return results.Int64("value")
+}
+
+// Shards returns the number of shards that have been written.
+func (c *ShardedCounter) Shards(ctx context.Context) (int64, error) {
+	return Count(ctx, c.doc.Collection(SHARDS_COLLECTION).Query)
+}
+
+// ShardedDocument spreads the writes to one hot logical document over shards documents of a CollectionStore,
+// each shard holds a part of the document and Load merges them. The shard documents are in the collection of
+// the store with the IDs from ShardID, so they are also returned by the queries of the store.
+type ShardedDocument[T any] struct {
+	store    CollectionStore[T]
+	id       string
+	shards   int
+	newShard func(id string) *T
+	merge    func(total *T, shard *T)
+}
+
+// NewShardedDocument returns the document id of store sharded over shards documents. newShard returns an
+// empty shard with the given document ID, the keyer of the store must return that ID for it, and merge adds
+// a shard into the total that Load returns, which starts as the empty shard with ID id.
+// The number of shards can be raised later but not lowered, Load only reads the shards up to shards.
+//
+// Example usage:
+//
+//	stats, err := firestore.NewShardedDocument(store, "homepage", 10,
+//		func(id string) *PageStats { return &PageStats{ID: id} },
+//		func(total *PageStats, shard *PageStats) {
+//			total.Views += shard.Views
+//			total.Likes += shard.Likes
+//		})
+//	_, err = stats.Modify(ctx, 5, func(s *PageStats) error {
+//		s.Views++
+//		return nil
+//	})
+func NewShardedDocument[T any](store CollectionStore[T], id string, shards int, newShard func(id string) *T, merge func(total *T, shard *T)) (*ShardedDocument[T], error) {
+	if store == nil || newShard == nil || merge == nil {
+		return nil, errs.MustNotBeNil.New("store, newShard and merge are required")
+	}
+	if shards < 1 {
+		return nil, errs.MinSizeExceededError.New("shards %d must be >= 1", shards)
+	}
+	return &ShardedDocument[T]{store: store, id: id, shards: shards, newShard: newShard, merge: merge}, nil
+}
+
+// ShardID returns the document ID of shard i, the id of the document and i separated by "_".
+func (d *ShardedDocument[T]) ShardID(i int) string {
+	return fmt.Sprintf("%s_%d", d.id, i)
+}
+
+// Modify applies mutate to a random shard with the read-modify-write loop of CollectionStore.Modify, a shard that
+// does not exist yet is created from newShard. mutate must only make changes that merge adds up, like increments.
+// It returns the shard that was written.
+func (d *ShardedDocument[T]) Modify(ctx context.Context, attempts int, mutate func(shard *T) error, fields ...string) (*Versioned[T], error) {
+	id := d.ShardID(rand.IntN(d.shards))
+	v, err := d.store.Modify(ctx, id, attempts, mutate, fields...)
+	if !IsNotFound(err) {
+		return v, err
+	}
+	shard := d.newShard(id)
+	if err = mutate(shard); err != nil {
+		return nil, err
+	}
+	v, err = d.store.Create(ctx, shard)
+	if IsAlreadyExists(err) {
+		// created by a concurrent Modify since it was not found
+		return d.store.Modify(ctx, id, attempts, mutate, fields...)
+	}
+	return v, err
+}
+
+// Load merges every shard that exists into the empty shard with the ID of the document.
+func (d *ShardedDocument[T]) Load(ctx context.Context) (*T, error) {
+	total := d.newShard(d.id)
+	for i := range d.shards {
+		shard, err := d.store.LoadVersioned(ctx, d.ShardID(i))
+		if IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		d.merge(total, shard.Value)
+	}
+	return total, nil
+}
@@ -0,0 +1,67 @@
+package firestore
+
+import (
+	"slices"
+	"sync"
+	"testing"
+
+	fs "cloud.google.com/go/firestore"
+)
+
+type pageStats struct {
+	ID    string `json:"id"`
+	Views int    `json:"views"`
+}
+
+func TestShardedDocument(t *testing.T) {
+	store := NewMemoryCollectionStore[pageStats]("stats", func(s *pageStats) string { return s.ID })
+	stats, err := NewShardedDocument[pageStats](store, "home", 4,
+		func(id string) *pageStats { return &pageStats{ID: id} },
+		func(total *pageStats, shard *pageStats) { total.Views += shard.Views })
+	if err != nil {
+		t.Fatalf("NewShardedDocument() = %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for range 50 {
+		wg.Go(func() {
+			if _, err := stats.Modify(t.Context(), 10, func(s *pageStats) error {
+				s.Views++
+				return nil
+			}); err != nil {
+				t.Errorf("Modify() = %v", err)
+			}
+		})
+	}
+	wg.Wait()
+
+	got, err := stats.Load(t.Context())
+	if err != nil {
+		t.Fatalf("Load() = %v", err)
+	}
+	if *got != (pageStats{ID: "home", Views: 50}) {
+		t.Errorf("Load() = %+v, want 50 views of home", *got)
+	}
+	for id := range store.All() {
+		if id != stats.ShardID(0) && id != stats.ShardID(1) && id != stats.ShardID(2) && id != stats.ShardID(3) {
+			t.Errorf("Modify() wrote document %s, want only the shards of home", id)
+		}
+	}
+}
+
+func TestNewShardedCounter(t *testing.T) {
+	doc := new(fs.Client).Collection("pages").Doc("home")
+	if _, err := NewShardedCounter(doc, 0); err == nil {
+		t.Errorf("NewShardedCounter() with 0 shards succeeded, want an error")
+	}
+	counter, err := NewShardedCounter(doc, 3)
+	if err != nil {
+		t.Fatalf("NewShardedCounter() = %v", err)
+	}
+	for range 20 {
+		shard := counter.shard()
+		if !slices.Contains([]string{"0", "1", "2"}, shard.ID) || shard.Path != doc.Path+"/"+SHARDS_COLLECTION+"/"+shard.ID {
+			t.Errorf("shard() = %s, want one of 3 shards under %s", shard.Path, doc.Path)
+		}
+	}
+}
@@ -0,0 +1,483 @@
+package firestore
+
+import (
+	"context"
+	"encoding/json"
+	"iter"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	fs "cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	errs "github.com/jarrodhroberson/ossgo/errors"
+	"github.com/jarrodhroberson/ossgo/functions/must"
+	"github.com/jarrodhroberson/ossgo/timestamp"
+)
+
+// The fields of the temporal metadata the stores maintain on every document they write.
+const (
+	CREATED_AT      = "created_at"
+	CREATED_BY      = "created_by"
+	LAST_UPDATED_AT = "last_updated_at"
+	LAST_UPDATED_BY = "last_updated_by"
+	DELETED_AT      = "deleted_at"
+	DELETED_BY      = "deleted_by"
+)
+
+// temporalFields are the fields of the temporal metadata, they are never taken from the written value.
+var temporalFields = []string{CREATED_AT, CREATED_BY, LAST_UPDATED_AT, LAST_UPDATED_BY, DELETED_AT, DELETED_BY}
+
+// AUDIT_COLLECTION is the subcollection of a document the entries of its audit trail are appended to.
+const AUDIT_COLLECTION = "_audit"
+
+// writeAttempts is how many times a write that is not conditional on a version the caller read,
+// Create and Remove, is retried when the document changes between its read and its write.
+const writeAttempts = 5
+
+// ActorFunc returns the ID of the user or service a write is made for.
+type ActorFunc func(ctx context.Context) string
+
+type actorKey struct{}
+
+// ContextWithActor returns a copy of ctx carrying the ID of the actor, for ActorFromContext.
+func ContextWithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFromContext is the ActorFunc that returns the actor set with ContextWithActor, empty when there is none.
+func ActorFromContext(ctx context.Context) string {
+	actor, _ := ctx.Value(actorKey{}).(string)
+	return actor
+}
+
+// WithTemporalMetadata records the actor of every write next to its timestamp: created_by, last_updated_by
+// and, with WithSoftDelete, deleted_by. A nil actor is ActorFromContext. Store, Remove and the bulk methods
+// have no context of their own, they call actor with context.Background().
+func WithTemporalMetadata(actor ActorFunc) CollectionStoreOption {
+	return func(o *collectionStoreOptions) {
+		if actor == nil {
+			actor = ActorFromContext
+		}
+		o.actor = actor
+	}
+}
+
+// WithSoftDelete makes Remove and BulkRemove write deleted_at instead of deleting the document.
+// Load, LoadVersioned, Update and Modify treat such a tombstone as a missing document, All, Find, Query, Page
+// and Watch only return documents whose deleted_at is null, and Store and Create over a tombstone bring it back.
+//
+// The filter on deleted_at needs a composite index for queries that filter or order on other fields,
+// and documents written before the store had WithSoftDelete have no deleted_at and are not returned until
+// it is set to null, for example with a Migration. The TransactionStore of InTransaction behaves the same.
+func WithSoftDelete() CollectionStoreOption {
+	return func(o *collectionStoreOptions) {
+		o.softDelete = true
+	}
+}
+
+// WithAudit appends an AuditEntry to the AUDIT_COLLECTION subcollection of a document for every write to it
+// through the store, read it with AuditTrail. The entry of a Store, and of every write through InTransaction,
+// is written in the same transaction as the document, the entries of the other writes are appended after them
+// and not atomically, an error appending it is returned with the write already done.
+func WithAudit() CollectionStoreOption {
+	return func(o *collectionStoreOptions) {
+		o.audit = true
+	}
+}
+
+// tracked reports if Create, Update and Remove must read the document first, to find a tombstone
+// or to record what the write changed.
+func (o collectionStoreOptions) tracked() bool {
+	return o.softDelete || o.audit
+}
+
+// setActor sets field of m to the actor of the write, if the store records actors.
+func (o collectionStoreOptions) setActor(ctx context.Context, m map[string]any, field string) {
+	if o.actor != nil {
+		m[field] = o.actor(ctx)
+	}
+}
+
+// stamp sets the temporal metadata of m, the fields of a write replacing existing, the fields of the live
+// document, nil for a new one. created_at and created_by are kept, or set to now for a new document,
+// last_updated_at and last_updated_by are set to now, and deleted_at is cleared in a WithSoftDelete store.
+// It returns the AuditOperation of the write.
+func (o collectionStoreOptions) stamp(ctx context.Context, m map[string]any, existing map[string]any, now time.Time) AuditOperation {
+	for _, field := range temporalFields {
+		delete(m, field)
+	}
+	op := AuditOperations.Updated
+	if existing == nil {
+		op = AuditOperations.Created
+		m[CREATED_AT] = now
+		o.setActor(ctx, m, CREATED_BY)
+	} else {
+		for _, field := range []string{CREATED_AT, CREATED_BY} {
+			if v, ok := existing[field]; ok {
+				m[field] = v
+			}
+		}
+	}
+	m[LAST_UPDATED_AT] = now
+	o.setActor(ctx, m, LAST_UPDATED_BY)
+	if o.softDelete {
+		m[DELETED_AT] = nil
+	}
+	return op
+}
+
+// updated sets the last_updated_at and last_updated_by of m for a write that does not replace the document,
+// the other temporal metadata is removed from m. It returns the fields it set.
+func (o collectionStoreOptions) updated(ctx context.Context, m map[string]any, now time.Time) []string {
+	for _, field := range temporalFields {
+		delete(m, field)
+	}
+	m[LAST_UPDATED_AT] = now
+	if o.actor == nil {
+		return []string{LAST_UPDATED_AT}
+	}
+	o.setActor(ctx, m, LAST_UPDATED_BY)
+	return []string{LAST_UPDATED_AT, LAST_UPDATED_BY}
+}
+
+// isTombstone reports if a document with the given fields was soft deleted.
+func (o collectionStoreOptions) isTombstone(data map[string]any) bool {
+	return o.softDelete && data[DELETED_AT] != nil
+}
+
+// AuditOperation is the kind of write an AuditEntry records.
+type AuditOperation string
+
+var AuditOperations = struct {
+	Created AuditOperation
+	Updated AuditOperation
+	Deleted AuditOperation
+}{
+	Created: "created",
+	Updated: "updated",
+	Deleted: "deleted",
+}
+
+// AuditChange is a field a write changed, nested fields are separate changes with a dot separated path.
+// The values of an encrypted field are not recorded, the change is only Redacted.
+type AuditChange struct {
+	Field    string `json:"field" firestore:"field"`
+	Before   any    `json:"before,omitempty" firestore:"before,omitempty"`
+	After    any    `json:"after,omitempty" firestore:"after,omitempty"`
+	Redacted bool   `json:"redacted,omitempty" firestore:"redacted,omitempty"`
+}
+
+// AuditEntry records a write to a document, UpdateTime is the version of the document the write created,
+// it is zero for a delete. Changes leave out the temporal metadata.
+type AuditEntry struct {
+	Operation  AuditOperation `json:"operation" firestore:"operation"`
+	Actor      string         `json:"actor,omitempty" firestore:"actor,omitempty"`
+	At         time.Time      `json:"at" firestore:"at"`
+	UpdateTime time.Time      `json:"update_time" firestore:"update_time"`
+	Changes    []AuditChange  `json:"changes" firestore:"changes"`
+}
+
+// jsonFields converts the fields of a document to the values they have in JSON,
+// so fields read from Firestore and fields of a value compare equal.
+func jsonFields(m map[string]any) (map[string]any, error) {
+	b, err := json.Marshal(m)
+	if err != nil {
+		return nil, errs.MarshalError.Wrap(err, "could not marshal document fields")
+	}
+	fields := make(map[string]any)
+	if err = json.Unmarshal(b, &fields); err != nil {
+		return nil, errs.UnMarshalError.Wrap(err, "could not unmarshal document fields")
+	}
+	return fields, nil
+}
+
+// valueAt returns the value of the field at the dot separated path in m, nil when there is none.
+func valueAt(m map[string]any, path string) any {
+	var v any = m
+	for _, segment := range strings.Split(path, ".") {
+		fields, ok := v.(map[string]any)
+		if !ok {
+			return nil
+		}
+		v = fields[segment]
+	}
+	return v
+}
+
+// auditChanges returns the changes from before to after, the plain text fields of a document before and after
+// a write, nil when it did not exist or was deleted. The values of the top level fields in encrypted are redacted.
+func auditChanges(before map[string]any, after map[string]any, encrypted map[string]bool) ([]AuditChange, error) {
+	b, err := jsonFields(before)
+	if err != nil {
+		return nil, err
+	}
+	a, err := jsonFields(after)
+	if err != nil {
+		return nil, err
+	}
+	changes := make([]AuditChange, 0)
+	for _, field := range changedFields("", b, a) {
+		top, _, _ := strings.Cut(field, ".")
+		if slices.Contains(temporalFields, top) {
+			continue
+		}
+		if _, ok := encrypted[top]; ok {
+			changes = append(changes, AuditChange{Field: field, Redacted: true})
+			continue
+		}
+		changes = append(changes, AuditChange{Field: field, Before: valueAt(b, field), After: valueAt(a, field)})
+	}
+	return changes, nil
+}
+
+// tombstone returns a NotFound error for a document that was soft deleted, nil for a live one.
+func (c collectionStore[T]) tombstone(dss *fs.DocumentSnapshot) error {
+	if !c.options.softDelete {
+		return nil
+	}
+	if deletedAt, err := dss.DataAt(DELETED_AT); err == nil && deletedAt != nil {
+		return status.Errorf(codes.NotFound, "document %s was deleted at %v", dss.Ref.Path, deletedAt)
+	}
+	return nil
+}
+
+// live restricts q to the documents that are not tombstones in a WithSoftDelete store.
+func (c collectionStore[T]) live(q fs.Query) fs.Query {
+	if c.options.softDelete {
+		return q.Where(DELETED_AT, QueryOps.Equals.String(), nil)
+	}
+	return q
+}
+
+// query is the Query the builders of Query, Page and Watch start from.
+func (c collectionStore[T]) query(client *fs.Client) Query[T] {
+	return liveQuery[T](client.Collection(c.collection), c.options)
+}
+
+// liveQuery is the Query of collection that only matches the documents that are not tombstones in a
+// WithSoftDelete store.
+func liveQuery[T any](collection *fs.CollectionRef, o collectionStoreOptions) Query[T] {
+	q := NewQuery[T](collection)
+	if o.softDelete {
+		q = q.Where(DELETED_AT, QueryOps.Equals, nil)
+	}
+	return q
+}
+
+// write reads the document id and calls change with its plain text fields, nil when it does not exist or is
+// a tombstone. The fields change returns replace the document, nil fields delete it, and an empty
+// AuditOperation writes nothing. The write is conditional on the document not having been written since it
+// was read, or since version when it is not zero, otherwise it is a ConflictError.
+// With WithAudit the AuditEntry of the write is appended after it.
+func (c collectionStore[T]) write(ctx context.Context, client *fs.Client, id string, version time.Time, change func(live map[string]any) (map[string]any, AuditOperation, error)) (*fs.WriteResult, error) {
+	doc := client.Collection(c.collection).Doc(id)
+	dss, err := doc.Get(ctx)
+	if err != nil && !IsNotFound(err) {
+		return nil, err
+	}
+	var before, live map[string]any
+	if dss != nil && dss.Exists() {
+		before = dss.Data()
+		if err = c.encryption.decrypt(ctx, before); err != nil {
+			return nil, EncryptionError.Wrap(err, "could not decrypt document with ID %s", id)
+		}
+		if !c.options.isTombstone(before) {
+			live = before
+		}
+		if !version.IsZero() && !dss.UpdateTime.Equal(version) {
+			return nil, conflictOrErr(status.Errorf(codes.FailedPrecondition, "document %s was updated at %s", doc.Path, dss.UpdateTime), id)
+		}
+	}
+	after, op, err := change(live)
+	if err != nil || op == "" {
+		return nil, err
+	}
+
+	var wr *fs.WriteResult
+	switch {
+	case after == nil && before == nil:
+		return nil, nil
+	case after == nil:
+		wr, err = doc.Delete(ctx, fs.LastUpdateTime(dss.UpdateTime))
+	default:
+		data := maps.Clone(after)
+		if err = c.encryption.encrypt(ctx, data); err != nil {
+			return nil, err
+		}
+		if before == nil {
+			wr, err = doc.Create(ctx, data)
+		} else {
+			wr, err = doc.Update(ctx, migrationUpdates(slices.Collect(maps.Keys(before)), data), fs.LastUpdateTime(dss.UpdateTime))
+		}
+	}
+	if IsAlreadyExists(err) {
+		return nil, errs.ConflictError.Wrap(err, "document %s was created since it was read", id)
+	}
+	if err != nil {
+		return nil, conflictOrErr(err, id)
+	}
+
+	if c.options.audit {
+		if err = c.appendAudit(ctx, doc, op, before, after, wr.UpdateTime); err != nil {
+			return wr, err
+		}
+	}
+	return wr, nil
+}
+
+// auditEntry returns the AuditEntry of a write of after over before, without its UpdateTime.
+// The values of the encrypted fields are redacted.
+func (o collectionStoreOptions) auditEntry(ctx context.Context, op AuditOperation, before map[string]any, after map[string]any, encrypted map[string]bool) (AuditEntry, error) {
+	changes, err := auditChanges(before, after, encrypted)
+	if err != nil {
+		return AuditEntry{}, err
+	}
+	actor := ActorFromContext
+	if o.actor != nil {
+		actor = o.actor
+	}
+	return AuditEntry{Operation: op, Actor: actor(ctx), At: time.Now(), Changes: changes}, nil
+}
+
+// appendAudit appends the AuditEntry of a write of after over before to the audit trail of doc.
+func (c collectionStore[T]) appendAudit(ctx context.Context, doc *fs.DocumentRef, op AuditOperation, before map[string]any, after map[string]any, updateTime time.Time) error {
+	entry, err := c.options.auditEntry(ctx, op, before, after, c.encryption.fields)
+	if err != nil {
+		return err
+	}
+	if op != AuditOperations.Deleted || c.options.softDelete {
+		entry.UpdateTime = updateTime
+	}
+	if _, err = doc.Collection(AUDIT_COLLECTION).NewDoc().Create(ctx, entry); err != nil {
+		return errs.NotWrittenError.Wrap(err, "document %s was written but its audit entry was not", doc.Path)
+	}
+	return nil
+}
+
+// store replaces the document of v and keeps its created_at and created_by. The document is read and
+// written in a transaction, which Firestore retries when another write gets in between, so concurrent
+// Stores do not fail and the last writer wins. With WithAudit the AuditEntry is written in the same
+// transaction, its update_time is the server timestamp of the commit, the update time of the document.
+func (c collectionStore[T]) store(ctx context.Context, client *fs.Client, v *T) error {
+	doc := client.Collection(c.collection).Doc(c.keyer(v))
+	m := must.MarshallMap(v)
+	return client.RunTransaction(ctx, func(ctx context.Context, tx *fs.Transaction) error {
+		dss, err := tx.Get(doc)
+		if err != nil && !IsNotFound(err) {
+			return err
+		}
+		var before, live map[string]any
+		if dss != nil && dss.Exists() {
+			before = dss.Data()
+			if err = c.encryption.decrypt(ctx, before); err != nil {
+				return EncryptionError.Wrap(err, "could not decrypt document with ID %s", doc.ID)
+			}
+			if !c.options.isTombstone(before) {
+				live = before
+			}
+		}
+		after := maps.Clone(m)
+		op := c.options.stamp(ctx, after, live, time.Now())
+		data := maps.Clone(after)
+		if err = c.encryption.encrypt(ctx, data); err != nil {
+			return err
+		}
+		if err = tx.Set(doc, data); err != nil {
+			return err
+		}
+		if !c.options.audit {
+			return nil
+		}
+		return c.options.auditInTransaction(ctx, tx, doc, op, before, after, c.encryption.fields)
+	})
+}
+
+// auditInTransaction writes the AuditEntry of a write of after over before to the audit trail of doc in tx,
+// its update_time is the server timestamp of the commit, the update time of the document. Like appendAudit
+// a delete that is not a soft delete has no update_time.
+func (o collectionStoreOptions) auditInTransaction(ctx context.Context, tx *fs.Transaction, doc *fs.DocumentRef, op AuditOperation, before map[string]any, after map[string]any, encrypted map[string]bool) error {
+	entry, err := o.auditEntry(ctx, op, before, after, encrypted)
+	if err != nil {
+		return err
+	}
+	fields := map[string]any{
+		"operation": entry.Operation,
+		"at":        entry.At,
+		"changes":   entry.Changes,
+	}
+	if op != AuditOperations.Deleted || o.softDelete {
+		fields["update_time"] = fs.ServerTimestamp
+	}
+	if entry.Actor != "" {
+		fields["actor"] = entry.Actor
+	}
+	return tx.Create(doc.Collection(AUDIT_COLLECTION).NewDoc(), fields)
+}
+
+// remove deletes the document id through write, in a WithSoftDelete store by writing deleted_at and
+// deleted_by. A document that does not exist is not an error.
+func (c collectionStore[T]) remove(ctx context.Context, client *fs.Client, id string) error {
+	return RetryOnConflict(ctx, writeAttempts, func(ctx context.Context) error {
+		_, err := c.write(ctx, client, id, time.Time{}, func(live map[string]any) (map[string]any, AuditOperation, error) {
+			if live == nil {
+				return nil, "", nil
+			}
+			if !c.options.softDelete {
+				return nil, AuditOperations.Deleted, nil
+			}
+			after := maps.Clone(live)
+			after[DELETED_AT] = time.Now()
+			c.options.setActor(ctx, after, DELETED_BY)
+			return after, AuditOperations.Deleted, nil
+		})
+		return err
+	})
+}
+
+// AuditTrail yields the entries of the audit trail of the document id in the order they were written,
+// the store must have WithAudit for there to be any.
+//
+// Example usage:
+//
+//	for entry, err := range store.AuditTrail(ctx, "account-1") {
+//		if err != nil {
+//			return err
+//		}
+//		log.Info().Str("actor", entry.Actor).Msgf("%s at %s", entry.Operation, entry.At)
+//	}
+func (c collectionStore[T]) AuditTrail(ctx context.Context, id string) iter.Seq2[*AuditEntry, error] {
+	return func(yield func(*AuditEntry, error) bool) {
+		client, err := c.clientProvider(ctx)
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		entries := client.Collection(c.collection).Doc(id).Collection(AUDIT_COLLECTION)
+		for entry, err := range NewQuery[AuditEntry](entries).OrderBy("at", fs.Asc).Documents(ctx) {
+			if !yield(entry, err) {
+				return
+			}
+		}
+	}
+}
+
+// timestampOf converts a temporal metadata value, a time.Time as it is read from Firestore or the RFC 3339
+// string it is in JSON, to a Timestamp. It is nil for a missing value or one that is not a timestamp.
+func timestampOf(v any) *timestamp.Timestamp {
+	switch vt := v.(type) {
+	case time.Time:
+		return timestamp.From(vt)
+	case *timestamp.Timestamp:
+		return vt
+	case string:
+		ts := new(timestamp.Timestamp)
+		if err := ts.UnmarshalText([]byte(vt)); err == nil {
+			return ts
+		}
+	}
+	return nil
+}
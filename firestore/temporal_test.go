@@ -0,0 +1,315 @@
+package firestore
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	fs "cloud.google.com/go/firestore"
+	pb "cloud.google.com/go/firestore/apiv1/firestorepb"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestEntityTimestamps(t *testing.T) {
+	at := time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)
+	tests := []struct {
+		name  string
+		value any
+		want  bool
+	}{
+		{name: "firestore timestamp", value: at, want: true},
+		{name: "json string", value: "2024-05-01T12:30:00Z", want: true},
+		{name: "missing", value: nil, want: false},
+		{name: "not a timestamp", value: "yesterday", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := Entity[struct{}]{CREATED_AT: tt.value, LAST_UPDATED_AT: tt.value}
+			created, updated := e.CreatedAt(), e.UpdatedAt()
+			if !tt.want && (created != nil || updated != nil) {
+				t.Errorf("CreatedAt() = %v and UpdatedAt() = %v, want nil", created, updated)
+			}
+			if tt.want && (created == nil || updated == nil || created.String() != "2024-05-01T12:30:00Z" || updated.String() != created.String()) {
+				t.Errorf("CreatedAt() = %v and UpdatedAt() = %v, want %s", created, updated, at)
+			}
+		})
+	}
+}
+
+func TestStamp(t *testing.T) {
+	ctx := ContextWithActor(context.Background(), "ada")
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	o := collectionStoreOptions{}
+	WithTemporalMetadata(nil)(&o)
+	WithSoftDelete()(&o)
+
+	m := map[string]any{"name": "Ann", CREATED_AT: "from the value"}
+	if op := o.stamp(ctx, m, nil, now); op != AuditOperations.Created {
+		t.Errorf("stamp() of a new document = %s, want %s", op, AuditOperations.Created)
+	}
+	want := map[string]any{"name": "Ann", CREATED_AT: now, CREATED_BY: "ada", LAST_UPDATED_AT: now, LAST_UPDATED_BY: "ada", DELETED_AT: nil}
+	if !reflect.DeepEqual(m, want) {
+		t.Errorf("stamp() of a new document = %v, want %v", m, want)
+	}
+
+	m = map[string]any{"name": "Bob"}
+	existing := map[string]any{"name": "Ann", CREATED_AT: created, CREATED_BY: "grace"}
+	if op := o.stamp(ContextWithActor(ctx, "linus"), m, existing, now); op != AuditOperations.Updated {
+		t.Errorf("stamp() of an existing document = %s, want %s", op, AuditOperations.Updated)
+	}
+	want = map[string]any{"name": "Bob", CREATED_AT: created, CREATED_BY: "grace", LAST_UPDATED_AT: now, LAST_UPDATED_BY: "linus", DELETED_AT: nil}
+	if !reflect.DeepEqual(m, want) {
+		t.Errorf("stamp() of an existing document = %v, want %v", m, want)
+	}
+
+	if !o.isTombstone(map[string]any{DELETED_AT: now}) || o.isTombstone(want) {
+		t.Errorf("isTombstone() does not tell a tombstone from a live document")
+	}
+}
+
+func TestAuditChanges(t *testing.T) {
+	before := map[string]any{
+		"age":           int64(36),
+		"name":          map[string]any{"ciphertext": []byte{1}, "key_version": int64(1)},
+		"address":       map[string]any{"city": "London", "zip": "N1"},
+		"tags":          []any{"math"},
+		LAST_UPDATED_AT: time.Now(),
+	}
+	after := map[string]any{
+		"age":           float64(36),
+		"name":          "Ada",
+		"address":       map[string]any{"city": "Paris", "zip": "N1"},
+		"email":         "ada@example.com",
+		LAST_UPDATED_AT: time.Now().Add(time.Second),
+	}
+	got, err := auditChanges(before, after, map[string]bool{"name": false})
+	if err != nil {
+		t.Fatalf("auditChanges() = %v", err)
+	}
+	want := []AuditChange{
+		{Field: "address.city", Before: "London", After: "Paris"},
+		{Field: "email", After: "ada@example.com"},
+		{Field: "name", Redacted: true},
+		{Field: "tags", Before: []any{"math"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("auditChanges() = %+v, want %+v", got, want)
+	}
+}
+
+func TestSoftDeleteQuery(t *testing.T) {
+	softDelete := collectionStoreOptions{}
+	WithSoftDelete()(&softDelete)
+	tests := []struct {
+		name    string
+		options collectionStoreOptions
+		want    bool
+	}{
+		{name: "soft delete", options: softDelete, want: true},
+		{name: "hard delete", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := collectionStore[conformanceDoc]{collection: "docs", options: tt.options}
+			q, err := c.query(new(fs.Client)).Build()
+			if err != nil {
+				t.Fatalf("Build() = %v", err)
+			}
+			b, err := q.Serialize()
+			if err != nil {
+				t.Fatalf("Serialize() = %v", err)
+			}
+			var req pb.RunQueryRequest
+			if err = proto.Unmarshal(b, &req); err != nil {
+				t.Fatalf("Unmarshal() = %v", err)
+			}
+			where := req.GetStructuredQuery().GetWhere().GetUnaryFilter()
+			got := where.GetField().GetFieldPath() == DELETED_AT && where.GetOp().String() == "IS_NULL"
+			if got != tt.want {
+				t.Errorf("query() filters on deleted_at is null = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMemoryCollectionStoreTracked(t *testing.T) {
+	ctx := ContextWithActor(context.Background(), "ada")
+	store := NewMemoryCollectionStore[patient]("patients", func(p *patient) string { return p.ID },
+		WithSoftDelete(), WithAudit(), WithTemporalMetadata(func(context.Context) string { return "ada" }),
+		WithEncryption(StaticKeyRing(map[int][]byte{1: []byte("key one")})))
+
+	if _, err := store.Create(ctx, &patient{ID: "p1", Name: "Ann", Email: "ann@example.com", Ward: 3}); err != nil {
+		t.Fatalf("Create() = %v", err)
+	}
+	if name := store.docs["p1"].data["name"]; name == "Ann" {
+		t.Errorf("Create() stored the encrypted field name in plain text")
+	}
+	if err := store.Remove("p1"); err != nil {
+		t.Fatalf("Remove() = %v", err)
+	}
+	tombstone := store.docs["p1"].data
+	if tombstone[DELETED_AT] == nil || tombstone[DELETED_BY] != "ada" || tombstone[CREATED_BY] != "ada" {
+		t.Errorf("Remove() left %v, want a tombstone deleted by ada", tombstone)
+	}
+	if _, err := store.Load("p1"); !IsNotFound(err) {
+		t.Errorf("Load() of a tombstone = %v, want a NotFound error", err)
+	}
+	for id := range store.All() {
+		t.Errorf("All() yielded the tombstone %s", id)
+	}
+	hits := store.Query(ctx, func(q Query[patient]) Query[patient] {
+		return q.Filter(EncryptedEquals(ctx, store.options.keys, "email", "ann@example.com"))
+	})
+	for p, err := range hits {
+		t.Errorf("Query() yielded the tombstone %v %v", p, err)
+	}
+
+	if _, err := store.Create(ctx, &patient{ID: "p1", Name: "Ann", Email: "ann@example.com", Ward: 4}); err != nil {
+		t.Fatalf("Create() over a tombstone = %v", err)
+	}
+	found := 0
+	for p, err := range hits {
+		if err != nil || p.Name != "Ann" || p.Ward != 4 {
+			t.Errorf("Query() by encrypted email = %+v %v, want the recreated patient", p, err)
+		}
+		found++
+	}
+	if found != 1 {
+		t.Errorf("Query() by encrypted email found %d patients, want 1", found)
+	}
+
+	var ops []AuditOperation
+	for entry, err := range store.AuditTrail(ctx, "p1") {
+		if err != nil || entry.Actor != "ada" {
+			t.Fatalf("AuditTrail() = %+v %v, want entries by ada", entry, err)
+		}
+		for _, change := range entry.Changes {
+			if change.Field == "name" && !change.Redacted {
+				t.Errorf("AuditTrail() change of the encrypted field name is not redacted: %+v", change)
+			}
+		}
+		ops = append(ops, entry.Operation)
+	}
+	if want := []AuditOperation{AuditOperations.Created, AuditOperations.Deleted, AuditOperations.Created}; !reflect.DeepEqual(ops, want) {
+		t.Errorf("AuditTrail() = %v, want %v", ops, want)
+	}
+}
+
+// auditedStore is a CollectionStore with an audit trail.
+type auditedStore[T any] interface {
+	CollectionStore[T]
+	AuditTrail(ctx context.Context, id string) iter.Seq2[*AuditEntry, error]
+}
+
+// testTrackedTransaction checks that the TransactionStore of a WithSoftDelete and WithAudit store writes
+// tombstones and audit entries like the store and that its reads leave the tombstones out.
+func testTrackedTransaction(t *testing.T, store auditedStore[patient], run transactionRunner) {
+	ctx := ContextWithActor(t.Context(), "ada")
+	inTransaction := func(fn func(ts TransactionStore[patient]) error) error {
+		return run(ctx, func(tx UnitOfWork) error {
+			return fn(store.InTransaction(tx))
+		})
+	}
+
+	if err := inTransaction(func(ts TransactionStore[patient]) error {
+		return ts.Create(&patient{ID: "p1", Name: "Ann", Email: "ann@example.com", Ward: 3})
+	}); err != nil {
+		t.Fatalf("Create() = %v", err)
+	}
+	if err := inTransaction(func(ts TransactionStore[patient]) error { return ts.Remove("p1") }); err != nil {
+		t.Fatalf("Remove() = %v", err)
+	}
+	if _, err := store.Load("p1"); !IsNotFound(err) {
+		t.Errorf("Load() after Remove() = %v, want a NotFound error", err)
+	}
+
+	err := inTransaction(func(ts TransactionStore[patient]) error {
+		if _, err := ts.Load("p1"); !IsNotFound(err) {
+			t.Errorf("Load() of a tombstone = %v, want a NotFound error", err)
+		}
+		if exists, err := ts.Exists("p1"); exists || err != nil {
+			t.Errorf("Exists() of a tombstone = %v, %v, want false", exists, err)
+		}
+		if all, err := ts.LoadAll("p1"); err != nil || len(all) != 1 || all[0] != nil {
+			t.Errorf("LoadAll() of a tombstone = %v, %v, want [nil]", all, err)
+		}
+		for p, err := range ts.Query(func(q Query[patient]) Query[patient] {
+			return q.Where("ward", QueryOps.Equals, 3)
+		}) {
+			t.Errorf("Query() yielded the tombstone %v %v", p, err)
+		}
+		return ts.Create(&patient{ID: "p1", Name: "Ann", Email: "ann@example.com", Ward: 4})
+	})
+	if err != nil {
+		t.Fatalf("Create() over a tombstone = %v", err)
+	}
+	if err = inTransaction(func(ts TransactionStore[patient]) error {
+		return ts.Update(&patient{ID: "p1", Ward: 5}, "ward")
+	}); err != nil {
+		t.Fatalf("Update() = %v", err)
+	}
+	if p, err := store.Load("p1"); err != nil || p.Name != "Ann" || p.Ward != 5 {
+		t.Errorf("Load() = %+v, %v, want Ann in ward 5", p, err)
+	}
+
+	var ops []AuditOperation
+	for entry, err := range store.AuditTrail(ctx, "p1") {
+		if err != nil || entry.Actor != "ada" {
+			t.Fatalf("AuditTrail() = %+v %v, want entries by ada", entry, err)
+		}
+		if entry.UpdateTime.IsZero() {
+			t.Errorf("AuditTrail() %s entry has no update time, a soft delete has one", entry.Operation)
+		}
+		for _, change := range entry.Changes {
+			if change.Field == "name" && !change.Redacted {
+				t.Errorf("AuditTrail() change of the encrypted field name is not redacted: %+v", change)
+			}
+		}
+		ops = append(ops, entry.Operation)
+	}
+	want := []AuditOperation{AuditOperations.Created, AuditOperations.Deleted, AuditOperations.Created, AuditOperations.Updated}
+	if !reflect.DeepEqual(ops, want) {
+		t.Errorf("AuditTrail() = %v, want %v", ops, want)
+	}
+}
+
+// trackedOptions are the options of the stores of testTrackedTransaction.
+func trackedOptions() []CollectionStoreOption {
+	return []CollectionStoreOption{WithSoftDelete(), WithAudit(), WithTemporalMetadata(nil),
+		WithEncryption(StaticKeyRing(map[int][]byte{1: []byte("key one")}))}
+}
+
+func TestMemoryTransactionStoreTracked(t *testing.T) {
+	store := NewMemoryCollectionStore[patient]("patients", func(p *patient) string { return p.ID }, trackedOptions()...)
+	testTrackedTransaction(t, store, func(ctx context.Context, fn func(tx UnitOfWork) error) error {
+		return fn(unitOfWork{ctx: ctx})
+	})
+	if data := store.docs["p1"].data; data[DELETED_AT] != nil || data["name"] == "Ann" {
+		t.Errorf("transaction left %v, want a live document with the name encrypted", data)
+	}
+}
+
+// TestFirestoreTransactionStoreTracked runs testTrackedTransaction against the Firestore emulator,
+// start it with "gcloud emulators firestore start" and set FIRESTORE_EMULATOR_HOST.
+func TestFirestoreTransactionStoreTracked(t *testing.T) {
+	if os.Getenv("FIRESTORE_EMULATOR_HOST") == "" {
+		t.Skip("FIRESTORE_EMULATOR_HOST is not set")
+	}
+	client, err := fs.NewClient(t.Context(), "ossgo-conformance")
+	if err != nil {
+		t.Fatalf("NewClient() = %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	collection := fmt.Sprintf("patients_%d", time.Now().UnixNano())
+	store := NewCollectionStore[patient](DEFAULT, collection, func(p *patient) string { return p.ID }, append(trackedOptions(), WithClient(client))...)
+	testTrackedTransaction(t, store, func(ctx context.Context, fn func(tx UnitOfWork) error) error {
+		return client.RunTransaction(ctx, func(ctx context.Context, tx *fs.Transaction) error {
+			return fn(unitOfWork{ctx: ctx, client: client, tx: tx})
+		})
+	})
+}
@@ -0,0 +1,449 @@
+package firestore
+
+import (
+	"context"
+	"iter"
+	"maps"
+	"time"
+
+	fs "cloud.google.com/go/firestore"
+	"github.com/joomcode/errorx"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/jarrodhroberson/ossgo/containers"
+	errs "github.com/jarrodhroberson/ossgo/errors"
+	"github.com/jarrodhroberson/ossgo/functions/must"
+)
+
+// UnitOfWork is a running transaction, bind typed stores to it with Bind or collectionStore.InTransaction.
+// Firestore requires all reads of a transaction to happen before any of its writes.
+type UnitOfWork interface {
+	// Context is the context of the transaction attempt.
+	Context() context.Context
+	// Client is the client the transaction runs on.
+	Client() *fs.Client
+	// Transaction is the underlying transaction for anything the typed stores do not cover.
+	Transaction() *fs.Transaction
+	// ReadOnly reports if the transaction was started with ReadOnly.
+	ReadOnly() bool
+}
+
+type unitOfWork struct {
+	ctx      context.Context
+	client   *fs.Client
+	tx       *fs.Transaction
+	readOnly bool
+}
+
+func (u unitOfWork) Context() context.Context     { return u.ctx }
+func (u unitOfWork) Client() *fs.Client           { return u.client }
+func (u unitOfWork) Transaction() *fs.Transaction { return u.tx }
+func (u unitOfWork) ReadOnly() bool               { return u.readOnly }
+
+type transactionOptions struct {
+	readOnly    bool
+	maxAttempts int
+}
+
+// TransactionOption configures RunInTransaction.
+type TransactionOption func(o *transactionOptions)
+
+// ReadOnly runs a read-only transaction, it takes no locks so it never contends with writers,
+// any write is an error.
+func ReadOnly() TransactionOption {
+	return func(o *transactionOptions) {
+		o.readOnly = true
+	}
+}
+
+// MaxAttempts sets how many times the transaction is attempted when it is aborted by contention,
+// the default is fs.DefaultTransactionMaxAttempts.
+func MaxAttempts(n int) TransactionOption {
+	return func(o *transactionOptions) {
+		o.maxAttempts = n
+	}
+}
+
+// RunInTransaction runs fn in a transaction on database. When the transaction is aborted because another
+// transaction wrote the same documents it is retried from the start, so fn must not have side effects outside
+// of the transaction. If fn returns an error the transaction is rolled back and that error is returned.
+// A transaction that is still contended after all the attempts is a ConflictError.
+//
+// Example usage, moving money between accounts held in two collections:
+//
+//	err := firestore.RunInTransaction(ctx, "bank", func(tx firestore.UnitOfWork) error {
+//		accounts := firestore.Bind[Account](tx, "accounts", func(a *Account) string { return a.ID })
+//		ledger := firestore.Bind[Entry](tx, "ledger", func(e *Entry) string { return e.ID })
+//		from, err := accounts.Load(fromID)
+//		if err != nil {
+//			return err
+//		}
+//		from.Balance -= amount
+//		if err = accounts.Update(from, "balance"); err != nil {
+//			return err
+//		}
+//		return ledger.Create(&Entry{ID: cuid2.New(), Account: fromID, Amount: -amount})
+//	})
+func RunInTransaction(ctx context.Context, database DatabaseName, fn func(tx UnitOfWork) error, options ...TransactionOption) error {
+	o := transactionOptions{maxAttempts: fs.DefaultTransactionMaxAttempts}
+	for _, option := range options {
+		option(&o)
+	}
+	client, err := DefaultClientRegistry.Get(ctx, database)
+	if err != nil {
+		return err
+	}
+
+	txOptions := []fs.TransactionOption{fs.MaxAttempts(o.maxAttempts)}
+	if o.readOnly {
+		txOptions = append(txOptions, fs.ReadOnly)
+	}
+	err = client.RunTransaction(ctx, func(ctx context.Context, tx *fs.Transaction) error {
+		return fn(unitOfWork{ctx: ctx, client: client, tx: tx, readOnly: o.readOnly})
+	}, txOptions...)
+	if status.Code(err) == codes.Aborted {
+		return errs.ConflictError.Wrap(err, "transaction on %s was aborted by contention", database)
+	}
+	return err
+}
+
+// TransactionStore reads and writes documents of type T in a collection as part of a UnitOfWork,
+// get one with Bind or CollectionStore.InTransaction.
+type TransactionStore[T any] interface {
+	// Load reads the document with the given id, a missing document is an error, check with IsNotFound.
+	Load(id string) (*T, error)
+	// Exists reports if the document with the given id exists.
+	Exists(id string) (bool, error)
+	// LoadAll reads the documents with the given ids, missing documents are nil.
+	LoadAll(ids ...string) ([]*T, error)
+	// Query runs the query built by build and yields the typed results.
+	Query(build func(q Query[T]) Query[T]) iter.Seq2[*T, error]
+	// Create creates v as a new document, it is an error if it already exists.
+	Create(v *T) error
+	// Store writes v whether or not the document exists.
+	Store(v *T) error
+	// Update writes the given top level fields of v to the existing document, all fields when none are given.
+	Update(v *T, fields ...string) error
+	// Remove deletes the document with the given id.
+	Remove(id string) error
+}
+
+// transactionStore is the TransactionStore of a Firestore transaction. It keeps the plain text fields of
+// every document it read or wrote, so a write does not have to read the document it replaces again.
+type transactionStore[T any] struct {
+	uow        UnitOfWork
+	collection *fs.CollectionRef
+	keyer      containers.Keyer[T]
+	encryption fieldEncryption
+	options    collectionStoreOptions
+	documents  map[string]map[string]any
+}
+
+// Bind returns a TransactionStore for collection, the keyer returns the document ID of a T.
+// A T with encrypted fields can only be written by a TransactionStore from collectionStore.InTransaction
+// of a store created WithEncryption.
+func Bind[T any](tx UnitOfWork, collection string, keyer containers.Keyer[T]) TransactionStore[T] {
+	return transactionStore[T]{uow: tx, collection: tx.Client().Collection(collection), keyer: keyer, encryption: newFieldEncryption[T](nil), documents: make(map[string]map[string]any)}
+}
+
+// InTransaction binds the collection of this store to tx, tx must be on the same database as the store.
+// The writes keep the temporal metadata, soft delete and audit trail like the writes of the store, the
+// AuditEntry of a write is written in the same transaction.
+//
+// Store, and in a store WithSoftDelete or WithAudit also Create, Update and Remove, need the document they
+// replace. Firestore only allows reads before the first write of a transaction, so when a transaction writes
+// more than one document Load, Exists, LoadAll or Query them before the first write, the writes then use what
+// was read instead of reading the document again.
+func (c collectionStore[T]) InTransaction(tx UnitOfWork) TransactionStore[T] {
+	return transactionStore[T]{uow: tx, collection: tx.Client().Collection(c.collection), keyer: c.keyer, encryption: c.encryption, options: c.options, documents: make(map[string]map[string]any)}
+}
+
+// remember decrypts the fields of a document read in the transaction and keeps them for its writes,
+// the fields are nil when the document does not exist.
+func (ts transactionStore[T]) remember(id string, dss *fs.DocumentSnapshot) (map[string]any, error) {
+	var data map[string]any
+	if dss != nil && dss.Exists() {
+		data = dss.Data()
+		if err := ts.encryption.decrypt(ts.uow.Context(), data); err != nil {
+			return nil, EncryptionError.Wrap(err, "could not decrypt document with ID %s", id)
+		}
+	}
+	if ts.documents != nil {
+		ts.documents[id] = data
+	}
+	return data, nil
+}
+
+// get returns the plain text fields of the document id, nil when it does not exist. A document that was
+// already read or written in the transaction is not read again.
+func (ts transactionStore[T]) get(id string) (map[string]any, error) {
+	if data, ok := ts.documents[id]; ok {
+		return data, nil
+	}
+	dss, err := ts.uow.Transaction().Get(ts.collection.Doc(id))
+	if err != nil && !IsNotFound(err) {
+		return nil, err
+	}
+	return ts.remember(id, dss)
+}
+
+// live returns the fields of the document id if it exists and is not a tombstone, nil otherwise.
+func (ts transactionStore[T]) live(id string) (map[string]any, error) {
+	data, err := ts.get(id)
+	if err != nil || data == nil || ts.options.isTombstone(data) {
+		return nil, err
+	}
+	return data, nil
+}
+
+// decode decodes the plain text fields of the document id.
+func (ts transactionStore[T]) decode(id string, data map[string]any) (*T, error) {
+	t, err := decodeFields[T](data)
+	if err != nil {
+		return nil, errs.MarshalError.Wrap(err, "error unmarshalling document with ID %s", id)
+	}
+	return t, nil
+}
+
+func (ts transactionStore[T]) notFound(id string) error {
+	return status.Errorf(codes.NotFound, "document %s not found", ts.collection.Doc(id).Path)
+}
+
+// Load reads the document with the given id, a missing document or a tombstone is an error, check with IsNotFound.
+func (ts transactionStore[T]) Load(id string) (*T, error) {
+	data, err := ts.live(id)
+	if err != nil {
+		return nil, err
+	}
+	if data == nil {
+		return nil, ts.notFound(id)
+	}
+	return ts.decode(id, data)
+}
+
+// Exists reports if the document with the given id exists and is not a tombstone, the read is part of the
+// transaction so a concurrent create of the document aborts it.
+func (ts transactionStore[T]) Exists(id string) (bool, error) {
+	data, err := ts.live(id)
+	return data != nil, err
+}
+
+// LoadAll reads the documents with the given ids in a single call, missing documents and tombstones are nil.
+func (ts transactionStore[T]) LoadAll(ids ...string) ([]*T, error) {
+	refs := make([]*fs.DocumentRef, 0, len(ids))
+	for _, id := range ids {
+		refs = append(refs, ts.collection.Doc(id))
+	}
+	snapshots, err := ts.uow.Transaction().GetAll(refs)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]*T, len(snapshots))
+	for i, dss := range snapshots {
+		data, err := ts.remember(ids[i], dss)
+		if err != nil {
+			return nil, err
+		}
+		if data == nil || ts.options.isTombstone(data) {
+			continue
+		}
+		if items[i], err = ts.decode(ids[i], data); err != nil {
+			return nil, err
+		}
+	}
+	return items, nil
+}
+
+// Query runs the query built by build as part of the transaction and yields the typed results,
+// in a WithSoftDelete store tombstones are left out like in collectionStore.Query.
+func (ts transactionStore[T]) Query(build func(q Query[T]) Query[T]) iter.Seq2[*T, error] {
+	return func(yield func(*T, error) bool) {
+		query, err := build(liveQuery[T](ts.collection, ts.options)).Build()
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		for dss, err := range DocumentIteratorToSeqErr(ts.uow.Transaction().Documents(query)) {
+			var t *T
+			if err == nil {
+				var data map[string]any
+				if data, err = ts.remember(dss.Ref.ID, dss); err == nil {
+					t, err = ts.decode(dss.Ref.ID, data)
+				}
+			}
+			if !yield(t, err) {
+				return
+			}
+		}
+	}
+}
+
+func (ts transactionStore[T]) writable() error {
+	return writable(ts.uow, ts.collection.ID)
+}
+
+// writable returns an error if tx is read-only.
+func writable(tx UnitOfWork, collection string) error {
+	if tx.ReadOnly() {
+		return errorx.IllegalState.New("can not write to %s in a read-only transaction", collection)
+	}
+	return nil
+}
+
+// encrypted returns a copy of the plain text fields after with the encrypted fields encrypted.
+func (ts transactionStore[T]) encrypted(after map[string]any) (map[string]any, error) {
+	data := maps.Clone(after)
+	if err := ts.encryption.encrypt(ts.uow.Context(), data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// written keeps after, the plain text fields of the document id after a write, nil after a delete, for the
+// following writes of the transaction. With WithAudit it writes the AuditEntry of the write in the transaction.
+func (ts transactionStore[T]) written(id string, op AuditOperation, before map[string]any, after map[string]any) error {
+	if ts.documents != nil {
+		ts.documents[id] = after
+	}
+	if !ts.options.audit {
+		return nil
+	}
+	return ts.options.auditInTransaction(ts.uow.Context(), ts.uow.Transaction(), ts.collection.Doc(id), op, before, after, ts.encryption.fields)
+}
+
+// Create creates v as a new document when the transaction commits, the commit fails if it already exists.
+// In a WithSoftDelete store a tombstone with the same key is replaced and a live document is an
+// AlreadyExists error right away.
+func (ts transactionStore[T]) Create(v *T) error {
+	if err := ts.writable(); err != nil {
+		return err
+	}
+	id := ts.keyer(v)
+	docRef := ts.collection.Doc(id)
+	var before map[string]any
+	if ts.options.softDelete {
+		var err error
+		if before, err = ts.get(id); err != nil {
+			return err
+		}
+		if before != nil && !ts.options.isTombstone(before) {
+			return status.Errorf(codes.AlreadyExists, "document %s already exists", docRef.Path)
+		}
+	}
+	after := must.MarshallMap(v)
+	op := ts.options.stamp(ts.uow.Context(), after, nil, time.Now())
+	data, err := ts.encrypted(after)
+	if err != nil {
+		return err
+	}
+	if before == nil {
+		err = ts.uow.Transaction().Create(docRef, data)
+	} else {
+		err = ts.uow.Transaction().Set(docRef, data)
+	}
+	if err != nil {
+		return err
+	}
+	return ts.written(id, op, before, after)
+}
+
+// Store writes v whether or not the document exists, like collectionStore.Store.
+// It needs the document to keep its created_at, see InTransaction.
+func (ts transactionStore[T]) Store(v *T) error {
+	if err := ts.writable(); err != nil {
+		return err
+	}
+	id := ts.keyer(v)
+	before, err := ts.get(id)
+	if err != nil {
+		return err
+	}
+	var live map[string]any
+	if before != nil && !ts.options.isTombstone(before) {
+		live = before
+	}
+	after := must.MarshallMap(v)
+	op := ts.options.stamp(ts.uow.Context(), after, live, time.Now())
+	data, err := ts.encrypted(after)
+	if err != nil {
+		return err
+	}
+	if err = ts.uow.Transaction().Set(ts.collection.Doc(id), data); err != nil {
+		return err
+	}
+	return ts.written(id, op, before, after)
+}
+
+// Update writes the given top level fields of v to the existing document, all fields when none are given.
+// The transaction already guarantees the document has not changed since it was read in the transaction.
+// In a WithSoftDelete store a tombstone is a NotFound error.
+func (ts transactionStore[T]) Update(v *T, fields ...string) error {
+	if err := ts.writable(); err != nil {
+		return err
+	}
+	id := ts.keyer(v)
+	var before map[string]any
+	if ts.options.tracked() {
+		var err error
+		if before, err = ts.live(id); err != nil {
+			return err
+		}
+		if before == nil {
+			return ts.notFound(id)
+		}
+	}
+	m := must.MarshallMap(v)
+	updated := ts.options.updated(ts.uow.Context(), m, time.Now())
+	if len(fields) > 0 {
+		fields = append(fields, updated...)
+	}
+	data, err := ts.encrypted(m)
+	if err != nil {
+		return err
+	}
+	if err = ts.uow.Transaction().Update(ts.collection.Doc(id), fieldMask(data, fields), fs.Exists); err != nil {
+		return err
+	}
+	if before == nil {
+		return nil
+	}
+	after := cloneValue(before).(map[string]any)
+	for _, u := range fieldMask(m, fields) {
+		applyUpdate(after, splitPath(u.Path), u.Value)
+	}
+	return ts.written(id, AuditOperations.Updated, before, after)
+}
+
+// Remove deletes the document with the given id when the transaction commits, in a WithSoftDelete store
+// by writing deleted_at and deleted_by. A missing document is not an error.
+func (ts transactionStore[T]) Remove(id string) error {
+	if err := ts.writable(); err != nil {
+		return err
+	}
+	docRef := ts.collection.Doc(id)
+	if !ts.options.tracked() {
+		return ts.uow.Transaction().Delete(docRef)
+	}
+	before, err := ts.live(id)
+	if err != nil || before == nil {
+		return err
+	}
+	if !ts.options.softDelete {
+		if err = ts.uow.Transaction().Delete(docRef); err != nil {
+			return err
+		}
+		return ts.written(id, AuditOperations.Deleted, before, nil)
+	}
+	after := cloneValue(before).(map[string]any)
+	after[DELETED_AT] = time.Now()
+	ts.options.setActor(ts.uow.Context(), after, DELETED_BY)
+	updates := []fs.Update{{Path: DELETED_AT, Value: after[DELETED_AT]}}
+	if actor, ok := after[DELETED_BY]; ok {
+		updates = append(updates, fs.Update{Path: DELETED_BY, Value: actor})
+	}
+	if err = ts.uow.Transaction().Update(docRef, updates, fs.Exists); err != nil {
+		return err
+	}
+	return ts.written(id, AuditOperations.Deleted, before, after)
+}
@@ -0,0 +1,35 @@
+package firestore
+
+import (
+	"testing"
+
+	fs "cloud.google.com/go/firestore"
+)
+
+func TestReadOnlyTransactionStore(t *testing.T) {
+	type user struct {
+		ID string `json:"id"`
+	}
+	var ts TransactionStore[user] = transactionStore[user]{
+		uow:        unitOfWork{ctx: t.Context(), readOnly: true},
+		collection: &fs.CollectionRef{ID: "users"},
+		keyer:      func(u *user) string { return u.ID },
+	}
+	testReadOnly(t, ts)
+	testReadOnly(t, NewMemoryCollectionStore[user]("users", func(u *user) string { return u.ID }).InTransaction(unitOfWork{ctx: t.Context(), readOnly: true}))
+}
+
+func testReadOnly[T any](t *testing.T, ts TransactionStore[T]) {
+	t.Helper()
+	writes := map[string]func() error{
+		"Create": func() error { return ts.Create(new(T)) },
+		"Store":  func() error { return ts.Store(new(T)) },
+		"Update": func() error { return ts.Update(new(T)) },
+		"Remove": func() error { return ts.Remove("a") },
+	}
+	for name, write := range writes {
+		if err := write(); err == nil {
+			t.Errorf("%s() in a read-only transaction error = nil", name)
+		}
+	}
+}
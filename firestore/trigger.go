@@ -0,0 +1,334 @@
+package firestore
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"maps"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/cloudevents/sdk-go/v2/event"
+	"github.com/gin-gonic/gin"
+	"github.com/googleapis/google-cloudevents-go/cloud/firestoredata"
+	"github.com/joomcode/errorx"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	errs "github.com/jarrodhroberson/ossgo/errors"
+)
+
+// DocumentEvent is a Firestore trigger CloudEvent decoded into the document before and after the write.
+// Before is nil for a created document and After is nil for a deleted document.
+type DocumentEvent[T any] struct {
+	// ID is the CloudEvent id, events can be delivered more than once so use it to make handlers idempotent.
+	ID   string
+	Type string
+	Time time.Time
+	// Database is the database ID, Path is the document path in the database like "users/abc/orders/1".
+	Database   string
+	Path       string
+	Collection string
+	DocumentID string
+	Before     *T
+	After      *T
+	// Changed is the sorted, dot separated paths of the fields that are different between Before and After.
+	Changed []string
+}
+
+// Kind is ChangeKinds.Added for a created document, ChangeKinds.Removed for a deleted document,
+// and ChangeKinds.Modified otherwise.
+func (e DocumentEvent[T]) Kind() ChangeKind {
+	switch {
+	case e.Before == nil:
+		return ChangeKinds.Added
+	case e.After == nil:
+		return ChangeKinds.Removed
+	default:
+		return ChangeKinds.Modified
+	}
+}
+
+// HasChanged reports if any of the fields, or a field nested in them, changed.
+func (e DocumentEvent[T]) HasChanged(fields ...string) bool {
+	for _, changed := range e.Changed {
+		for _, field := range fields {
+			if changed == field || strings.HasPrefix(changed, field+".") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// fromValue converts a Firestore event value into the value encoding/json would decode it from.
+// Timestamps become time.Time, references their full name and geo points a map of latitude and longitude.
+func fromValue(v *firestoredata.Value) any {
+	switch vt := v.GetValueType().(type) {
+	case *firestoredata.Value_BooleanValue:
+		return vt.BooleanValue
+	case *firestoredata.Value_IntegerValue:
+		return vt.IntegerValue
+	case *firestoredata.Value_DoubleValue:
+		return vt.DoubleValue
+	case *firestoredata.Value_TimestampValue:
+		return vt.TimestampValue.AsTime()
+	case *firestoredata.Value_StringValue:
+		return vt.StringValue
+	case *firestoredata.Value_BytesValue:
+		return base64.StdEncoding.EncodeToString(vt.BytesValue)
+	case *firestoredata.Value_ReferenceValue:
+		return vt.ReferenceValue
+	case *firestoredata.Value_GeoPointValue:
+		return map[string]any{"latitude": vt.GeoPointValue.GetLatitude(), "longitude": vt.GeoPointValue.GetLongitude()}
+	case *firestoredata.Value_ArrayValue:
+		values := make([]any, 0, len(vt.ArrayValue.GetValues()))
+		for _, av := range vt.ArrayValue.GetValues() {
+			values = append(values, fromValue(av))
+		}
+		return values
+	case *firestoredata.Value_MapValue:
+		return fromFields(vt.MapValue.GetFields())
+	default:
+		return nil
+	}
+}
+
+func fromFields(fields map[string]*firestoredata.Value) map[string]any {
+	m := make(map[string]any, len(fields))
+	for k, v := range fields {
+		m[k] = fromValue(v)
+	}
+	return m
+}
+
+// changedFields returns the paths of the fields that are different between before and after,
+// nested maps are compared field by field.
+func changedFields(prefix string, before map[string]any, after map[string]any) []string {
+	changed := make([]string, 0)
+	keys := make(map[string]struct{}, len(before)+len(after))
+	for k := range before {
+		keys[k] = struct{}{}
+	}
+	for k := range after {
+		keys[k] = struct{}{}
+	}
+	for _, k := range slices.Sorted(maps.Keys(keys)) {
+		b, inBefore := before[k]
+		a, inAfter := after[k]
+		bm, bIsMap := b.(map[string]any)
+		am, aIsMap := a.(map[string]any)
+		switch {
+		case inBefore && inAfter && bIsMap && aIsMap:
+			changed = append(changed, changedFields(prefix+k+".", bm, am)...)
+		case inBefore != inAfter || !reflect.DeepEqual(b, a):
+			changed = append(changed, prefix+k)
+		}
+	}
+	return changed
+}
+
+// decodeFields decodes the fields of a document into a T the same way DocSnapShotToType does, through its json tags.
+func decodeFields[T any](fields map[string]any) (*T, error) {
+	b, err := json.Marshal(fields)
+	if err != nil {
+		return nil, errs.MarshalError.Wrap(err, "could not marshal document fields")
+	}
+	var t T
+	if err = json.Unmarshal(b, &t); err != nil {
+		return nil, errs.UnMarshalError.Wrap(err, "could not unmarshal document fields into %T", t)
+	}
+	return &t, nil
+}
+
+// documentName is the parts of a document resource name
+// "projects/{project}/databases/{database}/documents/{path}".
+type documentName struct {
+	database   string
+	path       string
+	collection string
+	id         string
+}
+
+func parseDocumentName(name string) (documentName, error) {
+	var dn documentName
+	prefix, path, ok := strings.Cut(name, "/documents/")
+	segments := strings.Split(path, "/")
+	if !ok || len(segments) < 2 || len(segments)%2 != 0 {
+		return dn, errs.InvalidData.New("%q is not a document name", name)
+	}
+	if _, database, ok := strings.Cut(prefix, "/databases/"); ok {
+		dn.database = database
+	}
+	dn.path = path
+	dn.collection = segments[len(segments)-2]
+	dn.id = segments[len(segments)-1]
+	return dn, nil
+}
+
+// documentHandler handles the decoded event data of a single collection.
+type documentHandler func(ctx context.Context, ce event.Event, dn documentName, data *firestoredata.DocumentEventData) error
+
+// EventRouter dispatches Firestore trigger CloudEvents to the handler registered for the collection of the
+// document, registered with OnDocument. The collection is the ID of the collection directly containing the
+// document, so a handler for "orders" gets the events of every "orders" subcollection.
+// Events for collections without a handler are acknowledged and ignored.
+//
+// Example usage:
+//
+//	router := firestore.NewEventRouter()
+//	firestore.OnDocument(router, "users", func(ctx context.Context, e firestore.DocumentEvent[User]) error {
+//		if e.HasChanged("email") {
+//			return sendVerification(ctx, e.After)
+//		}
+//		return nil
+//	})
+//	r.POST("/events/firestore", router.Gin)
+type EventRouter struct {
+	handlers map[string]documentHandler
+}
+
+// NewEventRouter creates an EventRouter without handlers.
+func NewEventRouter() *EventRouter {
+	return &EventRouter{handlers: make(map[string]documentHandler)}
+}
+
+// OnDocument registers handler for the events of documents in collection, decoded into T.
+// Registering a collection again replaces its handler.
+//
+// The encrypted fields of T are decrypted with the KeyRing of WithKeyRing, Changed compares their plain text.
+// A document that can not be decoded into T is an UnsupportedEventError, so the event is not retried, a
+// document that can not be decrypted is an error that is retried, the key may not be readable yet.
+func OnDocument[T any](r *EventRouter, collection string, handler func(ctx context.Context, e DocumentEvent[T]) error, options ...DocumentOption) {
+	fe := newFieldEncryption[T](newDocumentOptions(options).keys)
+	decode := func(ctx context.Context, fields map[string]any, path string) (*T, error) {
+		if err := fe.decrypt(ctx, fields); err != nil {
+			return nil, EncryptionError.Wrap(err, "could not decrypt document %s", path)
+		}
+		t, err := decodeFields[T](fields)
+		if err != nil {
+			return nil, UnsupportedEventError.Wrap(err, "could not decode document %s", path)
+		}
+		return t, nil
+	}
+	r.handlers[collection] = func(ctx context.Context, ce event.Event, dn documentName, data *firestoredata.DocumentEventData) error {
+		de := DocumentEvent[T]{
+			ID:         ce.ID(),
+			Type:       ce.Type(),
+			Time:       ce.Time(),
+			Database:   dn.database,
+			Path:       dn.path,
+			Collection: dn.collection,
+			DocumentID: dn.id,
+		}
+		before := map[string]any{}
+		after := map[string]any{}
+		var err error
+		if data.GetOldValue() != nil {
+			before = fromFields(data.GetOldValue().GetFields())
+			if de.Before, err = decode(ctx, before, dn.path); err != nil {
+				return err
+			}
+		}
+		if data.GetValue() != nil {
+			after = fromFields(data.GetValue().GetFields())
+			if de.After, err = decode(ctx, after, dn.path); err != nil {
+				return err
+			}
+		}
+		de.Changed = changedFields("", before, after)
+		return handler(ctx, de)
+	}
+}
+
+// decodeEventData decodes the DocumentEventData of the event, Firestore sends it as protobuf
+// but JSON is accepted as well.
+func decodeEventData(ce event.Event) (*firestoredata.DocumentEventData, error) {
+	if !strings.HasPrefix(ce.Type(), DocumentEventTypePrefix) {
+		return nil, UnsupportedEventError.New("%q is not a Firestore document event", ce.Type())
+	}
+	var data firestoredata.DocumentEventData
+	var err error
+	if strings.Contains(ce.DataContentType(), "json") {
+		err = protojson.UnmarshalOptions{DiscardUnknown: true}.Unmarshal(ce.Data(), &data)
+	} else {
+		err = proto.Unmarshal(ce.Data(), &data)
+	}
+	if err != nil {
+		return nil, UnsupportedEventError.Wrap(err, "could not decode the data of event %s", ce.ID())
+	}
+	return &data, nil
+}
+
+// Receive dispatches the event to the handler of its collection and returns the error of the handler.
+// Its signature matches the CloudEvent functions of the Functions Framework.
+func (r *EventRouter) Receive(ctx context.Context, ce event.Event) error {
+	data, err := decodeEventData(ce)
+	if err != nil {
+		return err
+	}
+	return r.dispatch(ctx, ce, data)
+}
+
+func (r *EventRouter) dispatch(ctx context.Context, ce event.Event, data *firestoredata.DocumentEventData) error {
+	doc := data.GetValue()
+	if doc == nil {
+		doc = data.GetOldValue()
+	}
+	dn, err := parseDocumentName(doc.GetName())
+	if err != nil {
+		return UnsupportedEventError.Wrap(err, "event %s has no document", ce.ID())
+	}
+	handler, ok := r.handlers[dn.collection]
+	if !ok {
+		log.Debug().Msgf("no handler for %s event of document %s", ce.Type(), dn.path)
+		return nil
+	}
+	return handler(ctx, ce, dn, data)
+}
+
+// handle parses and dispatches the CloudEvent in the request and returns the HTTP status to respond with.
+// An event that can not be parsed is a 400 so it is not retried, a handler error is a 500 so it is.
+func (r *EventRouter) handle(req *http.Request) (int, error) {
+	ce, err := cloudevents.NewEventFromHTTPRequest(req)
+	if err != nil {
+		return http.StatusBadRequest, errs.InvalidData.Wrap(err, "expected CloudEvent")
+	}
+	data, err := decodeEventData(*ce)
+	if err != nil {
+		return http.StatusBadRequest, err
+	}
+	if err = r.dispatch(req.Context(), *ce, data); err != nil {
+		if errorx.IsOfType(err, UnsupportedEventError) {
+			return http.StatusBadRequest, err
+		}
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusNoContent, nil
+}
+
+// ServeHTTP makes EventRouter an http.Handler for Eventarc Firestore triggers.
+func (r *EventRouter) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	status, err := r.handle(req)
+	if err != nil {
+		log.Error().Err(err).Msg(err.Error())
+		http.Error(w, err.Error(), status)
+		return
+	}
+	w.WriteHeader(status)
+}
+
+// Gin is EventRouter as a gin handler for Eventarc Firestore triggers.
+func (r *EventRouter) Gin(c *gin.Context) {
+	status, err := r.handle(c.Request)
+	if err != nil {
+		c.AbortWithError(status, err)
+		return
+	}
+	c.Status(status)
+}
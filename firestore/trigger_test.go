@@ -0,0 +1,147 @@
+package firestore
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	"github.com/googleapis/google-cloudevents-go/cloud/firestoredata"
+	"github.com/joomcode/errorx"
+)
+
+type triggerUser struct {
+	Name    string            `json:"name"`
+	Age     int64             `json:"age"`
+	Address map[string]string `json:"address"`
+}
+
+func stringValue(s string) *firestoredata.Value {
+	return &firestoredata.Value{ValueType: &firestoredata.Value_StringValue{StringValue: s}}
+}
+
+func integerValue(i int64) *firestoredata.Value {
+	return &firestoredata.Value{ValueType: &firestoredata.Value_IntegerValue{IntegerValue: i}}
+}
+
+func mapValue(fields map[string]*firestoredata.Value) *firestoredata.Value {
+	return &firestoredata.Value{ValueType: &firestoredata.Value_MapValue{MapValue: &firestoredata.MapValue{Fields: fields}}}
+}
+
+func TestChangedFields(t *testing.T) {
+	before := fromFields(map[string]*firestoredata.Value{
+		"name":    stringValue("a"),
+		"age":     integerValue(1),
+		"address": mapValue(map[string]*firestoredata.Value{"city": stringValue("x"), "zip": stringValue("1")}),
+	})
+	after := fromFields(map[string]*firestoredata.Value{
+		"name":    stringValue("a"),
+		"age":     integerValue(2),
+		"address": mapValue(map[string]*firestoredata.Value{"city": stringValue("y"), "zip": stringValue("1")}),
+		"email":   stringValue("a@example.com"),
+	})
+	got := changedFields("", before, after)
+	want := []string{"address.city", "age", "email"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("changedFields() = %v, want %v", got, want)
+	}
+}
+
+func TestParseDocumentName(t *testing.T) {
+	dn, err := parseDocumentName("projects/p/databases/(default)/documents/users/u1/orders/o1")
+	if err != nil {
+		t.Fatalf("parseDocumentName() error = %v", err)
+	}
+	want := documentName{database: "(default)", path: "users/u1/orders/o1", collection: "orders", id: "o1"}
+	if dn != want {
+		t.Errorf("parseDocumentName() = %+v, want %+v", dn, want)
+	}
+	if _, err = parseDocumentName("projects/p/databases/(default)/documents/users"); err == nil {
+		t.Error("parseDocumentName() of a collection should be an error")
+	}
+}
+
+func TestEventRouterDispatch(t *testing.T) {
+	name := "projects/p/databases/(default)/documents/users/u1"
+	data := &firestoredata.DocumentEventData{
+		OldValue: &firestoredata.Document{Name: name, Fields: map[string]*firestoredata.Value{
+			"name": stringValue("a"), "age": integerValue(1),
+		}},
+		Value: &firestoredata.Document{Name: name, Fields: map[string]*firestoredata.Value{
+			"name": stringValue("b"), "age": integerValue(1),
+		}},
+	}
+	var got DocumentEvent[triggerUser]
+	router := NewEventRouter()
+	OnDocument(router, "users", func(ctx context.Context, e DocumentEvent[triggerUser]) error {
+		got = e
+		return nil
+	})
+	if err := router.dispatch(context.Background(), event.New(), data); err != nil {
+		t.Fatalf("dispatch() error = %v", err)
+	}
+	if got.Kind() != ChangeKinds.Modified || got.DocumentID != "u1" || got.Before.Name != "a" || got.After.Name != "b" {
+		t.Errorf("dispatch() event = %+v", got)
+	}
+	if !got.HasChanged("name") || got.HasChanged("age") {
+		t.Errorf("dispatch() changed = %v, want [name]", got.Changed)
+	}
+
+	data.Value = nil
+	data.OldValue.Name = "projects/p/databases/(default)/documents/orders/o1"
+	got = DocumentEvent[triggerUser]{}
+	if err := router.dispatch(context.Background(), event.New(), data); err != nil || got.DocumentID != "" {
+		t.Errorf("dispatch() of an unhandled collection = %v, %+v", err, got)
+	}
+}
+
+func TestEventRouterDecryptsDocuments(t *testing.T) {
+	keys := StaticKeyRing(map[int][]byte{1: []byte("key one")})
+	encrypted := func(v any) *firestoredata.Value {
+		ev, err := encryptValue(v, 1, []byte("key one"), false)
+		if err != nil {
+			t.Fatalf("encryptValue() = %v", err)
+		}
+		return mapValue(map[string]*firestoredata.Value{
+			CIPHERTEXT_FIELD:  {ValueType: &firestoredata.Value_BytesValue{BytesValue: ev[CIPHERTEXT_FIELD].([]byte)}},
+			KEY_VERSION_FIELD: integerValue(1),
+		})
+	}
+	name := "projects/p/databases/(default)/documents/patients/p1"
+	data := &firestoredata.DocumentEventData{
+		OldValue: &firestoredata.Document{Name: name, Fields: map[string]*firestoredata.Value{
+			"id": stringValue("p1"), "name": encrypted("Ann"), "ward": integerValue(3),
+		}},
+		Value: &firestoredata.Document{Name: name, Fields: map[string]*firestoredata.Value{
+			"id": stringValue("p1"), "name": encrypted("Ann"), "ward": integerValue(4),
+		}},
+	}
+
+	var got DocumentEvent[patient]
+	handler := func(ctx context.Context, e DocumentEvent[patient]) error {
+		got = e
+		return nil
+	}
+	router := NewEventRouter()
+	OnDocument(router, "patients", handler, WithKeyRing(keys))
+	if err := router.dispatch(context.Background(), event.New(), data); err != nil {
+		t.Fatalf("dispatch() error = %v", err)
+	}
+	if got.Before.Name != "Ann" || got.After.Name != "Ann" || got.After.Ward != 4 {
+		t.Errorf("dispatch() event = %+v, want the decrypted patient", got)
+	}
+	// the ciphertext of name changed but its plain text did not
+	if !reflect.DeepEqual(got.Changed, []string{"ward"}) {
+		t.Errorf("dispatch() changed = %v, want [ward]", got.Changed)
+	}
+
+	OnDocument(router, "patients", handler)
+	if err := router.dispatch(context.Background(), event.New(), data); !errorx.IsOfType(err, EncryptionError) {
+		t.Errorf("dispatch() without a KeyRing error = %v, want an EncryptionError so the event is retried", err)
+	}
+	data.Value.Fields["ward"] = stringValue("four")
+	OnDocument(router, "patients", handler, WithKeyRing(keys))
+	if err := router.dispatch(context.Background(), event.New(), data); !errorx.IsOfType(err, UnsupportedEventError) {
+		t.Errorf("dispatch() of a document that does not decode error = %v, want an UnsupportedEventError", err)
+	}
+}
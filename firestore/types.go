@@ -6,6 +6,7 @@ import (
 	"iter"
 	"slices"
 	"strings"
+	"time"
 
 	"cloud.google.com/go/firestore"
 	fs "cloud.google.com/go/firestore"
@@ -15,7 +16,6 @@ import (
 
 	"github.com/jarrodhroberson/ossgo/containers"
 	errs "github.com/jarrodhroberson/ossgo/errors"
-	"github.com/jarrodhroberson/ossgo/functions"
 	"github.com/jarrodhroberson/ossgo/functions/must"
 	"github.com/jarrodhroberson/ossgo/seq"
 	slyces "github.com/jarrodhroberson/ossgo/slices"
@@ -34,19 +34,25 @@ type CollectionName string
 
 const DEFAULT DatabaseName = fs.DefaultDatabaseID
 
-const DocumentCreated = "google.cloud.firestore.v1.created"
-const DocumentUpdated = "google.cloud.firestore.v1.updated"
-const DocumentDeleted = "google.cloud.firestore.v1.deleted"
-const DocumentWritten = "google.cloud.firestore.v1.written"
+// DocumentEventTypePrefix is the prefix of the CloudEvent types of Firestore document triggers,
+// including the ".withAuthContext" variants.
+const DocumentEventTypePrefix = "google.cloud.firestore.document.v1."
+
+const DocumentCreated = DocumentEventTypePrefix + "created"
+const DocumentUpdated = DocumentEventTypePrefix + "updated"
+const DocumentDeleted = DocumentEventTypePrefix + "deleted"
+const DocumentWritten = DocumentEventTypePrefix + "written"
 
 type Entity[T any] map[string]interface{}
 
+// CreatedAt returns the created_at of the entity, nil when it has none.
 func (e Entity[T]) CreatedAt() *timestamp.Timestamp {
-	return e["created_at"].(*timestamp.Timestamp)
+	return timestampOf(e[CREATED_AT])
 }
 
+// UpdatedAt returns the last_updated_at of the entity, nil when it has none.
 func (e Entity[T]) UpdatedAt() *timestamp.Timestamp {
-	return e["last_updated_at"].(*timestamp.Timestamp)
+	return timestampOf(e[LAST_UPDATED_AT])
 }
 
 func (e Entity[T]) As() *T {
@@ -113,6 +119,17 @@ func (proj Projection) paths() []string {
 	}))
 }
 
+// apply adds the projection to q.
+func (proj Projection) apply(q fs.Query) fs.Query {
+	if paths := proj.paths(); slices.Equal(paths, All.paths()) {
+		// Get all fields, no need to add Select()
+		return q
+	} else if slices.Equal(paths, OnlyDocumentId.paths()) {
+		return q.Select()
+	}
+	return q.SelectPaths(proj.fieldPaths...)
+}
+
 // OnlyDocumentId returns only the document ID (as in doc.Ref.ID) not the "id" that might be on the document
 // if you want only that document id use NewProjection
 var OnlyDocumentId = NewProjection()
@@ -120,66 +137,163 @@ var OnlyDocumentId = NewProjection()
 // All returns all fields of the document
 var All = NewProjection("*")
 
-// collectionStore provides CRUD operations for a specific Firestore collection
+// collectionStoreOptions are the settings of a collectionStore shared by all document types.
+type collectionStoreOptions struct {
+	pageTokenKey []byte
+	registry     *ClientRegistry
+	client       *firestore.Client
+	keys         KeyRing
+	actor        ActorFunc
+	softDelete   bool
+	audit        bool
+}
+
+// CollectionStoreOption configures a CollectionStore created with NewCollectionStore.
+type CollectionStoreOption func(o *collectionStoreOptions)
+
+// WithPageTokenKey sets the key page tokens are signed with. Every instance serving the same
+// CollectionStore must use the same key for tokens to be valid across instances, load it from a secret.
+func WithPageTokenKey(key []byte) CollectionStoreOption {
+	return func(o *collectionStoreOptions) {
+		o.pageTokenKey = key
+	}
+}
+
+// WithClientRegistry gets the client of the store from registry instead of DefaultClientRegistry.
+func WithClientRegistry(registry *ClientRegistry) CollectionStoreOption {
+	return func(o *collectionStoreOptions) {
+		o.registry = registry
+	}
+}
+
+// WithClient uses client for every operation of the store, the database of the store is ignored.
+// The caller owns the client and closes it, useful for tests against the emulator.
+func WithClient(client *firestore.Client) CollectionStoreOption {
+	return func(o *collectionStoreOptions) {
+		o.client = client
+	}
+}
+
+// collectionStore provides CRUD operations for a specific Firestore collection.
+// The client comes from the ClientRegistry of the store and is shared, methods never close it.
 type collectionStore[T any] struct {
-	clientProvider functions.Provider[*firestore.Client]
+	clientProvider func(ctx context.Context) (*firestore.Client, error)
 	collection     string
 	keyer          containers.Keyer[T]
+	options        collectionStoreOptions
+	encryption     fieldEncryption
 }
 
+// All yields every document in the collection, a failure to get the client is logged and yields nothing.
 func (c collectionStore[T]) All() iter.Seq2[string, *T] {
 	ctx := context.Background()
-	client := c.clientProvider()
-	defer func(client *firestore.Client) {
-		err := client.Close()
-		if err != nil {
-			log.Err(err).Msg(err.Error())
-		}
-	}(client)
-	docIter := client.Collection(c.collection).Documents(ctx)
-	dssSeq2 := DocSnapShotSeq2ToType[T](DocumentIteratorToSeq2(docIter))
-	return ClosingWhenDoneSeq2(dssSeq2, client)
+	client, err := c.clientProvider(ctx)
+	if err != nil {
+		log.Err(err).Msg(err.Error())
+		return func(yield func(string, *T) bool) {}
+	}
+	docIter := c.live(client.Collection(c.collection).Query).Documents(ctx)
+	decode := documentDecoder[T](ctx, c.encryption)
+	return seq.Map2[string, *firestore.DocumentSnapshot, string, *T](DocumentIteratorToSeq2(docIter), seq.PassThruFunc[string], func(dss *firestore.DocumentSnapshot) *T {
+		return must.Must(decode(dss))
+	})
 }
 
+// Find yields the documents matching where, a failure to get the client is logged and yields nothing.
 func (c collectionStore[T]) Find(where WherePredicate, selectPaths Projection) iter.Seq[*T] {
 	ctx := context.Background()
-	client := c.clientProvider()
-	defer func(client *firestore.Client) {
-		err := client.Close()
-		if err != nil {
-			log.Err(err).Msg(err.Error())
-		}
-	}(client)
+	client, err := c.clientProvider(ctx)
+	if err != nil {
+		log.Err(err).Msg(err.Error())
+		return func(yield func(*T) bool) {}
+	}
 
-	q := client.Collection(c.collection).Query
+	q := c.live(client.Collection(c.collection).Query)
 	if where != nil {
 		q = where(q)
 	}
-	if paths := selectPaths.paths(); slices.Equal(paths, All.paths()) {
-		// Get all fields, no need to add Select()
-	} else if slices.Equal(paths, OnlyDocumentId.paths()) {
-		q = q.Select()
-	} else {
-		q = q.SelectPaths(selectPaths.fieldPaths...)
+	docIter := selectPaths.apply(q).Documents(ctx)
+	if len(c.encryption.fields) == 0 {
+		return DocumentIterToTypeSeq[T](docIter)
+	}
+	decode := documentDecoder[T](ctx, c.encryption)
+	return func(yield func(*T) bool) {
+		for dss := range DocumentIteratorToSeq(docIter) {
+			t, err := decode(dss)
+			if err != nil {
+				log.Err(err).Msg(err.Error())
+				return
+			}
+			if !yield(t) {
+				return
+			}
+		}
 	}
-	docIter := q.Documents(ctx)
-	return DocumentIterToTypeSeq[T](docIter)
 }
 
-func (c collectionStore[T]) Load(id string) (*T, error) {
-	ctx := context.Background()
-	client := c.clientProvider()
-	defer func(client *firestore.Client) {
-		err := client.Close()
+// Query runs the query built by build on the collection and yields the typed results.
+//
+// Example usage:
+//
+//	adults := store.Query(ctx, func(q firestore.Query[User]) firestore.Query[User] {
+//		return q.Where("age", firestore.QueryOps.GreaterThanOrEquals, 21).OrderBy("age", fs.Asc)
+//	})
+func (c collectionStore[T]) Query(ctx context.Context, build func(q Query[T]) Query[T]) iter.Seq2[*T, error] {
+	return func(yield func(*T, error) bool) {
+		client, err := c.clientProvider(ctx)
 		if err != nil {
-			log.Err(err).Msg(err.Error())
+			yield(nil, err)
+			return
+		}
+		decode := documentDecoder[T](ctx, c.encryption)
+		for dss, err := range build(c.query(client)).DocumentSnapshots(ctx) {
+			var t *T
+			if err == nil {
+				t, err = decode(dss)
+			}
+			if !yield(t, err) {
+				return
+			}
 		}
-	}(client)
+	}
+}
+
+// Page returns pageSize results of the query built by build, starting after the document the pageToken points to.
+// An empty pageToken starts at the first result. The returned NextPageToken is opaque and signed, a token
+// that has been modified or was returned for a different query is an InvalidPageTokenError.
+// Tokens are signed with the key from WithPageTokenKey, without one they are signed with a key generated once
+// per process, so they are valid for every store of this process but not for other instances.
+//
+// Example usage:
+//
+//	page, err := store.Page(ctx, func(q firestore.Query[User]) firestore.Query[User] {
+//		return q.Where("active", firestore.QueryOps.Equals, true).OrderBy("name", fs.Asc)
+//	}, 50, c.Query("page_token"))
+func (c collectionStore[T]) Page(ctx context.Context, build func(q Query[T]) Query[T], pageSize int, pageToken string) (*ResultPage[T], error) {
+	client, err := c.clientProvider(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return page(build(c.query(client)), pageSize, pageToken, c.options.pageTokenKey, firestorePageSource(ctx, documentDecoder[T](ctx, c.encryption)))
+}
+
+func (c collectionStore[T]) Load(id string) (*T, error) {
+	ctx := context.Background()
+	client, err := c.clientProvider(ctx)
+	if err != nil {
+		return nil, err
+	}
 
 	docSnapshot, err := client.Collection(c.collection).Doc(id).Get(ctx)
+	if err == nil {
+		err = c.tombstone(docSnapshot)
+	}
 	if err != nil {
 		return nil, err
 	}
+	if len(c.encryption.fields) > 0 {
+		return documentDecoder[T](ctx, c.encryption)(docSnapshot)
+	}
 	var t T
 	err = docSnapshot.DataTo(&t)
 	if err != nil {
@@ -190,40 +304,43 @@ func (c collectionStore[T]) Load(id string) (*T, error) {
 
 func (c collectionStore[T]) BulkLoad(iter iter.Seq[string]) iter.Seq2[*T, error] {
 	ctx := context.Background()
-	client := c.clientProvider()
-
-	return ClosingWhenDoneSeq2(func(yield func(*T, error) bool) {
+	return func(yield func(*T, error) bool) {
+		client, err := c.clientProvider(ctx)
+		if err != nil {
+			yield(nil, err)
+			return
+		}
 		for id := range iter {
 			docSS, err := client.Collection(c.collection).Doc(id).Get(ctx)
-			var t T
 			if err == nil {
-				err = docSS.DataTo(&t)
+				err = c.tombstone(docSS)
+			}
+			t := new(T)
+			if err == nil && len(c.encryption.fields) > 0 {
+				t, err = documentDecoder[T](ctx, c.encryption)(docSS)
+			} else if err == nil {
+				err = docSS.DataTo(t)
 			}
-			if !yield(&t, nil) {
+			if !yield(t, err) {
 				return
 			}
 		}
-	}, client)
+	}
 }
 
+// Store writes v whether or not the document exists, the last writer wins.
+// The document is read and written in a transaction to keep its created_at, it is set for a new document.
+// Use Create, Update or Modify when concurrent writers must not overwrite each other.
 func (c collectionStore[T]) Store(v *T) (*T, error) {
-	client := c.clientProvider()
-	defer func(client *firestore.Client) {
-		err := client.Close()
-		if err != nil {
-			log.Err(err).Msg(err.Error())
-		}
-	}(client)
-
-	docRef := client.Collection(c.collection).Doc(c.keyer(v))
-	m := must.MarshallMap(v)
-	m["last_updated_at"] = timestamp.Now()
-	containers.RemoveKeys(m, "created_at")
 	ctx := context.Background()
-	_, err := docRef.Set(ctx, m)
+	client, err := c.clientProvider(ctx)
 	if err != nil {
 		return nil, err
 	}
+
+	if err = c.store(ctx, client, v); err != nil {
+		return nil, err
+	}
 	return v, nil
 }
 
@@ -252,6 +369,8 @@ const (
 
 // BulkStore stores multiple items in batches using Firestore BulkWriter.
 // It uses errgroup.Group to concurrently process batches of documents up to firestore.MAX_BULK_WRITE_SIZE.
+// Each batch is read first to keep the created_at of the documents that exist, a store WithAudit writes
+// the items one by one like Store instead.
 // The errgroup ensures all goroutines complete and collects any errors that occur during batch processing.
 // If any goroutine returns an error, BulkStoreErrorHandling will return that error after all goroutines are complete.
 //
@@ -260,13 +379,10 @@ const (
 // - COLLECT_ERRORS: Continue processing remaining batches even if some fail report errors after iterator is complete
 func (c collectionStore[T]) BulkStore(iter iter.Seq[*T], errorHandling BulkStoreErrorHandling) error {
 	ctx := context.Background()
-	client := c.clientProvider()
-	defer func(client *firestore.Client) {
-		err := client.Close()
-		if err != nil {
-			log.Err(err).Msg(err.Error())
-		}
-	}(client)
+	client, err := c.clientProvider(ctx)
+	if err != nil {
+		return err
+	}
 
 	bw := client.BulkWriter(ctx)
 	defer func() {
@@ -279,14 +395,37 @@ func (c collectionStore[T]) BulkStore(iter iter.Seq[*T], errorHandling BulkStore
 	batches := seq.Chunk(iter, MAX_BULK_WRITE_SIZE)
 	for batch := range batches {
 		eg.Go(func() error {
-			for item := range batch {
-				docRef := client.Collection(c.collection).Doc(c.keyer(item))
-				m := must.MarshallMap(item)
-				m["last_updated_at"] = timestamp.Now()
-				containers.RemoveKeys(m, "created_at")
-				_, err := bw.Set(docRef, m)
+			if c.options.audit {
+				for item := range batch {
+					if err := c.store(ctx, client, item); err != nil {
+						return err
+					}
+				}
+				return nil
+			}
+			items := slices.Collect(batch)
+			docRefs := make([]*firestore.DocumentRef, 0, len(items))
+			for _, item := range items {
+				docRefs = append(docRefs, client.Collection(c.collection).Doc(c.keyer(item)))
+			}
+			docSnapshots, err := client.GetAll(ctx, docRefs)
+			if err != nil {
+				return err
+			}
+			now := time.Now()
+			for i, item := range items {
+				m, err := encodeDocument(ctx, c.encryption, item)
+				if err != nil {
+					return err
+				}
+				var existing map[string]any
+				if docSnapshots[i].Exists() && !c.options.isTombstone(docSnapshots[i].Data()) {
+					existing = docSnapshots[i].Data()
+				}
+				c.options.stamp(ctx, m, existing, now)
+				_, err = bw.Set(docRefs[i], m)
 				if err != nil {
-					return BulkWriterError.Wrap(err, "error storing document \"%s\"", docRef.Path)
+					return BulkWriterError.Wrap(err, "error storing document \"%s\"", docRefs[i].Path)
 				}
 			}
 			bw.Flush()
@@ -296,17 +435,19 @@ func (c collectionStore[T]) BulkStore(iter iter.Seq[*T], errorHandling BulkStore
 	return eg.Wait()
 }
 
+// Remove deletes the document with the given id, a store WithSoftDelete writes its deleted_at instead.
 func (c collectionStore[T]) Remove(id string) error {
 	ctx := context.Background()
-	client := c.clientProvider()
-	defer func(client *firestore.Client) {
-		err := client.Close()
-		if err != nil {
-			log.Err(err).Msg(err.Error())
-		}
-	}(client)
+	client, err := c.clientProvider(ctx)
+	if err != nil {
+		return err
+	}
 
-	_, err := client.Collection(c.collection).Doc(id).Delete(ctx)
+	if c.options.tracked() {
+		err = c.remove(ctx, client, id)
+	} else {
+		_, err = client.Collection(c.collection).Doc(id).Delete(ctx)
+	}
 	if err != nil {
 		err = errs.NotDeletedError.Wrap(err, "failed to delete %s/%s", c.collection, id)
 	}
@@ -315,13 +456,10 @@ func (c collectionStore[T]) Remove(id string) error {
 
 func (c collectionStore[T]) BulkRemove(iter iter.Seq[string], errorHandling BulkStoreErrorHandling) error {
 	ctx := context.Background()
-	client := c.clientProvider()
-	defer func(client *firestore.Client) {
-		err := client.Close()
-		if err != nil {
-			log.Err(err).Msg(err.Error())
-		}
-	}(client)
+	client, err := c.clientProvider(ctx)
+	if err != nil {
+		return err
+	}
 	bw := client.BulkWriter(ctx)
 	defer func() {
 		bw.Flush()
@@ -332,6 +470,14 @@ func (c collectionStore[T]) BulkRemove(iter iter.Seq[string], errorHandling Bulk
 	batches := seq.Chunk(iter, MAX_BULK_WRITE_SIZE)
 	for batch := range batches {
 		eg.Go(func() error {
+			if c.options.tracked() {
+				for id := range batch {
+					if err := c.remove(ctx, client, id); err != nil {
+						return errs.NotDeletedError.Wrap(err, "failed to delete %s/%s", c.collection, id)
+					}
+				}
+				return nil
+			}
 			for id := range batch {
 				docRef := client.Collection(c.collection).Doc(id)
 				_, err := bw.Delete(docRef)
@@ -350,7 +496,16 @@ type CollectionStore[T any] interface {
 	All() iter.Seq2[string, *T]
 	Load(id string) (*T, error)
 	Find(where WherePredicate, selectPaths Projection) iter.Seq[*T]
+	Query(ctx context.Context, build func(q Query[T]) Query[T]) iter.Seq2[*T, error]
+	Watch(ctx context.Context, build func(q Query[T]) Query[T]) iter.Seq2[Change[T], error]
+	Page(ctx context.Context, build func(q Query[T]) Query[T], pageSize int, pageToken string) (*ResultPage[T], error)
+	Aggregate(ctx context.Context, build func(q Query[T]) Query[T], aggregations ...Aggregation) (AggregationResults, error)
 	Store(v *T) (*T, error)
+	Create(ctx context.Context, v *T) (*Versioned[T], error)
+	LoadVersioned(ctx context.Context, id string) (*Versioned[T], error)
+	Update(ctx context.Context, current *Versioned[T], fields ...string) (*Versioned[T], error)
+	Modify(ctx context.Context, id string, attempts int, mutate func(t *T) error, fields ...string) (*Versioned[T], error)
+	InTransaction(tx UnitOfWork) TransactionStore[T]
 	BulkStore(iter iter.Seq[*T], errorHandling BulkStoreErrorHandling) error
 	Remove(id string) error
 	BulkRemove(iter iter.Seq[string], errorHandling BulkStoreErrorHandling) error
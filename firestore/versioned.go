@@ -0,0 +1,236 @@
+package firestore
+
+import (
+	"context"
+	"maps"
+	"math/rand/v2"
+	"slices"
+	"time"
+
+	fs "cloud.google.com/go/firestore"
+	"github.com/joomcode/errorx"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	errs "github.com/jarrodhroberson/ossgo/errors"
+	"github.com/jarrodhroberson/ossgo/functions/must"
+)
+
+// Versioned is a document and the update time it had when it was read.
+// The update time is the version, an Update only succeeds if the document has not been written since.
+type Versioned[T any] struct {
+	Value      *T
+	UpdateTime time.Time
+}
+
+// IsConflict checks if the given error is a ConflictError, the document was written by someone else
+// since it was read, read it again and retry.
+func IsConflict(err error) bool {
+	return errorx.IsOfType(err, errs.ConflictError)
+}
+
+// conflictOrErr converts a failed precondition into a ConflictError, other errors are returned unchanged.
+func conflictOrErr(err error, id string) error {
+	if code := status.Code(err); code == codes.FailedPrecondition || code == codes.Aborted {
+		return errs.ConflictError.Wrap(err, "document %s was modified since it was read", id)
+	}
+	return err
+}
+
+// fieldMask returns the updates for the top level fields of m, all of them if fields is empty.
+// A field that is not in m, like an omitempty field with a zero value, is deleted.
+func fieldMask(m map[string]interface{}, fields []string) []fs.Update {
+	if len(fields) == 0 {
+		// the values of m are written as they are, ToUpdates would marshal them to JSON again and
+		// turn timestamps into strings and bytes into base64
+		updates := make([]fs.Update, 0, len(m))
+		for _, k := range slices.Sorted(maps.Keys(m)) {
+			updates = append(updates, fs.Update{Path: k, Value: m[k]})
+		}
+		return updates
+	}
+	updates := make([]fs.Update, 0, len(fields))
+	for _, field := range fields {
+		value, ok := m[field]
+		if !ok {
+			value = fs.Delete
+		}
+		updates = append(updates, fs.Update{Path: field, Value: value})
+	}
+	return updates
+}
+
+// Create stores v as a new document, it fails if a document with the same key already exists,
+// check with IsAlreadyExists. In a WithSoftDelete store a tombstone with the same key is replaced.
+func (c collectionStore[T]) Create(ctx context.Context, v *T) (*Versioned[T], error) {
+	client, err := c.clientProvider(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	id := c.keyer(v)
+	if c.options.tracked() {
+		var wr *fs.WriteResult
+		m := must.MarshallMap(v)
+		err = RetryOnConflict(ctx, writeAttempts, func(ctx context.Context) error {
+			wr, err = c.write(ctx, client, id, time.Time{}, func(live map[string]any) (map[string]any, AuditOperation, error) {
+				if live != nil {
+					return nil, "", status.Errorf(codes.AlreadyExists, "document %s/%s already exists", c.collection, id)
+				}
+				after := maps.Clone(m)
+				return after, c.options.stamp(ctx, after, nil, time.Now()), nil
+			})
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		return &Versioned[T]{Value: v, UpdateTime: wr.UpdateTime}, nil
+	}
+
+	m, err := encodeDocument(ctx, c.encryption, v)
+	if err != nil {
+		return nil, err
+	}
+	c.options.stamp(ctx, m, nil, time.Now())
+	wr, err := client.Collection(c.collection).Doc(id).Create(ctx, m)
+	if err != nil {
+		return nil, err
+	}
+	return &Versioned[T]{Value: v, UpdateTime: wr.UpdateTime}, nil
+}
+
+// LoadVersioned loads the document with its update time, to be passed to Update.
+func (c collectionStore[T]) LoadVersioned(ctx context.Context, id string) (*Versioned[T], error) {
+	client, err := c.clientProvider(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	dss, err := client.Collection(c.collection).Doc(id).Get(ctx)
+	if err == nil {
+		err = c.tombstone(dss)
+	}
+	if err != nil {
+		return nil, err
+	}
+	t, err := documentDecoder[T](ctx, c.encryption)(dss)
+	if err != nil {
+		return nil, err
+	}
+	return &Versioned[T]{Value: t, UpdateTime: dss.UpdateTime}, nil
+}
+
+// Update writes the fields of current.Value to the existing document, fields are the top level names
+// of the fields to write, all fields are written when none are given. created_at is never written.
+//
+// The write only succeeds if the document has not been written since current was read, otherwise it is
+// a ConflictError. A zero UpdateTime only requires the document to exist.
+// The returned Versioned has the new update time so it can be updated again.
+func (c collectionStore[T]) Update(ctx context.Context, current *Versioned[T], fields ...string) (*Versioned[T], error) {
+	client, err := c.clientProvider(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	id := c.keyer(current.Value)
+	if c.options.tracked() {
+		m := must.MarshallMap(current.Value)
+		wr, err := c.write(ctx, client, id, current.UpdateTime, func(live map[string]any) (map[string]any, AuditOperation, error) {
+			if live == nil {
+				return nil, "", status.Errorf(codes.NotFound, "document %s/%s not found", c.collection, id)
+			}
+			after := maps.Clone(live)
+			for _, u := range fieldMask(m, fields) {
+				if u.Value == fs.Delete {
+					delete(after, u.Path)
+				} else {
+					after[u.Path] = u.Value
+				}
+			}
+			return after, c.options.stamp(ctx, after, live, time.Now()), nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		return &Versioned[T]{Value: current.Value, UpdateTime: wr.UpdateTime}, nil
+	}
+
+	m, err := encodeDocument(ctx, c.encryption, current.Value)
+	if err != nil {
+		return nil, err
+	}
+	updated := c.options.updated(ctx, m, time.Now())
+	if len(fields) > 0 {
+		fields = append(fields, updated...)
+	}
+	precondition := fs.Exists
+	if !current.UpdateTime.IsZero() {
+		precondition = fs.LastUpdateTime(current.UpdateTime)
+	}
+	wr, err := client.Collection(c.collection).Doc(id).Update(ctx, fieldMask(m, fields), precondition)
+	if err != nil {
+		return nil, conflictOrErr(err, id)
+	}
+	return &Versioned[T]{Value: current.Value, UpdateTime: wr.UpdateTime}, nil
+}
+
+// Modify is a read-modify-write loop: it loads the document, applies mutate and updates the fields,
+// retrying from the read up to attempts times while the update is a ConflictError.
+// An error from mutate stops the loop and is returned as is.
+//
+// Example usage:
+//
+//	account, err := store.Modify(ctx, "account-1", 5, func(a *Account) error {
+//		if a.Balance < amount {
+//			return ErrInsufficientFunds
+//		}
+//		a.Balance -= amount
+//		return nil
+//	}, "balance")
+func (c collectionStore[T]) Modify(ctx context.Context, id string, attempts int, mutate func(t *T) error, fields ...string) (*Versioned[T], error) {
+	return modify[T](ctx, c, id, attempts, mutate, fields...)
+}
+
+// versionedStore is a store that can read and conditionally write versioned documents.
+type versionedStore[T any] interface {
+	LoadVersioned(ctx context.Context, id string) (*Versioned[T], error)
+	Update(ctx context.Context, current *Versioned[T], fields ...string) (*Versioned[T], error)
+}
+
+// modify is the read-modify-write loop of Modify on store.
+func modify[T any](ctx context.Context, store versionedStore[T], id string, attempts int, mutate func(t *T) error, fields ...string) (*Versioned[T], error) {
+	var updated *Versioned[T]
+	err := RetryOnConflict(ctx, attempts, func(ctx context.Context) error {
+		current, err := store.LoadVersioned(ctx, id)
+		if err != nil {
+			return err
+		}
+		if err = mutate(current.Value); err != nil {
+			return err
+		}
+		updated, err = store.Update(ctx, current, fields...)
+		return err
+	})
+	return updated, err
+}
+
+// RetryOnConflict calls fn until it does not return a ConflictError, at most attempts times,
+// with a jittered exponential backoff between attempts. The last error is returned when the
+// attempts are used up, the cause of ctx is returned if it is done while waiting.
+func RetryOnConflict(ctx context.Context, attempts int, fn func(ctx context.Context) error) error {
+	if attempts < 1 {
+		return errs.MinSizeExceededError.New("attempts %d must be >= 1", attempts)
+	}
+	backoff := 50 * time.Millisecond
+	for attempt := 1; ; attempt++ {
+		err := fn(ctx)
+		if err == nil || !IsConflict(err) || attempt == attempts {
+			return err
+		}
+		if !sleep(ctx, backoff/2+rand.N(backoff)) {
+			return context.Cause(ctx)
+		}
+		backoff = min(backoff*2, 2*time.Second)
+	}
+}
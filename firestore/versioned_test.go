@@ -0,0 +1,59 @@
+package firestore
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	fs "cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestConflictOrErr(t *testing.T) {
+	if err := conflictOrErr(status.Error(codes.FailedPrecondition, "update time mismatch"), "doc-1"); !IsConflict(err) {
+		t.Errorf("conflictOrErr(FailedPrecondition) = %v, want a ConflictError", err)
+	}
+	if err := conflictOrErr(status.Error(codes.NotFound, "missing"), "doc-1"); IsConflict(err) || !IsNotFound(err) {
+		t.Errorf("conflictOrErr(NotFound) = %v, want the NotFound error", err)
+	}
+}
+
+func TestFieldMask(t *testing.T) {
+	m := map[string]interface{}{"name": "a", "age": 21}
+	if got := fieldMask(m, nil); len(got) != 2 {
+		t.Errorf("fieldMask() without fields = %v, want all 2 fields", got)
+	}
+	got := fieldMask(m, []string{"age", "nickname"})
+	want := []fs.Update{{Path: "age", Value: 21}, {Path: "nickname", Value: fs.Delete}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("fieldMask() = %v, want %v", got, want)
+	}
+}
+
+func TestRetryOnConflict(t *testing.T) {
+	conflict := conflictOrErr(status.Error(codes.FailedPrecondition, ""), "doc-1")
+	calls := 0
+	err := RetryOnConflict(t.Context(), 3, func(ctx context.Context) error {
+		calls++
+		if calls < 3 {
+			return conflict
+		}
+		return nil
+	})
+	if err != nil || calls != 3 {
+		t.Errorf("RetryOnConflict() = %v after %d calls, want nil after 3", err, calls)
+	}
+
+	calls = 0
+	if err = RetryOnConflict(t.Context(), 2, func(ctx context.Context) error { calls++; return conflict }); !IsConflict(err) || calls != 2 {
+		t.Errorf("RetryOnConflict() = %v after %d calls, want a ConflictError after 2", err, calls)
+	}
+
+	other := errors.New("insufficient funds")
+	calls = 0
+	if err = RetryOnConflict(t.Context(), 5, func(ctx context.Context) error { calls++; return other }); err != other || calls != 1 {
+		t.Errorf("RetryOnConflict() = %v after %d calls, want %v after 1", err, calls, other)
+	}
+}
@@ -0,0 +1,269 @@
+package firestore
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"time"
+
+	fs "cloud.google.com/go/firestore"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ChangeKind is what happened to a document in a Change.
+type ChangeKind string
+
+func (ck ChangeKind) String() string {
+	return string(ck)
+}
+
+var ChangeKinds = struct {
+	Added    ChangeKind
+	Modified ChangeKind
+	Removed  ChangeKind
+}{
+	Added:    "added",
+	Modified: "modified",
+	Removed:  "removed",
+}
+
+// Change is a document that was added to, modified in or removed from the results of a watched query or document.
+// Value is the document after the change. For a removed document it is the last known value when Firestore
+// provides it, it is nil for a removed WatchDocument or a document removed while the listener was reconnecting.
+type Change[T any] struct {
+	Kind       ChangeKind
+	ID         string
+	Value      *T
+	UpdateTime time.Time
+	ReadTime   time.Time
+}
+
+// minWatchBackoff and maxWatchBackoff bound the wait before a listener is reopened after a transient error.
+const minWatchBackoff = 250 * time.Millisecond
+const maxWatchBackoff = 30 * time.Second
+
+// isTransient reports if a listener that failed with err should be reopened.
+func isTransient(err error) bool {
+	if errors.Is(err, iterator.Done) {
+		return true
+	}
+	switch status.Code(err) {
+	case codes.Unavailable, codes.Internal, codes.Unknown, codes.ResourceExhausted, codes.DeadlineExceeded, codes.Aborted:
+		return true
+	default:
+		return false
+	}
+}
+
+// sleep waits for d, or until ctx is done in which case it returns false.
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// docChange is a change before the document is decoded, value is false if there is no data to decode.
+type docChange struct {
+	kind  ChangeKind
+	id    string
+	doc   *fs.DocumentSnapshot
+	value bool
+}
+
+// watchState is the update time of every document in the results, so a listener that is reopened
+// only yields what changed while it was down, instead of every document as added again.
+type watchState map[string]time.Time
+
+// apply records the changes of a snapshot of a listener that has not been reopened.
+func (ws watchState) apply(changes []fs.DocumentChange) []docChange {
+	result := make([]docChange, 0, len(changes))
+	for _, c := range changes {
+		id := c.Doc.Ref.ID
+		switch c.Kind {
+		case fs.DocumentAdded:
+			ws[id] = c.Doc.UpdateTime
+			result = append(result, docChange{kind: ChangeKinds.Added, id: id, doc: c.Doc, value: true})
+		case fs.DocumentModified:
+			ws[id] = c.Doc.UpdateTime
+			result = append(result, docChange{kind: ChangeKinds.Modified, id: id, doc: c.Doc, value: true})
+		case fs.DocumentRemoved:
+			delete(ws, id)
+			result = append(result, docChange{kind: ChangeKinds.Removed, id: id, doc: c.Doc, value: true})
+		}
+	}
+	return result
+}
+
+// resume compares the first snapshot of a reopened listener, which has every document as added,
+// with what was seen before and replaces the state with it.
+func (ws watchState) resume(docs []*fs.DocumentSnapshot) []docChange {
+	result := make([]docChange, 0)
+	seen := make(map[string]struct{}, len(docs))
+	for _, doc := range docs {
+		id := doc.Ref.ID
+		seen[id] = struct{}{}
+		updateTime, ok := ws[id]
+		ws[id] = doc.UpdateTime
+		if !ok {
+			result = append(result, docChange{kind: ChangeKinds.Added, id: id, doc: doc, value: true})
+		} else if !updateTime.Equal(doc.UpdateTime) {
+			result = append(result, docChange{kind: ChangeKinds.Modified, id: id, doc: doc, value: true})
+		}
+	}
+	for id := range ws {
+		if _, ok := seen[id]; !ok {
+			delete(ws, id)
+			result = append(result, docChange{kind: ChangeKinds.Removed, id: id})
+		}
+	}
+	return result
+}
+
+// yieldChanges decodes and yields the changes, it returns false when the consumer stopped.
+func yieldChanges[T any](changes []docChange, readTime time.Time, decode func(dss *fs.DocumentSnapshot) (*T, error), yield func(Change[T], error) bool) bool {
+	for _, dc := range changes {
+		change := Change[T]{Kind: dc.kind, ID: dc.id, ReadTime: readTime}
+		var err error
+		if dc.doc != nil {
+			change.UpdateTime = dc.doc.UpdateTime
+		}
+		if dc.value {
+			change.Value, err = decode(dc.doc)
+		}
+		if !yield(change, err) {
+			return false
+		}
+	}
+	return true
+}
+
+// nextChanges blocks for the next snapshot of a listener and returns its changes against state.
+type nextChanges func(state watchState, resumed bool) ([]docChange, time.Time, error)
+
+// watch opens listeners with open until the consumer stops, ctx is done or a listener fails with an error
+// that is not transient. next returns the changes of each snapshot against state, resumed is true for the first
+// snapshot of a reopened listener. decode decodes the documents of the changes.
+func watch[T any](ctx context.Context, decode func(dss *fs.DocumentSnapshot) (*T, error), open func() (next nextChanges, stop func())) iter.Seq2[Change[T], error] {
+	return func(yield func(Change[T], error) bool) {
+		state := make(watchState)
+		backoff := minWatchBackoff
+		resumed := false
+		for ctx.Err() == nil {
+			next, stop := open()
+			err := func() error {
+				defer stop()
+				for {
+					changes, readTime, err := next(state, resumed)
+					if err != nil {
+						return err
+					}
+					resumed, backoff = false, minWatchBackoff
+					if !yieldChanges(changes, readTime, decode, yield) {
+						return nil
+					}
+				}
+			}()
+			if err == nil || ctx.Err() != nil {
+				return
+			}
+			if !isTransient(err) {
+				yield(Change[T]{}, err)
+				return
+			}
+			if !sleep(ctx, backoff) {
+				return
+			}
+			backoff, resumed = min(backoff*2, maxWatchBackoff), true
+		}
+	}
+}
+
+// WatchQuery yields a Change for every document added to, modified in or removed from the results of q,
+// starting with every current result as added. It runs until the consumer stops or ctx is done, which ends
+// the sequence without an error.
+//
+// A listener that fails with a transient error is reopened with a backoff, and the results are compared with
+// what was seen before so only what changed in the meantime is yielded. An error that is not transient,
+// like a missing index or permission, is yielded as the final error.
+//
+// Example usage:
+//
+//	for change, err := range firestore.WatchQuery(ctx, query) {
+//		if err != nil {
+//			return err
+//		}
+//		push(change.Kind, change.ID, change.Value)
+//	}
+func WatchQuery[T any](ctx context.Context, q Query[T]) iter.Seq2[Change[T], error] {
+	return watchQuery(ctx, q, DocSnapShotToType[T])
+}
+
+// watchQuery is WatchQuery with the documents decoded by decode.
+func watchQuery[T any](ctx context.Context, q Query[T], decode func(dss *fs.DocumentSnapshot) (*T, error)) iter.Seq2[Change[T], error] {
+	query, err := q.Build()
+	if err != nil {
+		return func(yield func(Change[T], error) bool) {
+			yield(Change[T]{}, err)
+		}
+	}
+	return watch(ctx, decode, func() (nextChanges, func()) {
+		it := query.Snapshots(ctx)
+		next := func(state watchState, resumed bool) ([]docChange, time.Time, error) {
+			qs, err := it.Next()
+			if err != nil {
+				return nil, time.Time{}, err
+			}
+			if !resumed {
+				return state.apply(qs.Changes), qs.ReadTime, nil
+			}
+			docs, err := qs.Documents.GetAll()
+			if err != nil {
+				return nil, time.Time{}, err
+			}
+			return state.resume(docs), qs.ReadTime, nil
+		}
+		return next, it.Stop
+	})
+}
+
+// WatchDocument yields a Change every time the document is created, modified or deleted, starting with the
+// document as added if it exists. Shutdown and resumption work like WatchQuery.
+func WatchDocument[T any](ctx context.Context, doc *fs.DocumentRef) iter.Seq2[Change[T], error] {
+	return watch(ctx, DocSnapShotToType[T], func() (nextChanges, func()) {
+		it := doc.Snapshots(ctx)
+		next := func(state watchState, resumed bool) ([]docChange, time.Time, error) {
+			dss, err := it.Next()
+			if err != nil {
+				return nil, time.Time{}, err
+			}
+			if !dss.Exists() {
+				return state.resume(nil), dss.ReadTime, nil
+			}
+			return state.resume([]*fs.DocumentSnapshot{dss}), dss.ReadTime, nil
+		}
+		return next, it.Stop
+	})
+}
+
+// Watch runs WatchQuery on the query built by build on the collection.
+func (c collectionStore[T]) Watch(ctx context.Context, build func(q Query[T]) Query[T]) iter.Seq2[Change[T], error] {
+	return func(yield func(Change[T], error) bool) {
+		client, err := c.clientProvider(ctx)
+		if err != nil {
+			yield(Change[T]{}, err)
+			return
+		}
+		for change, err := range watchQuery(ctx, build(c.query(client)), documentDecoder[T](ctx, c.encryption)) {
+			if !yield(change, err) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package firestore
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	fs "cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func snapshot(id string, updated int64) *fs.DocumentSnapshot {
+	return &fs.DocumentSnapshot{Ref: &fs.DocumentRef{ID: id}, UpdateTime: time.Unix(updated, 0)}
+}
+
+func kinds(changes []docChange) map[string]ChangeKind {
+	m := make(map[string]ChangeKind, len(changes))
+	for _, c := range changes {
+		m[c.id] = c.kind
+	}
+	return m
+}
+
+func TestWatchStateResume(t *testing.T) {
+	state := make(watchState)
+	state.apply([]fs.DocumentChange{
+		{Kind: fs.DocumentAdded, Doc: snapshot("a", 1)},
+		{Kind: fs.DocumentAdded, Doc: snapshot("b", 1)},
+		{Kind: fs.DocumentAdded, Doc: snapshot("c", 1)},
+	})
+	got := kinds(state.resume([]*fs.DocumentSnapshot{snapshot("a", 1), snapshot("b", 2), snapshot("d", 1)}))
+	want := map[string]ChangeKind{"b": ChangeKinds.Modified, "c": ChangeKinds.Removed, "d": ChangeKinds.Added}
+	if len(got) != len(want) {
+		t.Fatalf("resume() = %v, want %v", got, want)
+	}
+	for id, kind := range want {
+		if got[id] != kind {
+			t.Errorf("resume() %s = %q, want %q", id, got[id], kind)
+		}
+	}
+	if len(state) != 3 {
+		t.Errorf("state after resume() has %d documents, want 3", len(state))
+	}
+}
+
+func TestWatchReopensAfterTransientErrors(t *testing.T) {
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	opened, stopped := 0, 0
+	events := []error{nil, status.Error(codes.Unavailable, "gone"), nil, status.Error(codes.PermissionDenied, "denied")}
+	resumedAt := make([]bool, 0)
+	changes := watch(ctx, DocSnapShotToType[struct{}], func() (nextChanges, func()) {
+		opened++
+		return func(state watchState, resumed bool) ([]docChange, time.Time, error) {
+			event := events[0]
+			events = events[1:]
+			if event != nil {
+				return nil, time.Time{}, event
+			}
+			resumedAt = append(resumedAt, resumed)
+			return []docChange{{kind: ChangeKinds.Added, id: "a"}}, time.Time{}, nil
+		}, func() { stopped++ }
+	})
+
+	count := 0
+	var last error
+	for change, err := range changes {
+		if err != nil {
+			last = err
+			continue
+		}
+		if change.ID != "a" {
+			t.Errorf("change ID = %q, want a", change.ID)
+		}
+		count++
+	}
+	if count != 2 || status.Code(last) != codes.PermissionDenied {
+		t.Errorf("watch() yielded %d changes and %v, want 2 and PermissionDenied", count, last)
+	}
+	if opened != 2 || stopped != 2 {
+		t.Errorf("watch() opened %d and stopped %d listeners, want 2 and 2", opened, stopped)
+	}
+	if len(resumedAt) != 2 || resumedAt[0] || !resumedAt[1] {
+		t.Errorf("resumed = %v, want [false true]", resumedAt)
+	}
+}
+
+func TestWatchStopsWhenContextIsDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(t.Context())
+	changes := watch(ctx, DocSnapShotToType[struct{}], func() (nextChanges, func()) {
+		return func(state watchState, resumed bool) ([]docChange, time.Time, error) {
+			cancel()
+			return nil, time.Time{}, status.Error(codes.Canceled, "canceled")
+		}, func() {}
+	})
+	for _, err := range changes {
+		t.Errorf("watch() after cancel yielded %v, want nothing", err)
+	}
+}
@@ -0,0 +1,68 @@
+package gin
+
+import (
+	"fmt"
+	"strconv"
+
+	g "github.com/gin-gonic/gin"
+	"github.com/joomcode/errorx"
+
+	errs "github.com/jarrodhroberson/ossgo/errors"
+)
+
+const PAGE_SIZE_PARAM = "page_size"
+const PAGE_TOKEN_PARAM = "page_token"
+
+// GetPageToken returns the page_token query parameter, an empty string requests the first page.
+func GetPageToken(c *g.Context) string {
+	return c.Query(PAGE_TOKEN_PARAM)
+}
+
+// GetPageSize returns the page_size query parameter, or defaultSize if it is not present.
+// A page_size that is not a number or is less than 1 is an IllegalArgument error,
+// a page_size larger than maxSize is reduced to maxSize.
+func GetPageSize(c *g.Context, defaultSize int, maxSize int) (int, error) {
+	value, exists := c.GetQuery(PAGE_SIZE_PARAM)
+	if !exists || value == "" {
+		return min(defaultSize, maxSize), nil
+	}
+	size, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, errorx.IllegalArgument.Wrap(err, "%s %q is not a number", PAGE_SIZE_PARAM, value)
+	}
+	if size < 1 {
+		return 0, errs.MinSizeExceededError.New("%s %d must be >= 1", PAGE_SIZE_PARAM, size)
+	}
+	return min(size, maxSize), nil
+}
+
+// GetPage returns the page size and page token query parameters, see GetPageSize and GetPageToken.
+//
+// Example usage:
+//
+//	size, token, err := gin.GetPage(c, 50, 500)
+//	if err != nil {
+//		c.AbortWithError(http.StatusBadRequest, err)
+//		return
+//	}
+//	page, err := store.Page(c, query, size, token)
+func GetPage(c *g.Context, defaultSize int, maxSize int) (int, string, error) {
+	size, err := GetPageSize(c, defaultSize, maxSize)
+	if err != nil {
+		return 0, "", err
+	}
+	return size, GetPageToken(c), nil
+}
+
+// SetNextPageLink sets a Link header with rel="next" pointing at the current request with page_token
+// replaced by nextPageToken. Nothing is set when nextPageToken is empty, there is no next page.
+func SetNextPageLink(c *g.Context, nextPageToken string) {
+	if nextPageToken == "" {
+		return
+	}
+	next := *c.Request.URL
+	query := next.Query()
+	query.Set(PAGE_TOKEN_PARAM, nextPageToken)
+	next.RawQuery = query.Encode()
+	c.Header("Link", fmt.Sprintf("<%s>; rel=\"next\"", next.String()))
+}
@@ -231,18 +231,32 @@ func getSecretLatestVersion(ctx context.Context, name string) (int, error) {
 // exists. The version can be a version number as a string (e.g. "5") or an
 // alias (e.g. "latest").
 func GetSecretValue(ctx context.Context, name string) ([]byte, error) {
+	_, value, err := GetSecretVersionValue(ctx, name, latestVersion)
+	return value, err
+}
+
+// GetSecretVersionValue accesses the payload of a specific version of the secret, version 0 is the latest version.
+// The returned Path has the number of the version that was accessed, for the latest version as well, so a
+// value can be stored with the version it came from and that version accessed again after the secret is rotated.
+func GetSecretVersionValue(ctx context.Context, name string, version int) (Path, []byte, error) {
 	if !isValidSecretName(name) {
 		err := errorx.IllegalArgument.New("invalid secret name: %s", name)
 		err = errs.RegExDoesNotMatch.Wrap(err, "secret name %s does not match the validSecretNameRegex %s", name, validSecretNameRegex)
-		return nil, err
+		return Path{}, nil, err
+	}
+	if version < latestVersion {
+		return Path{}, nil, secretVersionNotInValidRange.New("secret version %d must be >= 0", version)
 	}
 	path := buildPathToSecretWithLatest(name)
+	if version != latestVersion {
+		path = buildPathToSecretWithVersion(name, version)
+	}
 	if !validSecretPathWithVersionRegex.MatchString(path) {
-		return nil, errorx.IllegalState.New("%s does not match the validPathWithVersionPattern %s", path, validPathWithVersionPattern)
+		return Path{}, nil, errorx.IllegalState.New("%s does not match the validPathWithVersionPattern %s", path, validPathWithVersionPattern)
 	}
 	client, err := secretmanager.NewClient(ctx)
 	if err != nil {
-		return nil, errorx.InitializationFailed.Wrap(err, "failed to create secretmanager client")
+		return Path{}, nil, errorx.InitializationFailed.Wrap(err, "failed to create secretmanager client")
 	}
 	defer func(client *secretmanager.Client) {
 		err := client.Close()
@@ -257,10 +271,10 @@ func GetSecretValue(ctx context.Context, name string) ([]byte, error) {
 
 	result, err := client.AccessSecretVersion(ctx, req)
 	if err != nil {
-		return nil, errorx.DataUnavailable.Wrap(err, "failed to access secret version: %s", req.GetName())
+		return Path{}, nil, errorx.DataUnavailable.Wrap(err, "failed to access secret version: %s", req.GetName())
 	}
 
-	return result.Payload.Data, nil
+	return parsePathFrom(&secretmanagerpb.SecretVersion{Name: result.GetName()}), result.GetPayload().GetData(), nil
 }
 
 // CreateSecret creates a new secret.
@@ -10,8 +10,6 @@ import (
 	"strings"
 	"time"
 
-	"github.com/jarrodhroberson/ossgo/functions/must"
-
 	"cloud.google.com/go/firestore"
 
 	fs "github.com/jarrodhroberson/ossgo/firestore"
@@ -132,21 +130,14 @@ func initFirebaseApp(ctx context.Context, database string) *firestore.Client {
 // shouldSend checks if the email should be sent based on the existence of a
 // document in Firestore and a lease mechanism to prevent duplicates.
 func shouldSend(ctx context.Context, eventID string) error {
-	client := must.Must(fs.Client(ctx, "sendgrid"))
-	defer func(db *firestore.Client) {
-		err := db.Close()
-		if err != nil {
-			log.Error().Stack().Err(err).Msg("could not close firebase client")
-		}
-	}(client)
-
-	emailRef := client.Collection("sent").Doc(eventID)
-	return client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+	return fs.RunInTransaction(ctx, "sendgrid", func(uow fs.UnitOfWork) error {
+		tx := uow.Transaction()
+		emailRef := uow.Client().Collection("sent").Doc(eventID)
 		docSnapshot, err := tx.Get(emailRef)
-		if err != nil {
+		if err != nil && !fs.IsNotFound(err) {
 			return err
 		}
-		if docSnapshot.Exists() {
+		if docSnapshot != nil && docSnapshot.Exists() {
 			data := docSnapshot.Data()
 			if data["sent"] == true {
 				return nil
@@ -157,25 +148,17 @@ func shouldSend(ctx context.Context, eventID string) error {
 				return fmt.Errorf("lease already taken, try later.")
 			}
 		}
-		err = tx.Set(emailRef, map[string]interface{}{"lease": time.Now().Add(LeaseTime)})
-		if err != nil {
-			return err
-		}
-		return nil
+		return tx.Set(emailRef, map[string]interface{}{"lease": time.Now().Add(LeaseTime)})
 	})
 }
 
 // markSent marks the email as sent in Firestore.
 func markSent(ctx context.Context, eventID string) error {
-	client := must.Must(fs.Client(ctx, "sendgrid"))
-	defer func(client *firestore.Client) {
-		err := client.Close()
-		if err != nil {
-			log.Error().Stack().Err(err).Msg("could not close firebase client")
-		}
-	}(client)
-	//emailRef := client.Collection("sent").Doc(eventID)
-	_, err := client.Collection("sent").Doc(eventID).Set(ctx, map[string]interface{}{"sent": true})
+	client, err := fs.DefaultClientRegistry.Get(ctx, "sendgrid")
+	if err != nil {
+		return err
+	}
+	_, err = client.Collection("sent").Doc(eventID).Set(ctx, map[string]interface{}{"sent": true})
 	return err
 }
 
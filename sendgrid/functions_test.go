@@ -1,7 +1,10 @@
 package sendgrid
 
 import (
+	"fmt"
+	"os"
 	"testing"
+	"time"
 )
 
 func TestIsValidDomain(t *testing.T) {
@@ -203,3 +206,29 @@ func TestIsEmailAddressFormatValid(t *testing.T) {
 		})
 	}
 }
+
+// TestShouldSend runs against the Firestore emulator, start it with "gcloud emulators firestore start"
+// and set FIRESTORE_EMULATOR_HOST.
+func TestShouldSend(t *testing.T) {
+	if os.Getenv("FIRESTORE_EMULATOR_HOST") == "" {
+		t.Skip("FIRESTORE_EMULATOR_HOST is not set")
+	}
+	if os.Getenv("GOOGLE_CLOUD_PROJECT") == "" {
+		t.Setenv("GOOGLE_CLOUD_PROJECT", "ossgo-sendgrid")
+	}
+	eventID := fmt.Sprintf("event_%d", time.Now().UnixNano())
+
+	// the first delivery of an event has no document yet, it takes the lease
+	if err := shouldSend(t.Context(), eventID); err != nil {
+		t.Fatalf("shouldSend() of a new event error = %v", err)
+	}
+	if err := shouldSend(t.Context(), eventID); err == nil {
+		t.Error("shouldSend() while the lease is taken error = nil")
+	}
+	if err := markSent(t.Context(), eventID); err != nil {
+		t.Fatalf("markSent() error = %v", err)
+	}
+	if err := shouldSend(t.Context(), eventID); err != nil {
+		t.Errorf("shouldSend() of a sent event error = %v", err)
+	}
+}
@@ -0,0 +1,162 @@
+package seq
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"sync"
+)
+
+// FromChan returns a sequence of the values received from ch until it is closed.
+// Stopping the iteration early does not drain or close ch, it is left to the sender.
+// Ranging over a channel that is never closed blocks forever, use WithContext or a
+// context aware producer like ToChan to make sure it is closed.
+func FromChan[T any](ch <-chan T) iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for t := range ch {
+			if !yield(t) {
+				return
+			}
+		}
+	}
+}
+
+// ToChan ranges over the sequence in a new goroutine and sends each element to the returned channel,
+// which is buffered with bufSize elements. The channel is closed once the sequence ends.
+//
+// When ctx is done the goroutine stops reading the sequence and closes the channel, so receivers that
+// stop early must cancel ctx to avoid leaking the goroutine. Receiving until the channel is closed is
+// always safe.
+//
+// Example usage:
+//
+//	ctx, cancel := context.WithCancel(ctx)
+//	defer cancel()
+//	for u := range seq.ToChan(ctx, users, 10) {
+//		// receive users while the next 10 are read ahead
+//	}
+func ToChan[T any](ctx context.Context, it iter.Seq[T], bufSize int) <-chan T {
+	ch := make(chan T, max(0, bufSize))
+	go func() {
+		defer close(ch)
+		for t := range it {
+			select {
+			case ch <- t:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch
+}
+
+// Tee fans a single sequence out to n sequences that each yield every element of the source.
+// The source is read once, by a goroutine that is started when the first of the returned sequences
+// is iterated, and each element is sent to a channel per consumer buffered with bufSize elements.
+//
+// The buffers are bounded so the source is read no further ahead than the slowest consumer plus bufSize.
+// Every returned sequence must therefore be iterated concurrently, or ctx canceled, otherwise the others
+// stall once the buffer of the idle one is full. A consumer that stops iterating early is detached and no
+// longer receives elements, once every consumer has stopped the source is no longer read and the
+// goroutine exits. When ctx is done the goroutine exits and all the sequences end.
+//
+// The returned sequences can only be iterated once, Tee returns nil if n is less than 1.
+func Tee[T any](ctx context.Context, it iter.Seq[T], n int, bufSize int) []iter.Seq[T] {
+	seqs, _ := tee(ctx, it, n, bufSize)
+	return seqs
+}
+
+// tee implements Tee, it also returns a function per sequence that detaches its consumer
+// even if the sequence was never iterated.
+func tee[T any](ctx context.Context, it iter.Seq[T], n int, bufSize int) ([]iter.Seq[T], []func()) {
+	if n < 1 {
+		return nil, nil
+	}
+	channels := make([]chan T, n)
+	stopped := make([]chan struct{}, n)
+	for i := range n {
+		channels[i] = make(chan T, max(0, bufSize))
+		stopped[i] = make(chan struct{})
+	}
+
+	var start sync.Once
+	produce := func() {
+		go func() {
+			defer func() {
+				for _, ch := range channels {
+					close(ch)
+				}
+			}()
+			detached := make([]bool, n)
+			active := n
+			for t := range it {
+				for i, ch := range channels {
+					if detached[i] {
+						continue
+					}
+					select {
+					case ch <- t:
+					case <-stopped[i]:
+						detached[i] = true
+						active--
+					case <-ctx.Done():
+						return
+					}
+				}
+				if active == 0 {
+					return
+				}
+			}
+		}()
+	}
+
+	seqs := make([]iter.Seq[T], n)
+	detach := make([]func(), n)
+	for i := range n {
+		detach[i] = sync.OnceFunc(func() { close(stopped[i]) })
+		seqs[i] = func(yield func(T) bool) {
+			start.Do(produce)
+			defer detach[i]()
+			for t := range channels[i] {
+				if !yield(t) {
+					return
+				}
+			}
+		}
+	}
+	return seqs, detach
+}
+
+// Broadcast fans the sequence out to each of the consumers with Tee and runs every consumer in its own goroutine.
+// It waits for all the consumers to return and returns their errors joined with errors.Join.
+//
+// As soon as one consumer returns an error or panics the context passed to the others is canceled,
+// a panic is recovered and returned as a WorkerPanicError. If ctx is done the cause is also returned.
+//
+// Example usage, writing the same documents to two destinations:
+//
+//	err := seq.Broadcast(ctx, docs, 100,
+//		func(ctx context.Context, it iter.Seq[*Doc]) error { return seq.NdJsonMarshal(file, it) },
+//		func(ctx context.Context, it iter.Seq[*Doc]) error { return index(ctx, it) },
+//	)
+func Broadcast[T any](ctx context.Context, it iter.Seq[T], bufSize int, consumers ...func(ctx context.Context, it iter.Seq[T]) error) error {
+	consumerCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	seqs, detach := tee(consumerCtx, it, len(consumers), bufSize)
+	results := make([]error, len(consumers))
+	var wg sync.WaitGroup
+	for i, consumer := range consumers {
+		wg.Go(func() {
+			_, results[i] = callSafely(consumerCtx, seqs[i], func(ctx context.Context, s iter.Seq[T]) (struct{}, error) {
+				return struct{}{}, consumer(ctx, s)
+			})
+			// a consumer that returns without reading every element must not stall the others
+			detach[i]()
+			if results[i] != nil {
+				cancel()
+			}
+		})
+	}
+	wg.Wait()
+	return errors.Join(append(results, contextDone(ctx))...)
+}
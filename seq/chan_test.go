@@ -0,0 +1,125 @@
+package seq
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"runtime"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestToChanFromChan(t *testing.T) {
+	got := slices.Collect(FromChan(ToChan(t.Context(), IntRange(1, 5), 2)))
+	if want := []int{1, 2, 3, 4, 5}; !slices.Equal(got, want) {
+		t.Errorf("FromChan(ToChan()) = %v, want %v", got, want)
+	}
+
+	before := runtime.NumGoroutine()
+	ctx, cancel := context.WithCancel(t.Context())
+	for i := range ToChan(ctx, IntRange(0, 1_000_000), 0) {
+		if i == 3 {
+			break
+		}
+	}
+	cancel()
+	waitForGoroutines(t, before)
+}
+
+func TestTee(t *testing.T) {
+	seqs := Tee(t.Context(), IntRange(1, 100), 3, 4)
+	results := make([][]int, len(seqs))
+	var wg sync.WaitGroup
+	for i, s := range seqs {
+		wg.Go(func() {
+			for v := range s {
+				results[i] = append(results[i], v)
+				// the second consumer stops early and must not stall the others
+				if i == 1 && v == 10 {
+					return
+				}
+			}
+		})
+	}
+	wg.Wait()
+	if len(results[0]) != 100 || len(results[2]) != 100 {
+		t.Errorf("Tee() consumers got %d and %d elements, want 100", len(results[0]), len(results[2]))
+	}
+	if len(results[1]) != 10 {
+		t.Errorf("Tee() early consumer got %d elements, want 10", len(results[1]))
+	}
+}
+
+func TestBroadcast(t *testing.T) {
+	before := runtime.NumGoroutine()
+	var sum, count int
+	err := Broadcast(t.Context(), IntRange(1, 10), 1,
+		func(ctx context.Context, it iter.Seq[int]) error {
+			sum = Sum(it)
+			return nil
+		},
+		func(ctx context.Context, it iter.Seq[int]) error {
+			count = len(slices.Collect(it))
+			return nil
+		},
+		func(ctx context.Context, it iter.Seq[int]) error {
+			// never reads its sequence
+			return nil
+		},
+	)
+	if err != nil {
+		t.Fatalf("Broadcast() error = %v", err)
+	}
+	if sum != 55 || count != 10 {
+		t.Errorf("Broadcast() sum = %d count = %d, want 55 and 10", sum, count)
+	}
+
+	failed := errors.New("failed")
+	err = Broadcast(t.Context(), IntRange(1, 1_000_000), 1,
+		func(ctx context.Context, it iter.Seq[int]) error {
+			for range it {
+			}
+			return nil
+		},
+		func(ctx context.Context, it iter.Seq[int]) error {
+			for i := range it {
+				if i == 5 {
+					return failed
+				}
+			}
+			return nil
+		},
+	)
+	if !errors.Is(err, failed) {
+		t.Errorf("Broadcast() error = %v, want %v", err, failed)
+	}
+
+	err = Broadcast(t.Context(), IntRange(1, 1_000_000), 1,
+		func(ctx context.Context, it iter.Seq[int]) error {
+			for range it {
+			}
+			return nil
+		},
+		func(ctx context.Context, it iter.Seq[int]) error {
+			panic("boom")
+		},
+	)
+	if err == nil {
+		t.Error("Broadcast() with panicking consumer error = nil")
+	}
+	waitForGoroutines(t, before)
+}
+
+// waitForGoroutines fails the test if the number of goroutines does not drop back to n.
+func waitForGoroutines(t *testing.T, n int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > n {
+		if time.Now().After(deadline) {
+			t.Fatalf("goroutines = %d, want %d", runtime.NumGoroutine(), n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
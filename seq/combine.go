@@ -0,0 +1,189 @@
+package seq
+
+import (
+	"container/heap"
+	"iter"
+)
+
+// Zip pairs up the elements of two sequences by position, the first element of a with the first element of b
+// and so on. The sequence ends as soon as either of the sequences ends, use ZipLongest to keep going until
+// both have ended.
+//
+// Example usage:
+//
+//	for name, age := range seq.Zip(seq.ToSeq("alice", "bob", "carol"), seq.ToSeq(30, 40)) {
+//		fmt.Println(name, age) // alice 30, bob 40
+//	}
+func Zip[A any, B any](a iter.Seq[A], b iter.Seq[B]) iter.Seq2[A, B] {
+	return func(yield func(A, B) bool) {
+		nextB, stopB := iter.Pull(b)
+		defer stopB()
+		for va := range a {
+			vb, ok := nextB()
+			if !ok {
+				return
+			}
+			if !yield(va, vb) {
+				return
+			}
+		}
+	}
+}
+
+// ZipLongest is the same as Zip except it continues until both sequences have ended.
+// Once one of the sequences has ended its fill value is used in place of its elements.
+//
+// Example usage:
+//
+//	for name, age := range seq.ZipLongest(seq.ToSeq("alice", "bob", "carol"), seq.ToSeq(30, 40), "", -1) {
+//		fmt.Println(name, age) // alice 30, bob 40, carol -1
+//	}
+func ZipLongest[A any, B any](a iter.Seq[A], b iter.Seq[B], fillA A, fillB B) iter.Seq2[A, B] {
+	return func(yield func(A, B) bool) {
+		nextA, stopA := iter.Pull(a)
+		defer stopA()
+		nextB, stopB := iter.Pull(b)
+		defer stopB()
+		for {
+			va, okA := nextA()
+			vb, okB := nextB()
+			if !okA && !okB {
+				return
+			}
+			if !okA {
+				va = fillA
+			}
+			if !okB {
+				vb = fillB
+			}
+			if !yield(va, vb) {
+				return
+			}
+		}
+	}
+}
+
+// Zip2 is the iter.Seq2 version of Zip, each key value pair is yielded as a Pair.
+func Zip2[K1 any, V1 any, K2 any, V2 any](a iter.Seq2[K1, V1], b iter.Seq2[K2, V2]) iter.Seq2[Pair[K1, V1], Pair[K2, V2]] {
+	return Zip(pairs(a), pairs(b))
+}
+
+// Zip2Longest is the iter.Seq2 version of ZipLongest, each key value pair is yielded as a Pair.
+func Zip2Longest[K1 any, V1 any, K2 any, V2 any](a iter.Seq2[K1, V1], b iter.Seq2[K2, V2], fillA Pair[K1, V1], fillB Pair[K2, V2]) iter.Seq2[Pair[K1, V1], Pair[K2, V2]] {
+	return ZipLongest(pairs(a), pairs(b), fillA, fillB)
+}
+
+// Interleave takes one element from each sequence in turn, round-robin, until all the sequences have ended.
+// Sequences that end early are skipped for the rest of the iteration.
+//
+// Example usage:
+//
+//	for i := range seq.Interleave(seq.ToSeq(1, 4), seq.ToSeq(2, 5, 7), seq.ToSeq(3, 6)) {
+//		fmt.Println(i) // 1, 2, 3, 4, 5, 6, 7
+//	}
+func Interleave[T any](iterSeqs ...iter.Seq[T]) iter.Seq[T] {
+	return func(yield func(T) bool) {
+		nexts := make([]func() (T, bool), 0, len(iterSeqs))
+		for _, is := range iterSeqs {
+			next, stop := iter.Pull(is)
+			defer stop()
+			nexts = append(nexts, next)
+		}
+		for len(nexts) > 0 {
+			active := nexts[:0]
+			for _, next := range nexts {
+				v, ok := next()
+				if !ok {
+					continue
+				}
+				active = append(active, next)
+				if !yield(v) {
+					return
+				}
+			}
+			nexts = active
+		}
+	}
+}
+
+// mergeHead is the current smallest element of one of the sources being merged by MergeSorted.
+type mergeHead[T any] struct {
+	value  T
+	source int
+	next   func() (T, bool)
+}
+
+// mergeHeap is a container/heap of the current element of each of the sources being merged.
+// Ties are broken by the position of the source so the merge is stable.
+type mergeHeap[T any] struct {
+	heads   []mergeHead[T]
+	compare func(a T, b T) int
+}
+
+func (h *mergeHeap[T]) Len() int {
+	return len(h.heads)
+}
+
+func (h *mergeHeap[T]) Less(i, j int) bool {
+	if c := h.compare(h.heads[i].value, h.heads[j].value); c != 0 {
+		return c < 0
+	}
+	return h.heads[i].source < h.heads[j].source
+}
+
+func (h *mergeHeap[T]) Swap(i, j int) {
+	h.heads[i], h.heads[j] = h.heads[j], h.heads[i]
+}
+
+func (h *mergeHeap[T]) Push(x any) {
+	h.heads = append(h.heads, x.(mergeHead[T]))
+}
+
+func (h *mergeHeap[T]) Pop() any {
+	last := h.heads[len(h.heads)-1]
+	h.heads = h.heads[:len(h.heads)-1]
+	return last
+}
+
+// MergeSorted performs a k-way merge of sequences that are each already sorted according to compare,
+// producing a single sorted sequence. compare follows the same contract as cmp.Compare, a negative
+// number when a < b, zero when they are equal and a positive number when a > b.
+//
+// Only one element per source is held in memory at a time, the sources are read with iter.Pull
+// as their elements are needed so MergeSorted works on sequences too large to collect.
+// Elements that compare equal are yielded in the order of the sources they came from.
+//
+// Example usage, merging ordered results from several Firestore collections:
+//
+//	byCreated := func(a, b *Order) int { return a.CreatedAt.Compare(b.CreatedAt) }
+//	for o := range seq.MergeSorted(byCreated, euOrders, usOrders, apacOrders) {
+//		fmt.Println(o)
+//	}
+func MergeSorted[T any](compare func(a T, b T) int, iterSeqs ...iter.Seq[T]) iter.Seq[T] {
+	return func(yield func(T) bool) {
+		h := &mergeHeap[T]{
+			heads:   make([]mergeHead[T], 0, len(iterSeqs)),
+			compare: compare,
+		}
+		for source, is := range iterSeqs {
+			next, stop := iter.Pull(is)
+			defer stop()
+			if v, ok := next(); ok {
+				h.heads = append(h.heads, mergeHead[T]{value: v, source: source, next: next})
+			}
+		}
+		heap.Init(h)
+		for h.Len() > 0 {
+			head := h.heads[0]
+			if !yield(head.value) {
+				return
+			}
+			if v, ok := head.next(); ok {
+				h.heads[0].value = v
+				heap.Fix(h, 0)
+			} else {
+				heap.Pop(h)
+			}
+		}
+	}
+}
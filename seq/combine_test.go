@@ -0,0 +1,44 @@
+package seq
+
+import (
+	"cmp"
+	"slices"
+	"testing"
+)
+
+func TestZip(t *testing.T) {
+	got := make([]string, 0)
+	for s, i := range Zip(ToSeq("a", "b", "c"), IntRange(1, 2)) {
+		got = append(got, s+string(rune('0'+i)))
+	}
+	if want := []string{"a1", "b2"}; !slices.Equal(got, want) {
+		t.Errorf("Zip() = %v, want %v", got, want)
+	}
+
+	got = got[:0]
+	for s, i := range ZipLongest(ToSeq("a", "b", "c"), IntRange(1, 2), "", 0) {
+		got = append(got, s+string(rune('0'+i)))
+	}
+	if want := []string{"a1", "b2", "c0"}; !slices.Equal(got, want) {
+		t.Errorf("ZipLongest() = %v, want %v", got, want)
+	}
+}
+
+func TestInterleave(t *testing.T) {
+	got := slices.Collect(Interleave(ToSeq(1, 4), ToSeq(2, 5, 7), ToSeq(3, 6)))
+	if want := []int{1, 2, 3, 4, 5, 6, 7}; !slices.Equal(got, want) {
+		t.Errorf("Interleave() = %v, want %v", got, want)
+	}
+}
+
+func TestMergeSorted(t *testing.T) {
+	got := slices.Collect(MergeSorted(cmp.Compare[int], ToSeq(1, 4, 9), ToSeq(2, 3, 10, 11), Empty[int](), ToSeq(0, 4)))
+	if want := []int{0, 1, 2, 3, 4, 4, 9, 10, 11}; !slices.Equal(got, want) {
+		t.Errorf("MergeSorted() = %v, want %v", got, want)
+	}
+
+	got = slices.Collect(FirstN(MergeSorted(cmp.Compare[int], IntRange(0, 1_000_000), IntRange(0, 1_000_000)), 3))
+	if want := []int{0, 0, 1}; !slices.Equal(got, want) {
+		t.Errorf("MergeSorted() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,365 @@
+package seq
+
+import (
+	"encoding"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	errs "github.com/jarrodhroberson/ossgo/errors"
+	"github.com/jarrodhroberson/ossgo/structs"
+)
+
+// CSV_TAG is the struct tag used to map struct fields to columns.
+// The first value is the column name, it defaults to the field name when it is empty.
+// A field is not mapped to a column if its tag is "-" or includes structs.Ignore.
+//
+//	type Partner struct {
+//		ID       string               `csv:"partner_id"`
+//		Name     string               `csv:"name"`
+//		JoinedAt *timestamp.Timestamp `csv:"joined_at"`
+//		Internal string               `csv:",ignore"`
+//	}
+const CSV_TAG = "csv"
+
+// csvHeader determines if the first row is a header row.
+type csvHeader int
+
+const (
+	detectHeader csvHeader = iota
+	withHeader
+	withoutHeader
+)
+
+type csvConfig struct {
+	delimiter  rune
+	header     csvHeader
+	lazyQuotes bool
+}
+
+// CsvOption configures CsvUnmarshal and CsvMarshal.
+type CsvOption func(c *csvConfig)
+
+// WithDelimiter sets the field delimiter, the default is a comma.
+func WithDelimiter(delimiter rune) CsvOption {
+	return func(c *csvConfig) {
+		c.delimiter = delimiter
+	}
+}
+
+// WithHeader treats the first row as a header row, it is the default for CsvMarshal.
+func WithHeader() CsvOption {
+	return func(c *csvConfig) {
+		c.header = withHeader
+	}
+}
+
+// WithoutHeader treats the first row as data, columns are mapped to the fields in the order they are declared.
+func WithoutHeader() CsvOption {
+	return func(c *csvConfig) {
+		c.header = withoutHeader
+	}
+}
+
+func newCsvConfig(options ...CsvOption) *csvConfig {
+	c := &csvConfig{delimiter: ','}
+	for _, option := range options {
+		option(c)
+	}
+	return c
+}
+
+// csvColumn maps a column to the field of the struct it is read into and written from.
+type csvColumn struct {
+	name  string
+	field int
+}
+
+// csvColumns returns the columns of struct type t using the CSV_TAG from structs.Tags.
+func csvColumns(t reflect.Type) ([]csvColumn, error) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil, errs.InvalidData.New("%s is not a struct and can not be mapped to csv columns", t)
+	}
+	tags := structs.Tags(reflect.New(t).Interface())
+	columns := make([]csvColumn, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name := field.Name
+		if tag, ok := structs.FindTag(tags[field.Name], CSV_TAG); ok {
+			if tag.Has("-") || tag.Has(structs.Ignore) {
+				continue
+			}
+			if len(tag.Values) > 0 && tag.Values[0] != "" {
+				name = tag.Values[0]
+			}
+		}
+		columns = append(columns, csvColumn{name: name, field: i})
+	}
+	return columns, nil
+}
+
+// setCsvField parses s into the field v, empty strings leave non string fields at their zero value.
+func setCsvField(v reflect.Value, s string) error {
+	if v.Kind() == reflect.Pointer {
+		if s == "" {
+			return nil
+		}
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return setCsvField(v.Elem(), s)
+	}
+	if tu, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		if s == "" {
+			return nil
+		}
+		return tu.UnmarshalText([]byte(s))
+	}
+	if v.Kind() == reflect.String {
+		v.SetString(s)
+		return nil
+	}
+	if s == "" {
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	default:
+		return fmt.Errorf("unsupported field type %s", v.Type())
+	}
+	return nil
+}
+
+// formatCsvField is the inverse of setCsvField.
+func formatCsvField(v reflect.Value) (string, error) {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return "", nil
+		}
+		v = v.Elem()
+	}
+	// copy the value so methods with pointer receivers, like timestamp.Timestamp, are found
+	pv := reflect.New(v.Type())
+	pv.Elem().Set(v)
+	if tm, ok := pv.Interface().(encoding.TextMarshaler); ok {
+		b, err := tm.MarshalText()
+		return string(b), err
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, v.Type().Bits()), nil
+	default:
+		return "", fmt.Errorf("unsupported field type %s", v.Type())
+	}
+}
+
+// isCsvHeader reports if every value of record is the name of one of the columns.
+func isCsvHeader(record []string, columns []csvColumn) bool {
+	for _, value := range record {
+		if !slices.ContainsFunc(columns, func(column csvColumn) bool { return strings.TrimSpace(value) == column.name }) {
+			return false
+		}
+	}
+	return true
+}
+
+// CsvUnmarshal reads CSV from r one row at a time and yields each row decoded into a new *T.
+// T must be a struct, its fields are mapped to columns with the CSV_TAG struct tag.
+//
+// By default the first row is treated as a header if every one of its values is the name of a column,
+// columns are then matched to fields by name. Without a header the columns are mapped to the fields in
+// the order they are declared. Use WithHeader for a header that has columns that do not match a field,
+// they are ignored, WithoutHeader to turn off the detection and WithDelimiter for other delimiters.
+//
+// Fields of type string, bool, the integer and float types, pointers to those and any type implementing
+// encoding.TextUnmarshaler, like time.Time and timestamp.Timestamp, are supported.
+//
+// A malformed row, wrong number of fields, bad quoting or a value that can not be parsed into its field,
+// is yielded as a CsvRowError that includes the line number and reading continues with the next row.
+// Stop iterating to fail on the first malformed row.
+//
+// Example usage:
+//
+//	for p, err := range seq.CsvUnmarshal[Partner](reader) {
+//		if err != nil {
+//			log.Warn().Err(err).Msg(err.Error())
+//			continue
+//		}
+//		fmt.Println(p.Name)
+//	}
+func CsvUnmarshal[T any](r io.Reader, options ...CsvOption) iter.Seq2[*T, error] {
+	return func(yield func(*T, error) bool) {
+		config := newCsvConfig(options...)
+		columns, err := csvColumns(reflect.TypeFor[T]())
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+
+		cr := csv.NewReader(r)
+		cr.Comma = config.delimiter
+		cr.LazyQuotes = config.lazyQuotes
+		// mapping is the column mapped to each position in the row, nil for positions that are not mapped
+		var mapping []*csvColumn
+		for {
+			record, err := cr.Read()
+			if errors.Is(err, io.EOF) {
+				return
+			}
+			var parseError *csv.ParseError
+			if err != nil && !errors.As(err, &parseError) {
+				yield(nil, CsvRowError.Wrap(err, "failed to read csv"))
+				return
+			}
+			if parseError != nil {
+				if !yield(nil, CsvRowError.Wrap(err, "malformed row at line %d", parseError.StartLine)) {
+					return
+				}
+				continue
+			}
+
+			if mapping == nil {
+				if config.header == withHeader || (config.header == detectHeader && isCsvHeader(record, columns)) {
+					mapping = make([]*csvColumn, len(record))
+					for i, value := range record {
+						for c := range columns {
+							if strings.TrimSpace(value) == columns[c].name {
+								mapping[i] = &columns[c]
+							}
+						}
+					}
+					continue
+				}
+				mapping = make([]*csvColumn, len(columns))
+				for c := range columns {
+					mapping[c] = &columns[c]
+				}
+			}
+
+			t := new(T)
+			v := reflect.ValueOf(t).Elem()
+			for i, value := range record {
+				if i >= len(mapping) || mapping[i] == nil {
+					continue
+				}
+				if err = setCsvField(v.Field(mapping[i].field), value); err != nil {
+					line, column := cr.FieldPos(i)
+					err = CsvRowError.Wrap(err, "could not parse %q into column %s at line %d column %d", value, mapping[i].name, line, column)
+					break
+				}
+			}
+			if err != nil {
+				t = nil
+			}
+			if !yield(t, err) {
+				return
+			}
+		}
+	}
+}
+
+// TsvUnmarshal is CsvUnmarshal for tab separated values.
+// Quotes inside a field are kept as they are so fields can contain them without being quoted,
+// a field that starts with a quote is still read as a quoted field.
+func TsvUnmarshal[T any](r io.Reader, options ...CsvOption) iter.Seq2[*T, error] {
+	return CsvUnmarshal[T](r, append([]CsvOption{WithDelimiter('\t'), func(c *csvConfig) { c.lazyQuotes = true }}, options...)...)
+}
+
+// CsvMarshal writes the elements of the sequence to w as CSV, one row per element.
+// T must be a struct or a pointer to a struct, it is mapped to columns the same way as CsvUnmarshal.
+// A header row with the column names is written first unless WithoutHeader is given.
+//
+// Like the JSON functions the elements are written one at a time, so the sequence is never held in memory.
+// If formatting a field or the writer fails, iteration stops and an error is returned.
+func CsvMarshal[T any](w io.Writer, seq iter.Seq[T], options ...CsvOption) error {
+	config := newCsvConfig(options...)
+	columns, err := csvColumns(reflect.TypeFor[T]())
+	if err != nil {
+		return err
+	}
+
+	cw := csv.NewWriter(w)
+	cw.Comma = config.delimiter
+	record := make([]string, len(columns))
+	if config.header != withoutHeader {
+		for i, column := range columns {
+			record[i] = column.name
+		}
+		if err = cw.Write(record); err != nil {
+			return errs.MarshalError.New("failed to write csv header: %s", err)
+		}
+	}
+
+	row := 0
+	for t := range seq {
+		row++
+		v := reflect.ValueOf(t)
+		for v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				return errs.MustNotBeNil.New("element %d is nil", row)
+			}
+			v = v.Elem()
+		}
+		for i, column := range columns {
+			if record[i], err = formatCsvField(v.Field(column.field)); err != nil {
+				return errs.MarshalError.Wrap(err, "could not format column %s of element %d", column.name, row)
+			}
+		}
+		if err = cw.Write(record); err != nil {
+			return errs.MarshalError.New("failed to write csv row %d: %s", row, err)
+		}
+	}
+	cw.Flush()
+	if err = cw.Error(); err != nil {
+		return errs.MarshalError.New("failed to flush csv writer: %s", err)
+	}
+	return nil
+}
+
+// TsvMarshal is CsvMarshal for tab separated values.
+func TsvMarshal[T any](w io.Writer, seq iter.Seq[T], options ...CsvOption) error {
+	return CsvMarshal(w, seq, append([]CsvOption{WithDelimiter('\t')}, options...)...)
+}
@@ -0,0 +1,104 @@
+package seq
+
+import (
+	"bytes"
+	"io"
+	"iter"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jarrodhroberson/ossgo/timestamp"
+)
+
+type partner struct {
+	ID       string               `csv:"partner_id"`
+	Name     string               `csv:"name"`
+	Active   bool                 `csv:"active"`
+	Score    float64              `csv:"score"`
+	JoinedAt *timestamp.Timestamp `csv:"joined_at"`
+	Internal string               `csv:",ignore"`
+}
+
+func TestCsvUnmarshal(t *testing.T) {
+	joined := timestamp.From(time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC))
+	tests := []struct {
+		name      string
+		unmarshal func(io.Reader, ...CsvOption) iter.Seq2[*partner, error]
+		input     string
+		options   []CsvOption
+		want      []partner
+		wantErrs  int
+	}{
+		{
+			name:  "detected_header_out_of_order",
+			input: "name,partner_id,active\nAcme,p1,true\nGlobex,p2,false\n",
+			want:  []partner{{ID: "p1", Name: "Acme", Active: true}, {ID: "p2", Name: "Globex"}},
+		},
+		{
+			name:    "header_with_unknown_column",
+			input:   "name,partner_id,unknown,active\nAcme,p1,x,true\n",
+			options: []CsvOption{WithHeader()},
+			want:    []partner{{ID: "p1", Name: "Acme", Active: true}},
+		},
+		{
+			name:  "data_row_with_a_column_name",
+			input: "p1,name\np2,Globex\n",
+			want:  []partner{{ID: "p1", Name: "name"}, {ID: "p2", Name: "Globex"}},
+		},
+		{
+			name:  "no_header",
+			input: "p1,Acme,true,1.5,2024-03-01T00:00:00Z\n",
+			want:  []partner{{ID: "p1", Name: "Acme", Active: true, Score: 1.5, JoinedAt: joined}},
+		},
+		{
+			name:     "malformed_rows",
+			input:    "partner_id,score\np1,1\np2,abc\np3\np4,4\n",
+			want:     []partner{{ID: "p1", Score: 1}, {ID: "p4", Score: 4}},
+			wantErrs: 2,
+		},
+		{
+			name:      "tsv",
+			unmarshal: TsvUnmarshal[partner],
+			input:     "p1\tAc\"me\n",
+			options:   []CsvOption{WithoutHeader()},
+			want:      []partner{{ID: "p1", Name: "Ac\"me"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			unmarshal := tt.unmarshal
+			if unmarshal == nil {
+				unmarshal = CsvUnmarshal[partner]
+			}
+			got := make([]partner, 0)
+			errCount := 0
+			for p, err := range unmarshal(strings.NewReader(tt.input), tt.options...) {
+				if err != nil {
+					errCount++
+					continue
+				}
+				got = append(got, *p)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("CsvUnmarshal() = %+v, want %+v", got, tt.want)
+			}
+			if errCount != tt.wantErrs {
+				t.Errorf("CsvUnmarshal() errors = %d, want %d", errCount, tt.wantErrs)
+			}
+		})
+	}
+}
+
+func TestCsvMarshal(t *testing.T) {
+	var buf bytes.Buffer
+	partners := ToSeq(&partner{ID: "p1", Name: "Acme, Inc.", Active: true, Score: 2.5, Internal: "secret"})
+	if err := CsvMarshal(&buf, partners); err != nil {
+		t.Fatalf("CsvMarshal() error = %v", err)
+	}
+	want := "partner_id,name,active,score,joined_at\np1,\"Acme, Inc.\",true,2.5,\n"
+	if buf.String() != want {
+		t.Errorf("CsvMarshal() = %q, want %q", buf.String(), want)
+	}
+}
@@ -0,0 +1,8 @@
+package seq
+
+import (
+	errs "github.com/jarrodhroberson/ossgo/errors"
+)
+
+var WorkerPanicError = errs.IterationError.NewSubtype("Worker Panic")
+var CsvRowError = errs.ParseError.NewSubtype("Malformed CSV Row")
@@ -10,10 +10,8 @@ import (
 	"sync/atomic"
 
 	"github.com/jarrodhroberson/destruct/destruct"
-	"github.com/rs/zerolog/log"
 
 	errs "github.com/jarrodhroberson/ossgo/errors"
-	"github.com/jarrodhroberson/ossgo/functions/must"
 )
 
 // Empty returns an iter.Seq[T] that does not yield any items.
@@ -292,6 +290,8 @@ func SkipFirstN[T any](seq iter.Seq[T], skip int) iter.Seq[T] {
 
 // Chunk returns an iterator over consecutive sub-slices of up to n elements of s.
 // All but the last iter.Seq chunk will have size n.
+// Each chunk is read from s before it is yielded, so chunks can be iterated in any order,
+// more than once, or concurrently, for example by the workers of ParallelMap.
 // Chunk panics if n is less than 1.
 func Chunk[T any](sq iter.Seq[T], size int) iter.Seq[iter.Seq[T]] {
 	if size < 0 {
@@ -299,86 +299,54 @@ func Chunk[T any](sq iter.Seq[T], size int) iter.Seq[iter.Seq[T]] {
 	}
 
 	return func(yield func(s iter.Seq[T]) bool) {
-		next, stop := iter.Pull[T](sq)
-		defer stop()
-		endOfSeq := false
-		for !endOfSeq {
-			// get the first item for the chunk
-			v, ok := next()
-			// there are no more items !ok then exit loop
-			// this prevents returning an extra empty iter.Seq at end of Seq
-			if !ok {
-				break
-			}
-			// create the next sequence chunk
-			iterSeqChunk := func(yield func(T) bool) {
-				i := 0
-				for ; i < size; i++ {
-					if ok {
-						if !ok {
-							// end of original sequence
-							// this sequence may be <= size
-							endOfSeq = true
-							break
-						}
-
-						if !yield(v) {
-							return
-						}
-						v, ok = next()
-					}
+		chunk := make([]T, 0, size)
+		for v := range sq {
+			chunk = append(chunk, v)
+			if len(chunk) >= size {
+				if !yield(slices.Values(chunk)) {
+					return
 				}
+				chunk = make([]T, 0, size)
 			}
-			if !yield(iterSeqChunk) {
-				return
-			}
+		}
+		if len(chunk) > 0 {
+			yield(slices.Values(chunk))
 		}
 	}
 }
 
 // Chunk2 returns an iterator over consecutive sub-slices of up to n elements of s.
 // All but the last iter.Seq chunk will have size n.
+// Like Chunk, each chunk is read from s before it is yielded.
 // Chunk2 panics if n is less than 1.
 func Chunk2[K any, V any](sq iter.Seq2[K, V], size int) iter.Seq[iter.Seq2[K, V]] {
 	if size < 0 {
 		panic(errs.MinSizeExceededError.New("size %d must be >= 0", size))
 	}
 
-	return func(yield func(s iter.Seq2[K, V]) bool) {
-		next, stop := iter.Pull2[K, V](sq)
-		defer stop()
-		endOfSeq := false
-		for !endOfSeq {
-			// get the first item for the chunk
-			k, v, ok := next()
-			// there are no more items !ok then exit loop
-			// this prevents returning an extra empty iter.Seq at end of Seq
-			if !ok {
-				break
-			}
-			// create the next sequence chunk
-			iterSeqChunk := func(yield func(K, V) bool) {
-				i := 0
-				for ; i < size; i++ {
-					if ok {
-						if !ok {
-							// end of original sequence
-							// this sequence may be <= size
-							endOfSeq = true
-							break
-						}
-
-						if !yield(k, v) {
-							return
-						}
-						k, v, ok = next()
-					}
+	values := func(chunk []Pair[K, V]) iter.Seq2[K, V] {
+		return func(yield func(K, V) bool) {
+			for _, p := range chunk {
+				if !yield(p.Key, p.Value) {
+					return
 				}
 			}
-			if !yield(iterSeqChunk) {
-				return
+		}
+	}
+	return func(yield func(s iter.Seq2[K, V]) bool) {
+		chunk := make([]Pair[K, V], 0, size)
+		for k, v := range sq {
+			chunk = append(chunk, Pair[K, V]{Key: k, Value: v})
+			if len(chunk) >= size {
+				if !yield(values(chunk)) {
+					return
+				}
+				chunk = make([]Pair[K, V], 0, size)
 			}
 		}
+		if len(chunk) > 0 {
+			yield(values(chunk))
+		}
 	}
 }
 
@@ -546,6 +514,21 @@ func FlattenSeq[T any](iterSeqs ...iter.Seq[T]) iter.Seq[T] {
 	}
 }
 
+// Flatten takes an iter.Seq of batches (iter.Seq[T]) and flat maps all the batches
+// into a single iter.Seq. Unlike FlattenSeq the batches are produced lazily by an iter.Seq,
+// which makes it the inverse of Chunk.
+func Flatten[T any](iterSeqs iter.Seq[iter.Seq[T]]) iter.Seq[T] {
+	return func(yield func(t T) bool) {
+		for is := range iterSeqs {
+			for i := range is {
+				if !yield(i) {
+					return
+				}
+			}
+		}
+	}
+}
+
 // Sum calculates the sum of a sequence of numbers.
 //
 // Parameters:
@@ -584,6 +567,7 @@ func Sum[T Number](s iter.Seq[T]) T {
 // Notes:
 //   - The uniqueness of elements is determined using their hash identity via MustHashIdentity.
 //   - If elements cannot be hashed correctly, this function might panic.
+//   - Prefer Distinct for comparable types and UniqueBy when elements have a natural key, they do not hash.
 //
 // Example:
 //
@@ -752,39 +736,30 @@ func UnzipMap[K comparable, V any](m map[K]V) ([]K, []V) {
 func JsonMarshalCompact[T any](w io.Writer, seq iter.Seq[T]) error {
 	// no need to check if it is already a buffered writer, it does that already
 	// default size is 4096 bytes
-	w = bufio.NewWriter(w)
-	defer func() {
-		if err := w.(*bufio.Writer).Flush(); err != nil {
-			err = errs.MarshalError.New("failed to flush buffered writer: %s", err)
-			log.Warn().Err(err).Msg(err.Error())
-		}
-	}()
+	bw := bufio.NewWriter(w)
 
+	if _, err := bw.Write([]byte{'['}); err != nil {
+		return errs.MarshalError.New("failed to write opening bracket: %s", err)
+	}
 	first := true
-	next, stop := iter.Pull(seq)
-	defer stop()
-	for {
-		if t, ok := next(); ok {
-			if first {
-				if _, err := w.Write([]byte{'['}); err != nil {
-					return errs.MarshalError.New("failed to write opening bracket: %s", err)
-				}
-				first = false
-			} else {
-				if _, err := w.Write([]byte{','}); err != nil {
-					err = errs.MarshalError.New("failed to write comma: %s", err)
-					return err // Stop iteration on write error
-				}
-				_, err := w.Write(must.MarshalJson(t))
-				if err != nil {
-					return err
-				}
+	for t := range seq {
+		if !first {
+			if _, err := bw.Write([]byte{','}); err != nil {
+				return errs.MarshalError.New("failed to write comma: %s", err)
 			}
 		}
-		if _, err := w.Write([]byte{']'}); err != nil {
-			return errs.MarshalError.New("failed to write closing bracket: %s", err)
+		first = false
+		if err := writeJson(bw, t); err != nil {
+			return err // Stop iteration on write error
 		}
 	}
+	if _, err := bw.Write([]byte{']'}); err != nil {
+		return errs.MarshalError.New("failed to write closing bracket: %s", err)
+	}
+	if err := bw.Flush(); err != nil {
+		return errs.MarshalError.New("failed to flush buffered writer: %s", err)
+	}
+	return nil
 }
 
 // FilterErrors takes an iter.Seq2[K, error] and returns an iter.Seq[K] containing only the
@@ -874,9 +849,3 @@ func Values[K any, V any](s iter.Seq2[K, V]) iter.Seq[V] {
 		}
 	}
 }
-
-func Memoize[T any](s iter.Seq[T]) *MemoizeSeq[T] {
-	return &MemoizeSeq[T]{
-		delegate: slices.Collect(s),
-	}
-}
@@ -0,0 +1,218 @@
+package seq
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"iter"
+
+	errs "github.com/jarrodhroberson/ossgo/errors"
+)
+
+// writeJson marshals v and writes it to w without a trailing newline.
+func writeJson(w io.Writer, v any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return errs.MarshalError.Wrap(err, "failed to marshal %T", v)
+	}
+	if _, err = w.Write(b); err != nil {
+		return errs.MarshalError.New("failed to write %T: %s", v, err)
+	}
+	return nil
+}
+
+// NdJsonMarshal serializes the given sequence as newline delimited JSON (https://github.com/ndjson/ndjson-spec),
+// one compact JSON value per line, and writes it to the provided writer.
+//
+// Like JsonMarshalCompact the items are serialized one at a time through a buffered writer,
+// so the sequence is never held in memory. If marshaling or the writer fails, iteration stops and
+// an error is returned.
+//
+// Example:
+//
+//	var buf bytes.Buffer
+//	err := NdJsonMarshal(&buf, seq.IntRange(1, 3))
+//	fmt.Println(buf.String()) // Output: "1\n2\n3\n"
+func NdJsonMarshal[T any](w io.Writer, seq iter.Seq[T]) error {
+	bw := bufio.NewWriter(w)
+	for t := range seq {
+		if err := writeJson(bw, t); err != nil {
+			return err
+		}
+		if err := bw.WriteByte('\n'); err != nil {
+			return errs.MarshalError.New("failed to write newline: %s", err)
+		}
+	}
+	if err := bw.Flush(); err != nil {
+		return errs.MarshalError.New("failed to flush buffered writer: %s", err)
+	}
+	return nil
+}
+
+// JsonMarshalObject serializes the given key value sequence into a compact JSON object
+// and writes it to the provided writer, each key becomes a property name with its value as the property value.
+//
+// Keys are written in the order they are yielded and are not checked for duplicates.
+//
+// Example:
+//
+//	var buf bytes.Buffer
+//	err := JsonMarshalObject(&buf, maps.All(map[string]int{"a": 1}))
+//	fmt.Println(buf.String()) // Output: {"a":1}
+func JsonMarshalObject[V any](w io.Writer, seq iter.Seq2[string, V]) error {
+	bw := bufio.NewWriter(w)
+
+	if _, err := bw.Write([]byte{'{'}); err != nil {
+		return errs.MarshalError.New("failed to write opening brace: %s", err)
+	}
+	first := true
+	for k, v := range seq {
+		if !first {
+			if _, err := bw.Write([]byte{','}); err != nil {
+				return errs.MarshalError.New("failed to write comma: %s", err)
+			}
+		}
+		first = false
+		if err := writeJson(bw, k); err != nil {
+			return err
+		}
+		if _, err := bw.Write([]byte{':'}); err != nil {
+			return errs.MarshalError.New("failed to write colon: %s", err)
+		}
+		if err := writeJson(bw, v); err != nil {
+			return err
+		}
+	}
+	if _, err := bw.Write([]byte{'}'}); err != nil {
+		return errs.MarshalError.New("failed to write closing brace: %s", err)
+	}
+	if err := bw.Flush(); err != nil {
+		return errs.MarshalError.New("failed to flush buffered writer: %s", err)
+	}
+	return nil
+}
+
+// expectDelim reads the next token and checks that it is the expected delimiter.
+func expectDelim(dec *json.Decoder, delim json.Delim) error {
+	token, err := dec.Token()
+	if err != nil {
+		return errs.UnMarshalError.Wrap(err, "expected %s", delim)
+	}
+	if d, ok := token.(json.Delim); !ok || d != delim {
+		return errs.UnMarshalError.New("expected %s found %v", delim, token)
+	}
+	return nil
+}
+
+// JsonUnmarshalArray is the streaming inverse of JsonMarshalCompact.
+// It decodes a JSON array from r one element at a time, only the element being decoded is held in memory.
+//
+// Each element is yielded with a nil error. If the input is not a well formed JSON array, or an
+// element can not be decoded into T, the error is yielded and the sequence ends, because the
+// position in the stream can no longer be trusted.
+//
+// Example:
+//
+//	for u, err := range seq.JsonUnmarshalArray[User](resp.Body) {
+//		if err != nil {
+//			return err
+//		}
+//		fmt.Println(u.Name)
+//	}
+func JsonUnmarshalArray[T any](r io.Reader) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		dec := json.NewDecoder(r)
+		if err := expectDelim(dec, '['); err != nil {
+			yield(*new(T), err)
+			return
+		}
+		for dec.More() {
+			var t T
+			if err := dec.Decode(&t); err != nil {
+				yield(t, errs.UnMarshalError.Wrap(err, "failed to decode array element into %T", t))
+				return
+			}
+			if !yield(t, nil) {
+				return
+			}
+		}
+		if err := expectDelim(dec, ']'); err != nil {
+			yield(*new(T), err)
+		}
+	}
+}
+
+// NdJsonUnmarshal is the streaming inverse of NdJsonMarshal.
+// It decodes newline delimited JSON from r one line at a time, blank lines are skipped.
+//
+// Unlike JsonUnmarshalArray a malformed line does not end the sequence, the error, including the line number,
+// is yielded in place of that line and decoding continues with the next line. Stop iterating to
+// fail on the first error.
+func NdJsonUnmarshal[T any](r io.Reader) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		br := bufio.NewReader(r)
+		lineNumber := 0
+		for {
+			line, err := br.ReadBytes('\n')
+			if err != nil && !errors.Is(err, io.EOF) {
+				yield(*new(T), errs.UnMarshalError.Wrap(err, "failed to read line %d", lineNumber+1))
+				return
+			}
+			eof := err != nil
+			lineNumber++
+			if trimmed := bytes.TrimSpace(line); len(trimmed) > 0 {
+				var t T
+				if uerr := json.Unmarshal(trimmed, &t); uerr != nil {
+					if !yield(t, errs.UnMarshalError.Wrap(uerr, "failed to decode line %d into %T", lineNumber, t)) {
+						return
+					}
+				} else if !yield(t, nil) {
+					return
+				}
+			}
+			if eof {
+				return
+			}
+		}
+	}
+}
+
+// JsonUnmarshalObject is the streaming inverse of JsonMarshalObject.
+// It decodes a JSON object from r one property at a time and yields each property as a Pair of the
+// property name and its value decoded into V.
+//
+// Errors end the sequence the same way as JsonUnmarshalArray.
+func JsonUnmarshalObject[V any](r io.Reader) iter.Seq2[Pair[string, V], error] {
+	return func(yield func(Pair[string, V], error) bool) {
+		dec := json.NewDecoder(r)
+		if err := expectDelim(dec, '{'); err != nil {
+			yield(Pair[string, V]{}, err)
+			return
+		}
+		for dec.More() {
+			token, err := dec.Token()
+			if err != nil {
+				yield(Pair[string, V]{}, errs.UnMarshalError.Wrap(err, "failed to read property name"))
+				return
+			}
+			key, ok := token.(string)
+			if !ok {
+				yield(Pair[string, V]{}, errs.UnMarshalError.New("expected property name found %v", token))
+				return
+			}
+			var v V
+			if err = dec.Decode(&v); err != nil {
+				yield(Pair[string, V]{}, errs.UnMarshalError.Wrap(err, "failed to decode property %s into %T", key, v))
+				return
+			}
+			if !yield(Pair[string, V]{Key: key, Value: v}, nil) {
+				return
+			}
+		}
+		if err := expectDelim(dec, '}'); err != nil {
+			yield(Pair[string, V]{}, err)
+		}
+	}
+}
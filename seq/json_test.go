@@ -0,0 +1,85 @@
+package seq
+
+import (
+	"bytes"
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestJsonMarshalCompact(t *testing.T) {
+	tests := []struct {
+		name string
+		seq  []int
+		want string
+	}{
+		{name: "empty", seq: []int{}, want: "[]"},
+		{name: "one", seq: []int{1}, want: "[1]"},
+		{name: "many", seq: []int{1, 2, 3}, want: "[1,2,3]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := JsonMarshalCompact(&buf, slices.Values(tt.seq)); err != nil {
+				t.Fatalf("JsonMarshalCompact() error = %v", err)
+			}
+			if buf.String() != tt.want {
+				t.Errorf("JsonMarshalCompact() = %s, want %s", buf.String(), tt.want)
+			}
+			got, err := CollectErr(t.Context(), JsonUnmarshalArray[int](&buf), FAIL_ON_FIRST_ERROR)
+			if err != nil {
+				t.Fatalf("JsonUnmarshalArray() error = %v", err)
+			}
+			if !slices.Equal(got, tt.seq) {
+				t.Errorf("JsonUnmarshalArray() = %v, want %v", got, tt.seq)
+			}
+		})
+	}
+}
+
+func TestNdJson(t *testing.T) {
+	var buf bytes.Buffer
+	if err := NdJsonMarshal(&buf, ToSeq("a", "b")); err != nil {
+		t.Fatalf("NdJsonMarshal() error = %v", err)
+	}
+	if want := "\"a\"\n\"b\"\n"; buf.String() != want {
+		t.Errorf("NdJsonMarshal() = %q, want %q", buf.String(), want)
+	}
+
+	got := make([]string, 0)
+	errCount := 0
+	for s, err := range NdJsonUnmarshal[string](strings.NewReader("\"a\"\n\nnot json\n\"c\"")) {
+		if err != nil {
+			errCount++
+			continue
+		}
+		got = append(got, s)
+	}
+	if want := []string{"a", "c"}; !slices.Equal(got, want) {
+		t.Errorf("NdJsonUnmarshal() = %v, want %v", got, want)
+	}
+	if errCount != 1 {
+		t.Errorf("NdJsonUnmarshal() errors = %d, want %d", errCount, 1)
+	}
+}
+
+func TestJsonObject(t *testing.T) {
+	var buf bytes.Buffer
+	if err := JsonMarshalObject(&buf, Zip(ToSeq("a", "b"), ToSeq(1, 2))); err != nil {
+		t.Fatalf("JsonMarshalObject() error = %v", err)
+	}
+	if want := `{"a":1,"b":2}`; buf.String() != want {
+		t.Errorf("JsonMarshalObject() = %s, want %s", buf.String(), want)
+	}
+	got := make(map[string]int)
+	for p, err := range JsonUnmarshalObject[int](&buf) {
+		if err != nil {
+			t.Fatalf("JsonUnmarshalObject() error = %v", err)
+		}
+		got[p.Key] = p.Value
+	}
+	if want := map[string]int{"a": 1, "b": 2}; !maps.Equal(got, want) {
+		t.Errorf("JsonUnmarshalObject() = %v, want %v", got, want)
+	}
+}
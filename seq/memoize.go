@@ -0,0 +1,279 @@
+package seq
+
+import (
+	"encoding/json"
+	"io"
+	"iter"
+	"os"
+	"sync"
+
+	errs "github.com/jarrodhroberson/ossgo/errors"
+)
+
+// Codec encodes and decodes the elements a MemoizeSeq spills to disk.
+type Codec[T any] interface {
+	Encode(t T) ([]byte, error)
+	Decode(b []byte) (T, error)
+}
+
+// JsonCodec is a Codec that uses encoding/json.
+type JsonCodec[T any] struct{}
+
+func (c JsonCodec[T]) Encode(t T) ([]byte, error) {
+	return json.Marshal(t)
+}
+
+func (c JsonCodec[T]) Decode(b []byte) (T, error) {
+	var t T
+	err := json.Unmarshal(b, &t)
+	return t, err
+}
+
+type memoizeConfig[T any] struct {
+	threshold int
+	codec     Codec[T]
+	dir       string
+}
+
+// MemoizeOption configures Memoize.
+type MemoizeOption[T any] func(c *memoizeConfig[T])
+
+// WithSpillToDisk keeps the first threshold elements in memory and writes the rest to a temporary
+// file in dir using codec, an empty dir uses os.TempDir. The file is removed by MemoizeSeq.Close.
+func WithSpillToDisk[T any](threshold int, codec Codec[T], dir string) MemoizeOption[T] {
+	return func(c *memoizeConfig[T]) {
+		c.threshold = max(0, threshold)
+		c.codec = codec
+		c.dir = dir
+	}
+}
+
+// MemoizeSeq is a replayable sequence that reads its source at most once.
+// It is filled lazily, elements are only read from the source when the first reader asks for them,
+// and any number of readers can iterate it concurrently while it fills.
+type MemoizeSeq[T any] struct {
+	config memoizeConfig[T]
+	source iter.Seq[T]
+	// fill serializes reading from the source, mu guards everything else
+	fill   sync.Mutex
+	mu     sync.RWMutex
+	next   func() (T, bool)
+	stop   func()
+	done   bool
+	err    error
+	count  int
+	memory []T
+	file   *os.File
+	// offsets[i] and offsets[i+1] are the start and end of spilled element i in file
+	offsets []int64
+}
+
+// Memoize returns a MemoizeSeq that caches the elements of s as they are read, so s is never read more than once.
+// Unlike slices.Collect nothing is read until the MemoizeSeq is iterated, and each reader can use the elements
+// read so far while later ones are still being read.
+//
+// By default all the elements are kept in memory, WithSpillToDisk moves elements past a threshold into a
+// temporary file, which is useful for replaying a large Firestore scan without querying it again.
+//
+// Close must be called to stop the source if it was not read to the end, and to remove the temporary file.
+//
+// Example usage:
+//
+//	users := seq.Memoize(store.All(ctx), seq.WithSpillToDisk(10_000, seq.JsonCodec[*User]{}, ""))
+//	defer users.Close()
+//	for u := range users.Seq() {
+//		// the first pass reads from Firestore
+//	}
+//	for u := range users.Seq() {
+//		// the second pass replays from memory and disk
+//	}
+//	if err := users.Err(); err != nil {
+//		return err
+//	}
+func Memoize[T any](s iter.Seq[T], options ...MemoizeOption[T]) *MemoizeSeq[T] {
+	m := &MemoizeSeq[T]{source: s}
+	m.config.threshold = -1
+	for _, option := range options {
+		option(&m.config)
+	}
+	return m
+}
+
+// spills reports if the next element should be written to disk.
+func (m *MemoizeSeq[T]) spills() bool {
+	return m.config.codec != nil && len(m.memory) >= m.config.threshold
+}
+
+// finish marks the source as exhausted, it must be called with mu locked.
+func (m *MemoizeSeq[T]) finish(err error) {
+	m.done = true
+	if m.err == nil {
+		m.err = err
+	}
+	if m.stop != nil {
+		m.stop()
+	}
+}
+
+// readNext reads the next element from the source, it must be called with fill locked.
+func (m *MemoizeSeq[T]) readNext() {
+	if m.next == nil {
+		m.next, m.stop = iter.Pull(m.source)
+	}
+	t, ok := m.next()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.done {
+		// closed while reading
+		return
+	}
+	if !ok {
+		m.finish(nil)
+		return
+	}
+	if !m.spills() {
+		m.memory = append(m.memory, t)
+		m.count++
+		return
+	}
+	if m.file == nil {
+		f, err := os.CreateTemp(m.config.dir, "memoize-*")
+		if err != nil {
+			m.finish(errs.NotCreatedError.Wrap(err, "could not create spill file"))
+			return
+		}
+		m.file = f
+		m.offsets = []int64{0}
+	}
+	b, err := m.config.codec.Encode(t)
+	if err != nil {
+		m.finish(errs.MarshalError.Wrap(err, "could not encode element %d", m.count))
+		return
+	}
+	if _, err = m.file.Write(b); err != nil {
+		m.finish(errs.NotWrittenError.Wrap(err, "could not write element %d to %s", m.count, m.file.Name()))
+		return
+	}
+	m.offsets = append(m.offsets, m.offsets[len(m.offsets)-1]+int64(len(b)))
+	m.count++
+}
+
+// at returns element i, reading from the source until it is available, false if there is no element i.
+func (m *MemoizeSeq[T]) at(i int) (T, bool) {
+	m.mu.RLock()
+	available := i < m.count || m.done
+	m.mu.RUnlock()
+	if !available {
+		m.fill.Lock()
+		for {
+			m.mu.RLock()
+			available = i < m.count || m.done
+			m.mu.RUnlock()
+			if available {
+				break
+			}
+			m.readNext()
+		}
+		m.fill.Unlock()
+	}
+
+	m.mu.RLock()
+	if i >= m.count {
+		m.mu.RUnlock()
+		return *new(T), false
+	}
+	if i < len(m.memory) {
+		t := m.memory[i]
+		m.mu.RUnlock()
+		return t, true
+	}
+	j := i - len(m.memory)
+	file, start, end := m.file, m.offsets[j], m.offsets[j+1]
+	m.mu.RUnlock()
+
+	// spilled elements are never rewritten, so they can be read without holding the lock
+	b := make([]byte, end-start)
+	if _, err := file.ReadAt(b, start); err != nil && err != io.EOF {
+		m.fail(errs.NotReadError.Wrap(err, "could not read element %d from %s", i, file.Name()))
+		return *new(T), false
+	}
+	t, err := m.config.codec.Decode(b)
+	if err != nil {
+		m.fail(errs.UnMarshalError.Wrap(err, "could not decode element %d", i))
+		return *new(T), false
+	}
+	return t, true
+}
+
+// fail records the first error that occurred while replaying.
+func (m *MemoizeSeq[T]) fail(err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.err == nil {
+		m.err = err
+	}
+}
+
+// Seq returns a sequence of the elements, it can be iterated any number of times and concurrently.
+// The sequence ends early if spilling or replaying an element fails, check Err after iterating.
+func (m *MemoizeSeq[T]) Seq() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for i := 0; ; i++ {
+			t, ok := m.at(i)
+			if !ok || !yield(t) {
+				return
+			}
+		}
+	}
+}
+
+// Seq2 is Seq with the index of each element.
+func (m *MemoizeSeq[T]) Seq2() iter.Seq2[int, T] {
+	return func(yield func(int, T) bool) {
+		for i := 0; ; i++ {
+			t, ok := m.at(i)
+			if !ok || !yield(i, t) {
+				return
+			}
+		}
+	}
+}
+
+// Len returns the number of elements, it reads the rest of the source if it has not been read yet.
+func (m *MemoizeSeq[T]) Len() int {
+	m.at(int(^uint(0) >> 1))
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.count
+}
+
+// Err returns the first error that occurred while spilling or replaying elements.
+func (m *MemoizeSeq[T]) Err() error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.err
+}
+
+// Close stops reading the source and removes the spill file, the MemoizeSeq is empty afterwards.
+// It must not be called while the MemoizeSeq is being iterated.
+func (m *MemoizeSeq[T]) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.done {
+		m.finish(nil)
+	}
+	m.count = 0
+	m.memory = nil
+	m.offsets = nil
+	if m.file == nil {
+		return nil
+	}
+	name := m.file.Name()
+	m.file.Close()
+	m.file = nil
+	if err := os.Remove(name); err != nil {
+		return errs.NotDeletedError.Wrap(err, "could not remove spill file %s", name)
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package seq
+
+import (
+	"os"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// countingSource yields 1 to n and counts how many elements were read.
+func countingSource(n int, reads *atomic.Int64) func(yield func(int) bool) {
+	return func(yield func(int) bool) {
+		for i := 1; i <= n; i++ {
+			reads.Add(1)
+			if !yield(i) {
+				return
+			}
+		}
+	}
+}
+
+func TestMemoize(t *testing.T) {
+	var reads atomic.Int64
+	m := Memoize(countingSource(10, &reads))
+	defer m.Close()
+	if reads.Load() != 0 {
+		t.Errorf("Memoize() read %d elements before iterating, want 0", reads.Load())
+	}
+	for i := range m.Seq() {
+		if i == 3 {
+			break
+		}
+	}
+	if reads.Load() != 3 {
+		t.Errorf("Memoize() read %d elements for the first 3, want 3", reads.Load())
+	}
+	want := slices.Collect(IntRange(1, 10))
+	for range 2 {
+		if got := slices.Collect(m.Seq()); !slices.Equal(got, want) {
+			t.Errorf("Memoize().Seq() = %v, want %v", got, want)
+		}
+	}
+	if reads.Load() != 10 {
+		t.Errorf("Memoize() read %d elements, want 10", reads.Load())
+	}
+	if m.Len() != 10 {
+		t.Errorf("Len() = %d, want 10", m.Len())
+	}
+}
+
+func TestMemoizeConcurrentReaders(t *testing.T) {
+	var reads atomic.Int64
+	m := Memoize(countingSource(1000, &reads), WithSpillToDisk(100, JsonCodec[int]{}, t.TempDir()))
+	defer m.Close()
+	want := slices.Collect(IntRange(1, 1000))
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Go(func() {
+			if got := slices.Collect(m.Seq()); !slices.Equal(got, want) {
+				t.Errorf("Memoize().Seq() got %d elements, want %d in order", len(got), len(want))
+			}
+		})
+	}
+	wg.Wait()
+	if reads.Load() != 1000 {
+		t.Errorf("Memoize() read %d elements, want 1000", reads.Load())
+	}
+	if err := m.Err(); err != nil {
+		t.Errorf("Err() = %v", err)
+	}
+}
+
+func TestMemoizeSpillToDisk(t *testing.T) {
+	dir := t.TempDir()
+	m := Memoize(ToSeq("a", "b", "c", "d"), WithSpillToDisk(2, JsonCodec[string]{}, dir))
+	got := make([]string, 0)
+	for i, s := range m.Seq2() {
+		if len(got) != i {
+			t.Fatalf("Seq2() index = %d, want %d", i, len(got))
+		}
+		got = append(got, s)
+	}
+	if want := []string{"a", "b", "c", "d"}; !slices.Equal(got, want) {
+		t.Errorf("Memoize().Seq2() = %v, want %v", got, want)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("spill files = %d, want 1", len(entries))
+	}
+	if err := m.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("spill files after Close() = %d, want 0", len(entries))
+	}
+}
@@ -0,0 +1,157 @@
+package seq
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"sync"
+
+	errs "github.com/jarrodhroberson/ossgo/errors"
+)
+
+// indexed carries the position of an element of the input sequence through the workers
+// so ORDERED results can be put back in order.
+type indexed[T any] struct {
+	index int
+	value T
+	err   error
+}
+
+// recoverWorkerPanic converts a recovered panic value into a WorkerPanicError.
+func recoverWorkerPanic(r any) error {
+	if err, ok := r.(error); ok {
+		return WorkerPanicError.Wrap(err, "recovered from panic in worker")
+	}
+	return WorkerPanicError.New("recovered from panic in worker: %s", fmt.Sprint(r))
+}
+
+// callSafely calls mapFunc and converts a panic into an error instead of crashing the process.
+func callSafely[T any, R any](ctx context.Context, t T, mapFunc func(ctx context.Context, t T) (R, error)) (r R, err error) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			err = recoverWorkerPanic(rec)
+		}
+	}()
+	return mapFunc(ctx, t)
+}
+
+// ParallelMap is the concurrent version of Map.
+// It applies mapFunc to each element of the input sequence using the given number of worker goroutines
+// and yields each result paired with the error returned by mapFunc.
+//
+// ordering determines the order the results are yielded in:
+//   - ORDERED: results are yielded in the same order as the input sequence.
+//   - UNORDERED: results are yielded as soon as a worker finishes with them.
+//
+// ParallelMap applies back-pressure, it never reads more than 2 * workers elements ahead of what has been
+// yielded, so it does not buffer the whole input sequence in memory.
+// A panic in mapFunc is recovered and yielded as a WorkerPanicError instead of crashing the process.
+// When ctx is done no more elements are read from the input, and the cause is yielded as the final error.
+// If the caller stops iterating early the workers are stopped before ParallelMap returns, the goroutine
+// reading the input sequence stops as soon as the input yields its next element or returns.
+//
+// A workers value less than 1 is reported as a MinSizeExceededError.
+//
+// Example usage, loading documents in batches of 100 on 8 workers:
+//
+//	batches := seq.Chunk(ids, 100)
+//	loaded := seq.ParallelMap(ctx, batches, 8, seq.UNORDERED, func(ctx context.Context, batch iter.Seq[string]) (iter.Seq[*User], error) {
+//		return loadUsers(ctx, batch)
+//	})
+//	users := seq.Flatten(seq.FilterErrors(loaded))
+func ParallelMap[T any, R any](ctx context.Context, it iter.Seq[T], workers int, ordering Ordering, mapFunc func(ctx context.Context, t T) (R, error)) iter.Seq2[R, error] {
+	return func(yield func(R, error) bool) {
+		if workers < 1 {
+			yield(*new(R), errs.MinSizeExceededError.New("workers %d must be >= 1", workers))
+			return
+		}
+
+		workCtx, cancel := context.WithCancel(ctx)
+		window := workers * 2
+		// tokens limits the number of elements that have been read from the input but not yet yielded
+		tokens := make(chan struct{}, window)
+		jobs := make(chan indexed[T])
+		results := make(chan indexed[R], window)
+
+		go func() {
+			defer close(jobs)
+			index := 0
+			for t := range it {
+				select {
+				case tokens <- struct{}{}:
+				case <-workCtx.Done():
+					return
+				}
+				select {
+				case jobs <- indexed[T]{index: index, value: t}:
+					index++
+				case <-workCtx.Done():
+					return
+				}
+			}
+		}()
+
+		var wg sync.WaitGroup
+		for range workers {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for {
+					// workers do not wait for jobs to be closed once workCtx is done, the input
+					// may be blocked and then the goroutine reading it will not close jobs
+					var job indexed[T]
+					var ok bool
+					select {
+					case job, ok = <-jobs:
+						if !ok {
+							return
+						}
+					case <-workCtx.Done():
+						return
+					}
+					r, err := callSafely(workCtx, job.value, mapFunc)
+					results <- indexed[R]{index: job.index, value: r, err: err}
+				}
+			}()
+		}
+		go func() {
+			wg.Wait()
+			close(results)
+		}()
+
+		// make sure every worker has finished before returning, even if the caller stopped early
+		defer func() {
+			cancel()
+			for range results {
+			}
+		}()
+
+		pending := make(map[int]indexed[R])
+		next := 0
+		for res := range results {
+			if ordering == UNORDERED {
+				<-tokens
+				if !yield(res.value, res.err) {
+					return
+				}
+				continue
+			}
+			pending[res.index] = res
+			for {
+				r, ok := pending[next]
+				if !ok {
+					break
+				}
+				delete(pending, next)
+				next++
+				<-tokens
+				if !yield(r.value, r.err) {
+					return
+				}
+			}
+		}
+		if err := contextDone(ctx); err != nil {
+			yield(*new(R), err)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package seq
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestParallelMap(t *testing.T) {
+	square := func(ctx context.Context, i int) (int, error) {
+		return i * i, nil
+	}
+	want := slices.Collect(Map(IntRange(0, 99), func(i int) int { return i * i }))
+
+	t.Run("ordered", func(t *testing.T) {
+		got := slices.Collect(FilterErrors(ParallelMap(context.Background(), IntRange(0, 99), 4, ORDERED, square)))
+		if !slices.Equal(got, want) {
+			t.Errorf("ParallelMap() = %v, want %v", got, want)
+		}
+	})
+	t.Run("unordered", func(t *testing.T) {
+		got := slices.Collect(FilterErrors(ParallelMap(context.Background(), IntRange(0, 99), 4, UNORDERED, square)))
+		slices.Sort(got)
+		if !slices.Equal(got, want) {
+			t.Errorf("ParallelMap() = %v, want %v", got, want)
+		}
+	})
+	t.Run("panic", func(t *testing.T) {
+		panics := func(ctx context.Context, i int) (int, error) {
+			if i == 3 {
+				panic("boom")
+			}
+			return i, nil
+		}
+		errCount := 0
+		for _, err := range ParallelMap(context.Background(), IntRange(0, 9), 2, ORDERED, panics) {
+			if err != nil {
+				errCount++
+			}
+		}
+		if errCount != 1 {
+			t.Errorf("ParallelMap() errors = %d, want %d", errCount, 1)
+		}
+	})
+	t.Run("stop_early", func(t *testing.T) {
+		got := slices.Collect(FirstN(FilterErrors(ParallelMap(context.Background(), IntRange(0, 1_000_000), 4, ORDERED, square)), 5))
+		if !slices.Equal(got, want[:5]) {
+			t.Errorf("ParallelMap() = %v, want %v", got, want[:5])
+		}
+	})
+	t.Run("chunked", func(t *testing.T) {
+		sum := func(ctx context.Context, chunk iter.Seq[int]) ([]int, error) {
+			return slices.Collect(chunk), nil
+		}
+		var got []int
+		for chunk, err := range ParallelMap(context.Background(), Chunk(IntRange(0, 999), 10), 8, ORDERED, sum) {
+			if err != nil {
+				t.Fatalf("ParallelMap() error = %v", err)
+			}
+			if len(chunk) != 10 || chunk[0]%10 != 0 {
+				t.Errorf("ParallelMap() chunk = %v, want 10 consecutive elements", chunk)
+			}
+			got = append(got, chunk...)
+		}
+		if want := slices.Collect(IntRange(0, 999)); !slices.Equal(got, want) {
+			t.Errorf("ParallelMap() = %v, want %v", got, want)
+		}
+	})
+	t.Run("stop_early_blocked_input", func(t *testing.T) {
+		block := make(chan struct{})
+		defer close(block)
+		blocking := func(yield func(int) bool) {
+			for i := range 3 {
+				if !yield(i) {
+					return
+				}
+			}
+			<-block
+		}
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for range ParallelMap(context.Background(), blocking, 2, UNORDERED, square) {
+				break
+			}
+		}()
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("ParallelMap() did not return after stopping early while the input was blocked")
+		}
+	})
+	t.Run("canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		var last error
+		for _, err := range ParallelMap(ctx, IntRange(0, 99), 4, ORDERED, square) {
+			last = err
+		}
+		if !errors.Is(last, context.Canceled) {
+			t.Errorf("ParallelMap() error = %v, want %v", last, context.Canceled)
+		}
+	})
+}
@@ -0,0 +1,190 @@
+package seq
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"slices"
+
+	errs "github.com/jarrodhroberson/ossgo/errors"
+)
+
+// contextDone returns the cause of the context being done or nil if it is still active.
+func contextDone(ctx context.Context) error {
+	if ctx.Err() != nil {
+		return context.Cause(ctx)
+	}
+	return nil
+}
+
+// ToSeqErr lifts an infallible iter.Seq[T] into an iter.Seq2[T, error] so it can be used as the
+// source of the error propagating pipeline stages (MapErr, FilterErr, ChunkErr, ReduceErr).
+// Every element is yielded with a nil error.
+//
+// Example usage:
+//
+//	doubled := seq.MapErr(ctx, seq.ToSeqErr(seq.IntRange(1, 3)), func(ctx context.Context, i int) (int, error) {
+//		return i * 2, nil
+//	}, seq.FAIL_ON_FIRST_ERROR)
+func ToSeqErr[T any](it iter.Seq[T]) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		for t := range it {
+			if !yield(t, nil) {
+				return
+			}
+		}
+	}
+}
+
+// WithContext stops an iter.Seq2[T, error] as soon as ctx is done.
+// When the context is canceled or its deadline is exceeded the cause is yielded as the final error.
+// This is useful at the head of a pipeline whose source does not know about context.Context itself.
+func WithContext[T any](ctx context.Context, it iter.Seq2[T, error]) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		if err := contextDone(ctx); err != nil {
+			yield(*new(T), err)
+			return
+		}
+		for t, err := range it {
+			if cerr := contextDone(ctx); cerr != nil {
+				yield(*new(T), cerr)
+				return
+			}
+			if !yield(t, err) {
+				return
+			}
+		}
+	}
+}
+
+// MapErr is the error propagating version of Map.
+// It applies mapFunc to every element of the sequence that does not already carry an error.
+//
+// Errors from upstream stages are passed through unchanged, errors returned by mapFunc are
+// yielded in place of the element that failed. What happens next is determined by errorHandling:
+//   - FAIL_ON_FIRST_ERROR: the error is yielded and the sequence ends.
+//   - COLLECT_ERRORS: the error is yielded and the remaining elements are processed.
+//
+// The sequence always ends when ctx is done, the cause is yielded as the final error.
+//
+// Example usage:
+//
+//	ids := seq.ToSeqErr(seq.ToSeq("a", "b", "c"))
+//	users := seq.MapErr(ctx, ids, func(ctx context.Context, id string) (*User, error) {
+//		return store.Load(id)
+//	}, seq.COLLECT_ERRORS)
+//	for u, err := range users {
+//		// handle each user or error
+//	}
+func MapErr[T any, R any](ctx context.Context, it iter.Seq2[T, error], mapFunc func(ctx context.Context, t T) (R, error), errorHandling ErrorHandling) iter.Seq2[R, error] {
+	return func(yield func(R, error) bool) {
+		for t, err := range WithContext(ctx, it) {
+			var r R
+			if err == nil {
+				r, err = mapFunc(ctx, t)
+			}
+			if !yield(r, err) || errorHandling.stop(err) {
+				return
+			}
+		}
+	}
+}
+
+// FilterErr is the error propagating version of Filter.
+// Elements for which predicate returns true are yielded, errors from upstream stages and errors
+// returned by predicate are yielded according to errorHandling the same way as MapErr.
+func FilterErr[T any](ctx context.Context, it iter.Seq2[T, error], predicate func(ctx context.Context, t T) (bool, error), errorHandling ErrorHandling) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		for t, err := range WithContext(ctx, it) {
+			if err == nil {
+				var keep bool
+				keep, err = predicate(ctx, t)
+				if err == nil && !keep {
+					continue
+				}
+			}
+			if err != nil {
+				t = *new(T)
+			}
+			if !yield(t, err) || errorHandling.stop(err) {
+				return
+			}
+		}
+	}
+}
+
+// ChunkErr is the error propagating version of Chunk.
+// It batches successful elements into chunks of up to size elements, errors are not part of any chunk
+// and are yielded on their own as soon as they are encountered according to errorHandling.
+// With FAIL_ON_FIRST_ERROR the partially filled chunk is discarded when an error occurs.
+// With COLLECT_ERRORS the partially filled chunk keeps filling up after the error is yielded.
+//
+// Unlike Chunk, each chunk is buffered so it can be iterated independently of the source.
+// A size less than 1 is reported as a MinSizeExceededError instead of a panic.
+func ChunkErr[T any](ctx context.Context, it iter.Seq2[T, error], size int, errorHandling ErrorHandling) iter.Seq2[iter.Seq[T], error] {
+	return func(yield func(iter.Seq[T], error) bool) {
+		if size < 1 {
+			yield(nil, errs.MinSizeExceededError.New("size %d must be >= 1", size))
+			return
+		}
+		chunk := make([]T, 0, size)
+		for t, err := range WithContext(ctx, it) {
+			if err != nil {
+				if !yield(nil, err) || errorHandling.stop(err) {
+					return
+				}
+				continue
+			}
+			chunk = append(chunk, t)
+			if len(chunk) == size {
+				if !yield(slices.Values(chunk), nil) {
+					return
+				}
+				chunk = make([]T, 0, size)
+			}
+		}
+		if len(chunk) > 0 {
+			yield(slices.Values(chunk), nil)
+		}
+	}
+}
+
+// ReduceErr is the error propagating version of Reduce.
+// It consumes the entire sequence and returns the accumulated value along with any errors.
+//
+// With FAIL_ON_FIRST_ERROR the reduction stops at the first error, the value accumulated so far and the
+// error are returned. With COLLECT_ERRORS elements that failed are skipped and all the errors are
+// returned as a single error using errors.Join.
+//
+// Example usage:
+//
+//	total, err := seq.ReduceErr(ctx, amounts, 0, func(ctx context.Context, acc int, i int) (int, error) {
+//		return acc + i, nil
+//	}, seq.FAIL_ON_FIRST_ERROR)
+func ReduceErr[T any, A any](ctx context.Context, it iter.Seq2[T, error], initialValue A, f func(ctx context.Context, acc A, t T) (A, error), errorHandling ErrorHandling) (A, error) {
+	acc := initialValue
+	var collected []error
+	for t, err := range WithContext(ctx, it) {
+		if err == nil {
+			var next A
+			next, err = f(ctx, acc, t)
+			if err == nil {
+				acc = next
+				continue
+			}
+		}
+		if errorHandling.stop(err) {
+			return acc, err
+		}
+		collected = append(collected, err)
+	}
+	return acc, errors.Join(collected...)
+}
+
+// CollectErr collects the successful elements of the sequence into a slice.
+// Errors are handled the same way as ReduceErr.
+func CollectErr[T any](ctx context.Context, it iter.Seq2[T, error], errorHandling ErrorHandling) ([]T, error) {
+	return ReduceErr(ctx, it, make([]T, 0), func(ctx context.Context, acc []T, t T) ([]T, error) {
+		return append(acc, t), nil
+	}, errorHandling)
+}
@@ -0,0 +1,96 @@
+package seq
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+var errOdd = errors.New("odd")
+
+func failOnOdd(ctx context.Context, i int) (int, error) {
+	if i%2 != 0 {
+		return 0, errOdd
+	}
+	return i * 10, nil
+}
+
+func TestMapErr(t *testing.T) {
+	type testCase struct {
+		name          string
+		errorHandling ErrorHandling
+		want          []int
+		wantErrs      int
+	}
+	tests := []testCase{
+		{
+			name:          "fail_on_first_error",
+			errorHandling: FAIL_ON_FIRST_ERROR,
+			want:          []int{0},
+			wantErrs:      1,
+		},
+		{
+			name:          "collect_errors",
+			errorHandling: COLLECT_ERRORS,
+			want:          []int{0, 20, 40},
+			wantErrs:      2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := make([]int, 0)
+			errCount := 0
+			for v, err := range MapErr(context.Background(), ToSeqErr(IntRange(0, 4)), failOnOdd, tt.errorHandling) {
+				if err != nil {
+					if !errors.Is(err, errOdd) {
+						t.Errorf("MapErr() error = %v, want %v", err, errOdd)
+					}
+					errCount++
+					continue
+				}
+				got = append(got, v)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("MapErr() = %v, want %v", got, tt.want)
+			}
+			if errCount != tt.wantErrs {
+				t.Errorf("MapErr() errors = %d, want %d", errCount, tt.wantErrs)
+			}
+		})
+	}
+}
+
+func TestReduceErr(t *testing.T) {
+	ctx := context.Background()
+	sum := func(ctx context.Context, acc int, i int) (int, error) {
+		return acc + i, nil
+	}
+	got, err := ReduceErr(ctx, MapErr(ctx, ToSeqErr(IntRange(0, 4)), failOnOdd, COLLECT_ERRORS), 0, sum, COLLECT_ERRORS)
+	if got != 60 {
+		t.Errorf("ReduceErr() = %d, want %d", got, 60)
+	}
+	if !errors.Is(err, errOdd) {
+		t.Errorf("ReduceErr() error = %v, want %v", err, errOdd)
+	}
+}
+
+func TestWithContextCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	count := 0
+	var last error
+	for _, err := range WithContext(ctx, ToSeqErr(IntRange(1, 100))) {
+		count++
+		last = err
+		if count == 3 {
+			cancel()
+		}
+	}
+	if count != 4 {
+		t.Errorf("WithContext() yielded %d, want %d", count, 4)
+	}
+	if !errors.Is(last, context.Canceled) {
+		t.Errorf("WithContext() error = %v, want %v", last, context.Canceled)
+	}
+}
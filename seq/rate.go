@@ -0,0 +1,201 @@
+package seq
+
+import (
+	"context"
+	"iter"
+	"time"
+
+	"github.com/joomcode/errorx"
+)
+
+// sleep waits for d or until ctx is done, it returns the cause if ctx is done first.
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return contextDone(ctx)
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return context.Cause(ctx)
+	}
+}
+
+// tokenBucket is a token bucket that refills at rate tokens per second up to burst tokens.
+type tokenBucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// reserve takes a token from the bucket and returns how long to wait before it can be used.
+func (b *tokenBucket) reserve(now time.Time) time.Duration {
+	b.tokens = min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// RateLimit yields the elements of the sequence no faster than eventsPerSecond on average,
+// allowing bursts of up to burst elements to be yielded without waiting.
+// It is a token bucket, the bucket starts full and refills continuously at eventsPerSecond.
+//
+// The source sequence is read one element at a time before waiting for its token, so a slow
+// consumer like a rate limited API applies back-pressure all the way to the source and an
+// exhausted source ends the sequence without waiting.
+// When ctx is done while waiting the cause is yielded as the final error.
+// An eventsPerSecond that is not positive or a burst less than 1 is reported as an IllegalArgument error.
+//
+// Example usage, sending at most 10 emails per second:
+//
+//	for u, err := range seq.RateLimit(ctx, users, 10, 1) {
+//		if err != nil {
+//			return err
+//		}
+//		send(u)
+//	}
+func RateLimit[T any](ctx context.Context, it iter.Seq[T], eventsPerSecond float64, burst int) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		if eventsPerSecond <= 0 || burst < 1 {
+			yield(*new(T), errorx.IllegalArgument.New("eventsPerSecond %v must be > 0 and burst %d must be >= 1", eventsPerSecond, burst))
+			return
+		}
+		bucket := &tokenBucket{rate: eventsPerSecond, burst: float64(burst), tokens: float64(burst), last: time.Now()}
+		next, stop := iter.Pull(it)
+		defer stop()
+		for {
+			t, ok := next()
+			if !ok {
+				return
+			}
+			if err := sleep(ctx, bucket.reserve(time.Now())); err != nil {
+				yield(*new(T), err)
+				return
+			}
+			if !yield(t, nil) {
+				return
+			}
+		}
+	}
+}
+
+// Throttle yields the elements of the sequence at least interval apart, the first element is yielded immediately.
+// Like RateLimit the source is read one element at a time before waiting and the cause is
+// yielded as the final error when ctx is done while waiting.
+// Unlike RateLimit it never allows bursts, time spent by the consumer between elements counts towards the interval.
+func Throttle[T any](ctx context.Context, it iter.Seq[T], interval time.Duration) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var earliest time.Time
+		for t := range it {
+			if err := sleep(ctx, time.Until(earliest)); err != nil {
+				yield(*new(T), err)
+				return
+			}
+			earliest = time.Now().Add(interval)
+			if !yield(t, nil) {
+				return
+			}
+		}
+	}
+}
+
+// Debounce only yields an element once the source has not produced a newer one for quiet.
+// Elements that are followed by another element within quiet are dropped, the last element of the
+// source is always yielded. This is the sequence version of functions.Debounce, it is useful for
+// collapsing bursts of change notifications into a single update.
+//
+// The source is read in a separate goroutine with ToChan so the quiet period can be measured while it blocks.
+// That goroutine is stopped when ctx is done or the caller stops iterating.
+// When ctx is done the cause is yielded as the final error.
+// A quiet that is not positive is reported as an IllegalArgument error.
+func Debounce[T any](ctx context.Context, it iter.Seq[T], quiet time.Duration) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		if quiet <= 0 {
+			yield(*new(T), errorx.IllegalArgument.New("quiet %s must be > 0", quiet))
+			return
+		}
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		values := ToChan(ctx, it, 0)
+		timer := time.NewTimer(quiet)
+		timer.Stop()
+		defer timer.Stop()
+
+		var pending T
+		hasPending := false
+		for {
+			select {
+			case <-ctx.Done():
+				yield(*new(T), context.Cause(ctx))
+				return
+			case t, ok := <-values:
+				if !ok {
+					if hasPending {
+						yield(pending, nil)
+					}
+					return
+				}
+				pending, hasPending = t, true
+				timer.Reset(quiet)
+			case <-timer.C:
+				if hasPending {
+					hasPending = false
+					if !yield(pending, nil) {
+						return
+					}
+				}
+			}
+		}
+	}
+}
+
+// Sample yields the most recent element produced by the source once every interval.
+// Nothing is yielded for an interval in which the source did not produce an element, and the last
+// element is yielded when the source ends if it has not been yielded yet.
+//
+// Like Debounce the source is read in a separate goroutine that is stopped when ctx is done or
+// the caller stops iterating, and the cause is yielded as the final error when ctx is done.
+func Sample[T any](ctx context.Context, it iter.Seq[T], interval time.Duration) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		if interval <= 0 {
+			yield(*new(T), errorx.IllegalArgument.New("interval %s must be > 0", interval))
+			return
+		}
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		values := ToChan(ctx, it, 0)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		var latest T
+		hasLatest := false
+		for {
+			select {
+			case <-ctx.Done():
+				yield(*new(T), context.Cause(ctx))
+				return
+			case t, ok := <-values:
+				if !ok {
+					if hasLatest {
+						yield(latest, nil)
+					}
+					return
+				}
+				latest, hasLatest = t, true
+			case <-ticker.C:
+				if hasLatest {
+					hasLatest = false
+					if !yield(latest, nil) {
+						return
+					}
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package seq
+
+import (
+	"context"
+	"iter"
+	"slices"
+	"testing"
+	"time"
+)
+
+// spaced yields the values with a pause before each one.
+func spaced[T any](pause time.Duration, values ...T) iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for _, v := range values {
+			time.Sleep(pause)
+			if !yield(v) {
+				return
+			}
+		}
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	start := time.Now()
+	got, err := CollectErr(t.Context(), RateLimit(t.Context(), IntRange(1, 5), 100, 2), FAIL_ON_FIRST_ERROR)
+	if err != nil {
+		t.Fatalf("RateLimit() error = %v", err)
+	}
+	if len(got) != 5 {
+		t.Errorf("RateLimit() = %v, want 5 elements", got)
+	}
+	// 2 elements are allowed by the burst, the other 3 wait 10ms each
+	if elapsed := time.Since(start); elapsed < 25*time.Millisecond {
+		t.Errorf("RateLimit() took %s, want at least 25ms", elapsed)
+	}
+
+	// the source is read before waiting, so running out costs no extra token
+	start = time.Now()
+	if got, err = CollectErr(t.Context(), RateLimit(t.Context(), ToSeq(1), 0.1, 1), FAIL_ON_FIRST_ERROR); err != nil || len(got) != 1 {
+		t.Errorf("RateLimit() = %v, %v, want [1]", got, err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("RateLimit() took %s after the source ended, want no wait", elapsed)
+	}
+
+	if _, err = CollectErr(t.Context(), RateLimit(t.Context(), IntRange(0, 1), 0, 1), FAIL_ON_FIRST_ERROR); err == nil {
+		t.Error("RateLimit() with no rate error = nil")
+	}
+
+	ctx, cancel := context.WithTimeout(t.Context(), 20*time.Millisecond)
+	defer cancel()
+	if _, err = CollectErr(ctx, RateLimit(ctx, IntRange(0, 100), 1, 1), FAIL_ON_FIRST_ERROR); err == nil {
+		t.Error("RateLimit() with canceled context error = nil")
+	}
+}
+
+func TestThrottle(t *testing.T) {
+	start := time.Now()
+	got, err := CollectErr(t.Context(), Throttle(t.Context(), IntRange(0, 2), 10*time.Millisecond), FAIL_ON_FIRST_ERROR)
+	if err != nil {
+		t.Fatalf("Throttle() error = %v", err)
+	}
+	if !slices.Equal(got, []int{0, 1, 2}) {
+		t.Errorf("Throttle() = %v, want [0 1 2]", got)
+	}
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Errorf("Throttle() took %s, want at least 20ms", elapsed)
+	}
+}
+
+func TestDebounce(t *testing.T) {
+	const quiet = 50 * time.Millisecond
+	got, err := CollectErr(t.Context(), Debounce(t.Context(), ToSeq(1, 2, 3), quiet), FAIL_ON_FIRST_ERROR)
+	if err != nil {
+		t.Fatalf("Debounce() error = %v", err)
+	}
+	if !slices.Equal(got, []int{3}) {
+		t.Errorf("Debounce() = %v, want [3]", got)
+	}
+
+	// the pause is many quiet periods long so a slow scheduler cannot merge the two bursts
+	burst := func(yield func(int) bool) {
+		for _, v := range []int{1, 2, 3} {
+			if !yield(v) {
+				return
+			}
+		}
+		time.Sleep(20 * quiet)
+		yield(4)
+	}
+	got, err = CollectErr(t.Context(), Debounce(t.Context(), burst, quiet), FAIL_ON_FIRST_ERROR)
+	if err != nil {
+		t.Fatalf("Debounce() error = %v", err)
+	}
+	if !slices.Equal(got, []int{3, 4}) {
+		t.Errorf("Debounce() = %v, want [3 4]", got)
+	}
+
+	if _, err = CollectErr(t.Context(), Debounce(t.Context(), ToSeq(1), 0), FAIL_ON_FIRST_ERROR); err == nil {
+		t.Error("Debounce() with no quiet period error = nil")
+	}
+}
+
+func TestSample(t *testing.T) {
+	const interval = 20 * time.Millisecond
+	start := time.Now()
+	got, err := CollectErr(t.Context(), Sample(t.Context(), spaced(4*time.Millisecond, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10), interval), FAIL_ON_FIRST_ERROR)
+	if err != nil {
+		t.Fatalf("Sample() error = %v", err)
+	}
+	// at most one sample per elapsed interval plus the final element, however long the run took
+	limit := int(time.Since(start)/interval) + 1
+	if len(got) < 1 || len(got) > limit || got[len(got)-1] != 10 {
+		t.Errorf("Sample() = %v, want at most %d samples ending with 10", got, limit)
+	}
+	if !slices.IsSorted(got) {
+		t.Errorf("Sample() = %v, want samples in order", got)
+	}
+
+	if _, err = CollectErr(t.Context(), Sample(t.Context(), ToSeq(1), 0), FAIL_ON_FIRST_ERROR); err == nil {
+		t.Error("Sample() with no interval error = nil")
+	}
+}
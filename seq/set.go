@@ -0,0 +1,272 @@
+package seq
+
+import (
+	"bufio"
+	"cmp"
+	"encoding/binary"
+	"errors"
+	"io"
+	"iter"
+	"os"
+	"slices"
+
+	errs "github.com/jarrodhroberson/ossgo/errors"
+)
+
+// UniqueBy yields the first element for each distinct key returned by keyFunc, in the order they are first seen.
+// Only the keys are kept in memory, not the elements.
+//
+// Unlike Unique the elements do not have to be hashable, so it can not panic, and computing a key like
+// a document ID is much faster than hashing the whole element.
+//
+// Example usage:
+//
+//	users := seq.UniqueBy(users, func(u *User) string { return u.ID })
+func UniqueBy[T any, K comparable](s iter.Seq[T], keyFunc func(t T) K) iter.Seq[T] {
+	return func(yield func(T) bool) {
+		seen := make(map[K]struct{})
+		for t := range s {
+			key := keyFunc(t)
+			if _, ok := seen[key]; ok {
+				continue
+			}
+			seen[key] = struct{}{}
+			if !yield(t) {
+				return
+			}
+		}
+	}
+}
+
+// Distinct is UniqueBy for comparable types, the elements are their own keys.
+func Distinct[T comparable](s iter.Seq[T]) iter.Seq[T] {
+	return UniqueBy(s, PassThruFunc[T])
+}
+
+// Union yields the distinct elements of all the sequences, in the order they are first seen.
+func Union[T comparable](iterSeqs ...iter.Seq[T]) iter.Seq[T] {
+	return Distinct(FlattenSeq(iterSeqs...))
+}
+
+// UnionBy is Union using keyFunc to determine if elements are the same.
+func UnionBy[T any, K comparable](keyFunc func(t T) K, iterSeqs ...iter.Seq[T]) iter.Seq[T] {
+	return UniqueBy(FlattenSeq(iterSeqs...), keyFunc)
+}
+
+// keySet collects the keys of every element of s.
+func keySet[T any, K comparable](s iter.Seq[T], keyFunc func(t T) K) map[K]struct{} {
+	keys := make(map[K]struct{})
+	for t := range s {
+		keys[keyFunc(t)] = struct{}{}
+	}
+	return keys
+}
+
+// Intersect yields the distinct elements of a that are also in b.
+// b is read completely, and its elements are kept in memory, before the first element of a is read.
+func Intersect[T comparable](a iter.Seq[T], b iter.Seq[T]) iter.Seq[T] {
+	return IntersectBy(a, PassThruFunc[T], b, PassThruFunc[T])
+}
+
+// IntersectBy yields the elements of a whose key is also the key of an element of b, once per key.
+// The sequences can be of different types as long as their keys are comparable, only the keys of b are kept in memory.
+//
+// Example usage, finding the documents that have an object in Cloud Storage:
+//
+//	stored := seq.IntersectBy(docs, func(d *Doc) string { return d.Path },
+//		objects, func(o *storage.ObjectAttrs) string { return o.Name })
+func IntersectBy[A any, B any, K comparable](a iter.Seq[A], aKeyFunc func(a A) K, b iter.Seq[B], bKeyFunc func(b B) K) iter.Seq[A] {
+	return func(yield func(A) bool) {
+		keys := keySet(b, bKeyFunc)
+		for t := range a {
+			k := aKeyFunc(t)
+			if _, ok := keys[k]; !ok {
+				continue
+			}
+			// a key is yielded once, forgetting it also frees its memory
+			delete(keys, k)
+			if !yield(t) {
+				return
+			}
+		}
+	}
+}
+
+// Except yields the distinct elements of a that are not in b.
+// b is read completely, and its elements are kept in memory, before the first element of a is read.
+func Except[T comparable](a iter.Seq[T], b iter.Seq[T]) iter.Seq[T] {
+	return ExceptBy(a, PassThruFunc[T], b, PassThruFunc[T])
+}
+
+// ExceptBy yields the elements of a whose key is not the key of any element of b, once per key.
+// Like IntersectBy the sequences can be of different types, the keys of b and the keys of the elements
+// that were yielded are kept in memory.
+//
+// Example usage, finding the documents that are missing their object in Cloud Storage:
+//
+//	missing := seq.ExceptBy(docs, func(d *Doc) string { return d.Path },
+//		objects, func(o *storage.ObjectAttrs) string { return o.Name })
+func ExceptBy[A any, B any, K comparable](a iter.Seq[A], aKeyFunc func(a A) K, b iter.Seq[B], bKeyFunc func(b B) K) iter.Seq[A] {
+	return func(yield func(A) bool) {
+		keys := keySet(b, bKeyFunc)
+		for t := range a {
+			k := aKeyFunc(t)
+			if _, ok := keys[k]; ok {
+				continue
+			}
+			// a yielded key is excluded from then on, so every key is yielded once
+			keys[k] = struct{}{}
+			if !yield(t) {
+				return
+			}
+		}
+	}
+}
+
+// sortRun is a sorted run of elements spilled to a temporary file by SortBy.
+// Each element is written as its length as a uvarint followed by the bytes from the codec.
+type sortRun struct {
+	file *os.File
+}
+
+// writeSortRun sorts the elements and writes them to a new temporary file.
+func writeSortRun[T any](run []T, compare func(a T, b T) int, codec Codec[T]) (*sortRun, error) {
+	slices.SortStableFunc(run, compare)
+	f, err := os.CreateTemp("", "sort-run-*")
+	if err != nil {
+		return nil, errs.NotCreatedError.Wrap(err, "could not create sort run file")
+	}
+	sr := &sortRun{file: f}
+	w := bufio.NewWriter(f)
+	length := make([]byte, binary.MaxVarintLen64)
+	for i, t := range run {
+		b, err := codec.Encode(t)
+		if err != nil {
+			sr.remove()
+			return nil, errs.MarshalError.Wrap(err, "could not encode element %d of sort run", i)
+		}
+		n := binary.PutUvarint(length, uint64(len(b)))
+		if _, err = w.Write(length[:n]); err == nil {
+			_, err = w.Write(b)
+		}
+		if err != nil {
+			sr.remove()
+			return nil, errs.NotWrittenError.Wrap(err, "could not write sort run %s", f.Name())
+		}
+	}
+	if err = w.Flush(); err != nil {
+		sr.remove()
+		return nil, errs.NotWrittenError.Wrap(err, "could not write sort run %s", f.Name())
+	}
+	return sr, nil
+}
+
+// readSortRun reads the run back, the first error is stored in errp and ends the sequence.
+func readSortRun[T any](sr *sortRun, codec Codec[T], errp *error) iter.Seq[T] {
+	return func(yield func(T) bool) {
+		if _, err := sr.file.Seek(0, io.SeekStart); err != nil {
+			*errp = errs.NotReadError.Wrap(err, "could not read sort run %s", sr.file.Name())
+			return
+		}
+		r := bufio.NewReader(sr.file)
+		for {
+			length, err := binary.ReadUvarint(r)
+			if errors.Is(err, io.EOF) {
+				return
+			}
+			b := make([]byte, length)
+			if err == nil {
+				_, err = io.ReadFull(r, b)
+			}
+			if err != nil {
+				*errp = errs.NotReadError.Wrap(err, "could not read sort run %s", sr.file.Name())
+				return
+			}
+			t, err := codec.Decode(b)
+			if err != nil {
+				*errp = errs.UnMarshalError.Wrap(err, "could not decode element of sort run %s", sr.file.Name())
+				return
+			}
+			if !yield(t) {
+				return
+			}
+		}
+	}
+}
+
+func (sr *sortRun) remove() {
+	sr.file.Close()
+	os.Remove(sr.file.Name())
+}
+
+// SortBy yields the elements of the sequence sorted by the key returned by keyFunc, elements with equal keys
+// keep their relative order. It is an external merge sort for sequences larger than memory: the elements are
+// read in runs of runSize, each run is sorted and written to a temporary file with codec, and the runs are
+// merged with MergeSorted. At most runSize elements plus one element per run are held in memory at a time.
+//
+// If the whole sequence fits in a single run nothing is written to disk, a nil codec sorts entirely in memory.
+// The temporary files are removed when the iteration ends or is stopped early.
+// Failing to write or read a run is yielded as the final error, a runSize less than 1 is a MinSizeExceededError.
+//
+// Example usage:
+//
+//	sorted := seq.SortBy(objects, func(o *storage.ObjectAttrs) string { return o.Name }, 100_000, seq.JsonCodec[*storage.ObjectAttrs]{})
+func SortBy[T any, K cmp.Ordered](s iter.Seq[T], keyFunc func(t T) K, runSize int, codec Codec[T]) iter.Seq2[T, error] {
+	compare := func(a T, b T) int {
+		return cmp.Compare(keyFunc(a), keyFunc(b))
+	}
+	return func(yield func(T, error) bool) {
+		if runSize < 1 {
+			yield(*new(T), errs.MinSizeExceededError.New("runSize %d must be >= 1", runSize))
+			return
+		}
+		runs := make([]*sortRun, 0)
+		defer func() {
+			for _, sr := range runs {
+				sr.remove()
+			}
+		}()
+
+		run := make([]T, 0, runSize)
+		for t := range s {
+			if len(run) == runSize && codec != nil {
+				sr, err := writeSortRun(run, compare, codec)
+				if err != nil {
+					yield(*new(T), err)
+					return
+				}
+				runs = append(runs, sr)
+				run = run[:0]
+			}
+			run = append(run, t)
+		}
+		slices.SortStableFunc(run, compare)
+		if len(runs) == 0 {
+			for _, t := range run {
+				if !yield(t, nil) {
+					return
+				}
+			}
+			return
+		}
+
+		// the last run stays in memory, it comes after the spilled runs so the merge stays stable
+		var err error
+		sources := make([]iter.Seq[T], 0, len(runs)+1)
+		for _, sr := range runs {
+			sources = append(sources, readSortRun(sr, codec, &err))
+		}
+		sources = append(sources, slices.Values(run))
+		for t := range MergeSorted(compare, sources...) {
+			if err != nil {
+				break
+			}
+			if !yield(t, nil) {
+				return
+			}
+		}
+		if err != nil {
+			yield(*new(T), err)
+		}
+	}
+}
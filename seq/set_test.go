@@ -0,0 +1,73 @@
+package seq
+
+import (
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestUniqueBy(t *testing.T) {
+	got := slices.Collect(UniqueBy(ToSeq("apple", "avocado", "banana", "blueberry", "cherry"), func(s string) byte { return s[0] }))
+	if want := []string{"apple", "banana", "cherry"}; !slices.Equal(got, want) {
+		t.Errorf("UniqueBy() = %v, want %v", got, want)
+	}
+	if got := slices.Collect(Distinct(ToSeq(3, 1, 3, 2, 1))); !slices.Equal(got, []int{3, 1, 2}) {
+		t.Errorf("Distinct() = %v, want [3 1 2]", got)
+	}
+}
+
+func TestSetOperations(t *testing.T) {
+	a := ToSeq(1, 2, 2, 3, 4, 4, 3)
+	b := ToSeq(3, 4, 5)
+	tests := []struct {
+		name string
+		seq  []int
+		want []int
+	}{
+		{name: "union", seq: slices.Collect(Union(a, b)), want: []int{1, 2, 3, 4, 5}},
+		{name: "intersect", seq: slices.Collect(Intersect(a, b)), want: []int{3, 4}},
+		{name: "except", seq: slices.Collect(Except(a, b)), want: []int{1, 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !slices.Equal(tt.seq, tt.want) {
+				t.Errorf("%s = %v, want %v", tt.name, tt.seq, tt.want)
+			}
+		})
+	}
+
+	docs := ToSeq("users/a", "users/b", "users/c")
+	objects := ToSeq("USERS/B", "USERS/C", "USERS/D")
+	missing := slices.Collect(ExceptBy(docs, PassThruFunc[string], objects, strings.ToLower))
+	if want := []string{"users/a"}; !slices.Equal(missing, want) {
+		t.Errorf("ExceptBy() = %v, want %v", missing, want)
+	}
+}
+
+func TestSortBy(t *testing.T) {
+	type record struct {
+		Key   int
+		Order int
+	}
+	records := make([]record, 1000)
+	for i := range records {
+		records[i] = record{Key: rand.IntN(50), Order: i}
+	}
+
+	for _, codec := range []Codec[record]{JsonCodec[record]{}, nil} {
+		got, err := CollectErr(t.Context(), SortBy(slices.Values(records), func(r record) int { return r.Key }, 64, codec), FAIL_ON_FIRST_ERROR)
+		if err != nil {
+			t.Fatalf("SortBy() error = %v", err)
+		}
+		want := slices.Clone(records)
+		slices.SortStableFunc(want, func(a, b record) int { return a.Key - b.Key })
+		if !slices.Equal(got, want) {
+			t.Errorf("SortBy() with codec %v is not a stable sort", codec)
+		}
+	}
+
+	if _, err := CollectErr(t.Context(), SortBy(ToSeq(1), PassThruFunc[int], 0, nil), FAIL_ON_FIRST_ERROR); err == nil {
+		t.Error("SortBy() with runSize 0 error = nil")
+	}
+}
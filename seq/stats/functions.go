@@ -0,0 +1,211 @@
+package stats
+
+import (
+	"cmp"
+	"container/heap"
+	"iter"
+	"math"
+	"slices"
+
+	"github.com/joomcode/errorx"
+
+	errs "github.com/jarrodhroberson/ossgo/errors"
+	"github.com/jarrodhroberson/ossgo/seq"
+)
+
+// Summarize calculates the Summary of the sequence in a single pass, false if the sequence is empty.
+func Summarize[T seq.Number](it iter.Seq[T]) (Summary[T], bool) {
+	s := Summary[T]{}
+	for v := range it {
+		s.Add(v)
+	}
+	return s, s.Count > 0
+}
+
+// Min returns the smallest value of the sequence, false if the sequence is empty.
+// Unlike seq.Min it does not panic on an empty sequence.
+func Min[T seq.Number](it iter.Seq[T]) (T, bool) {
+	lo, _, ok := MinMax(it)
+	return lo, ok
+}
+
+// Max returns the largest value of the sequence, false if the sequence is empty.
+func Max[T seq.Number](it iter.Seq[T]) (T, bool) {
+	_, hi, ok := MinMax(it)
+	return hi, ok
+}
+
+// MinMax returns the smallest and largest values of the sequence in a single pass, false if the sequence is empty.
+func MinMax[T seq.Number](it iter.Seq[T]) (T, T, bool) {
+	var lo, hi T
+	found := false
+	for v := range it {
+		if !found {
+			lo, hi, found = v, v, true
+			continue
+		}
+		lo = min(lo, v)
+		hi = max(hi, v)
+	}
+	return lo, hi, found
+}
+
+// Mean returns the arithmetic mean of the sequence, false if the sequence is empty.
+func Mean[T seq.Number](it iter.Seq[T]) (float64, bool) {
+	s, ok := Summarize(it)
+	return s.Mean(), ok
+}
+
+// Variance returns the population variance of the sequence, false if the sequence is empty.
+// It uses Welford's online algorithm so it is numerically stable and only needs a single pass.
+func Variance[T seq.Number](it iter.Seq[T]) (float64, bool) {
+	s, ok := Summarize(it)
+	return s.Variance(), ok
+}
+
+// StdDev returns the population standard deviation of the sequence, false if the sequence is empty.
+func StdDev[T seq.Number](it iter.Seq[T]) (float64, bool) {
+	s, ok := Summarize(it)
+	return s.StdDev(), ok
+}
+
+// interpolate returns the p quantile of sorted using linear interpolation between the closest ranks.
+func interpolate(sorted []float64, p float64) float64 {
+	pos := p * float64(len(sorted)-1)
+	lo := int(math.Floor(pos))
+	hi := int(math.Ceil(pos))
+	return sorted[lo] + (pos-float64(lo))*(sorted[hi]-sorted[lo])
+}
+
+// NewP2Quantile returns a P2Quantile that estimates quantile p, p must be between 0 and 1 inclusive.
+func NewP2Quantile(p float64) (*P2Quantile, error) {
+	if p < 0 || p > 1 || math.IsNaN(p) {
+		return nil, errorx.IllegalArgument.New("quantile must be between 0 and 1, was %v", p)
+	}
+	return &P2Quantile{p: p}, nil
+}
+
+// Quantile estimates quantile p, between 0 and 1 inclusive, of the sequence in a single pass and constant memory.
+// 0.5 is the median, 0.99 is the 99th percentile. The result is an approximation, see P2Quantile.
+// An error is returned if p is out of range or the sequence is empty.
+func Quantile[T seq.Number](it iter.Seq[T], p float64) (float64, error) {
+	qs, err := Quantiles(it, p)
+	if err != nil {
+		return 0, err
+	}
+	return qs[0], nil
+}
+
+// Quantiles estimates several quantiles of the sequence in the same single pass.
+// The results are in the same order as ps.
+//
+// Example usage:
+//
+//	qs, err := stats.Quantiles(latencies, 0.5, 0.95, 0.99)
+func Quantiles[T seq.Number](it iter.Seq[T], ps ...float64) ([]float64, error) {
+	estimators := make([]*P2Quantile, len(ps))
+	for i, p := range ps {
+		q, err := NewP2Quantile(p)
+		if err != nil {
+			return nil, err
+		}
+		estimators[i] = q
+	}
+	for v := range it {
+		for _, q := range estimators {
+			q.Add(float64(v))
+		}
+	}
+	results := make([]float64, len(ps))
+	for i, q := range estimators {
+		v, ok := q.Value()
+		if !ok {
+			return nil, errs.MustNotBeEmpty.New("can not calculate quantiles of an empty sequence")
+		}
+		results[i] = v
+	}
+	return results, nil
+}
+
+// NewHistogram counts the values of the sequence into the buckets defined by bounds.
+// bounds are the inclusive upper bounds of the buckets and must be sorted in ascending order without duplicates,
+// one more bucket is added for values greater than the last bound. See Histogram for how values are counted.
+// Use LinearBounds or ExponentialBounds to generate evenly spaced bounds.
+func NewHistogram[T seq.Number](it iter.Seq[T], bounds ...T) (*Histogram[T], error) {
+	for i := 1; i < len(bounds); i++ {
+		if bounds[i-1] >= bounds[i] {
+			return nil, errorx.IllegalArgument.New("histogram bounds must be strictly ascending, %v is not less than %v", bounds[i-1], bounds[i])
+		}
+	}
+	h := &Histogram[T]{
+		Bounds: slices.Clone(bounds),
+		Counts: make([]int64, len(bounds)+1),
+	}
+	for v := range it {
+		h.Add(v)
+	}
+	return h, nil
+}
+
+// LinearBounds returns count bounds starting at start, each width apart.
+func LinearBounds[T seq.Number](start T, width T, count int) []T {
+	bounds := make([]T, count)
+	for i := range bounds {
+		bounds[i] = start + T(i)*width
+	}
+	return bounds
+}
+
+// ExponentialBounds returns count bounds starting at start, each factor times the previous.
+func ExponentialBounds(start float64, factor float64, count int) []float64 {
+	bounds := make([]float64, count)
+	for i := range bounds {
+		bounds[i] = start * math.Pow(factor, float64(i))
+	}
+	return bounds
+}
+
+// topHeap is a min heap, the smallest of the top k is at the root so it can be replaced by a larger value.
+type topHeap[T any] struct {
+	items   []T
+	compare func(a, b T) int
+}
+
+func (h *topHeap[T]) Len() int           { return len(h.items) }
+func (h *topHeap[T]) Less(i, j int) bool { return h.compare(h.items[i], h.items[j]) < 0 }
+func (h *topHeap[T]) Swap(i, j int)      { h.items[i], h.items[j] = h.items[j], h.items[i] }
+func (h *topHeap[T]) Push(x any)         { h.items = append(h.items, x.(T)) }
+func (h *topHeap[T]) Pop() any {
+	last := h.items[len(h.items)-1]
+	h.items = h.items[:len(h.items)-1]
+	return last
+}
+
+// TopK returns the k largest values of the sequence in descending order.
+// It keeps at most k values in a heap, so it is a single pass in O(n log k) time and O(k) memory.
+// Fewer than k values are returned if the sequence is shorter than k, an error is returned if k is less than 1.
+func TopK[T seq.Number](it iter.Seq[T], k int) ([]T, error) {
+	return TopKFunc(it, k, cmp.Compare[T])
+}
+
+// TopKFunc is TopK for any type using compare to order the values.
+// The values compare considers largest are returned first.
+func TopKFunc[T any](it iter.Seq[T], k int, compare func(a, b T) int) ([]T, error) {
+	if k < 1 {
+		return nil, errorx.IllegalArgument.New("k must be at least 1, was %d", k)
+	}
+	h := &topHeap[T]{items: make([]T, 0, k), compare: compare}
+	for v := range it {
+		if h.Len() < k {
+			heap.Push(h, v)
+		} else if compare(v, h.items[0]) > 0 {
+			h.items[0] = v
+			heap.Fix(h, 0)
+		}
+	}
+	top := make([]T, h.Len())
+	for i := len(top) - 1; i >= 0; i-- {
+		top[i] = heap.Pop(h).(T)
+	}
+	return top, nil
+}
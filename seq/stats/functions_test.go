@@ -0,0 +1,113 @@
+package stats
+
+import (
+	"math"
+	"math/rand/v2"
+	"slices"
+	"testing"
+
+	"github.com/jarrodhroberson/ossgo/seq"
+)
+
+func TestSummarize(t *testing.T) {
+	s, ok := Summarize(seq.ToSeq(2, 4, 4, 4, 5, 5, 7, 9))
+	if !ok {
+		t.Fatal("Summarize() ok = false, want true")
+	}
+	if s.Count != 8 || s.Min != 2 || s.Max != 9 || s.Sum != 40 {
+		t.Errorf("Summarize() = %+v", s)
+	}
+	if s.Mean() != 5 {
+		t.Errorf("Mean() = %v, want 5", s.Mean())
+	}
+	if s.Variance() != 4 {
+		t.Errorf("Variance() = %v, want 4", s.Variance())
+	}
+	if s.StdDev() != 2 {
+		t.Errorf("StdDev() = %v, want 2", s.StdDev())
+	}
+
+	if _, ok = Summarize(seq.ToSeq[int]()); ok {
+		t.Error("Summarize() of empty ok = true, want false")
+	}
+	if _, ok = Max(seq.ToSeq[float64]()); ok {
+		t.Error("Max() of empty ok = true, want false")
+	}
+	if lo, hi, ok := MinMax(seq.ToSeq(3, -1, 7)); !ok || lo != -1 || hi != 7 {
+		t.Errorf("MinMax() = %v, %v, %v, want -1, 7, true", lo, hi, ok)
+	}
+}
+
+func TestQuantile(t *testing.T) {
+	values := make([]float64, 10000)
+	for i := range values {
+		values[i] = float64(i)
+	}
+	rand.New(rand.NewPCG(1, 2)).Shuffle(len(values), func(i, j int) { values[i], values[j] = values[j], values[i] })
+
+	qs, err := Quantiles(slices.Values(values), 0.5, 0.9, 0.99)
+	if err != nil {
+		t.Fatalf("Quantiles() error = %v", err)
+	}
+	for i, want := range []float64{5000, 9000, 9900} {
+		if math.Abs(qs[i]-want) > 100 {
+			t.Errorf("Quantiles()[%d] = %v, want about %v", i, qs[i], want)
+		}
+	}
+
+	if got, err := Quantile(seq.ToSeq(1, 2, 3), 0.5); err != nil || got != 2 {
+		t.Errorf("Quantile() of small sequence = %v, %v, want 2", got, err)
+	}
+	if got, err := Quantile(seq.ToSeq(1, 2, 3, 4, 5), 0.99); err != nil || math.Abs(got-4.96) > 1e-9 {
+		t.Errorf("Quantile() of five values = %v, %v, want 4.96", got, err)
+	}
+	if _, err = Quantile(seq.ToSeq[int](), 0.5); err == nil {
+		t.Error("Quantile() of empty error = nil")
+	}
+	if _, err = Quantile(seq.ToSeq(1), 1.5); err == nil {
+		t.Error("Quantile() out of range error = nil")
+	}
+}
+
+func TestNewHistogram(t *testing.T) {
+	h, err := NewHistogram(seq.ToSeq(0, 1, 5, 10, 11, 25, 100), LinearBounds(0, 10, 3)...)
+	if err != nil {
+		t.Fatalf("NewHistogram() error = %v", err)
+	}
+	if want := []int64{1, 3, 1, 2}; !slices.Equal(h.Counts, want) {
+		t.Errorf("NewHistogram() counts = %v, want %v", h.Counts, want)
+	}
+	if h.Total() != 7 {
+		t.Errorf("Total() = %d, want 7", h.Total())
+	}
+	if _, err = NewHistogram(seq.ToSeq(1), 2, 1); err == nil {
+		t.Error("NewHistogram() unsorted bounds error = nil")
+	}
+}
+
+func TestTopK(t *testing.T) {
+	tests := []struct {
+		name string
+		seq  []int
+		k    int
+		want []int
+	}{
+		{name: "empty", seq: []int{}, k: 2, want: []int{}},
+		{name: "shorter_than_k", seq: []int{3, 1}, k: 5, want: []int{3, 1}},
+		{name: "many", seq: []int{5, 1, 9, 3, 7, 9, 2}, k: 3, want: []int{9, 9, 7}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := TopK(slices.Values(tt.seq), tt.k)
+			if err != nil {
+				t.Fatalf("TopK() error = %v", err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("TopK() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+	if _, err := TopK(seq.ToSeq(1), 0); err == nil {
+		t.Error("TopK() with k = 0 error = nil")
+	}
+}
@@ -0,0 +1,175 @@
+package stats
+
+import (
+	"math"
+	"slices"
+
+	"github.com/jarrodhroberson/ossgo/seq"
+)
+
+// Summary holds the descriptive statistics of a sequence that can all be calculated in a single pass.
+// The mean and variance are calculated with Welford's online algorithm which, unlike summing the values
+// and their squares, does not lose precision on large or nearly constant inputs.
+type Summary[T seq.Number] struct {
+	Count int64
+	Min   T
+	Max   T
+	Sum   T
+	mean  float64
+	m2    float64
+}
+
+// Add adds a single value to the summary.
+func (s *Summary[T]) Add(v T) {
+	if s.Count == 0 {
+		s.Min = v
+		s.Max = v
+	} else {
+		s.Min = min(s.Min, v)
+		s.Max = max(s.Max, v)
+	}
+	s.Count++
+	s.Sum += v
+	x := float64(v)
+	delta := x - s.mean
+	s.mean += delta / float64(s.Count)
+	s.m2 += delta * (x - s.mean)
+}
+
+// Mean returns the arithmetic mean of the values added so far.
+func (s *Summary[T]) Mean() float64 {
+	return s.mean
+}
+
+// Variance returns the population variance of the values added so far.
+func (s *Summary[T]) Variance() float64 {
+	if s.Count == 0 {
+		return 0
+	}
+	return s.m2 / float64(s.Count)
+}
+
+// SampleVariance returns the sample variance, with Bessel's correction, of the values added so far.
+func (s *Summary[T]) SampleVariance() float64 {
+	if s.Count < 2 {
+		return 0
+	}
+	return s.m2 / float64(s.Count-1)
+}
+
+// StdDev returns the population standard deviation of the values added so far.
+func (s *Summary[T]) StdDev() float64 {
+	return math.Sqrt(s.Variance())
+}
+
+// P2Quantile estimates a single quantile of a stream of values in constant memory using the P² algorithm
+// from Jain and Chlamtac, "The P² Algorithm for Dynamic Calculation of Quantiles and Histograms
+// Without Storing Observations" (1985). Only five markers are kept no matter how many values are added.
+//
+// Until five values have been added the quantile is calculated exactly.
+type P2Quantile struct {
+	p       float64
+	count   int
+	heights [5]float64
+	actual  [5]float64
+	desired [5]float64
+	deltas  [5]float64
+}
+
+// Add adds a single value to the estimate.
+func (q *P2Quantile) Add(x float64) {
+	if q.count < 5 {
+		q.heights[q.count] = x
+		q.count++
+		if q.count == 5 {
+			slices.Sort(q.heights[:])
+			for i := range q.actual {
+				q.actual[i] = float64(i + 1)
+			}
+			q.desired = [5]float64{1, 1 + 2*q.p, 1 + 4*q.p, 3 + 2*q.p, 5}
+			q.deltas = [5]float64{0, q.p / 2, q.p, (1 + q.p) / 2, 1}
+		}
+		return
+	}
+	q.count++
+
+	var k int
+	switch {
+	case x < q.heights[0]:
+		q.heights[0] = x
+		k = 0
+	case x >= q.heights[4]:
+		q.heights[4] = x
+		k = 3
+	default:
+		for k = 0; k < 3; k++ {
+			if x < q.heights[k+1] {
+				break
+			}
+		}
+	}
+	for i := k + 1; i < 5; i++ {
+		q.actual[i]++
+	}
+	for i := range q.desired {
+		q.desired[i] += q.deltas[i]
+	}
+
+	for i := 1; i < 4; i++ {
+		d := q.desired[i] - q.actual[i]
+		if (d >= 1 && q.actual[i+1]-q.actual[i] > 1) || (d <= -1 && q.actual[i-1]-q.actual[i] < -1) {
+			sign := math.Copysign(1, d)
+			h := q.parabolic(i, sign)
+			if q.heights[i-1] < h && h < q.heights[i+1] {
+				q.heights[i] = h
+			} else {
+				q.heights[i] = q.linear(i, sign)
+			}
+			q.actual[i] += sign
+		}
+	}
+}
+
+// parabolic is the piecewise parabolic prediction of the new height of marker i.
+func (q *P2Quantile) parabolic(i int, d float64) float64 {
+	n, h := q.actual, q.heights
+	return h[i] + d/(n[i+1]-n[i-1])*((n[i]-n[i-1]+d)*(h[i+1]-h[i])/(n[i+1]-n[i])+(n[i+1]-n[i]-d)*(h[i]-h[i-1])/(n[i]-n[i-1]))
+}
+
+// linear is the fallback linear prediction used when the parabolic prediction is out of order.
+func (q *P2Quantile) linear(i int, d float64) float64 {
+	j := i + int(d)
+	return q.heights[i] + d*(q.heights[j]-q.heights[i])/(q.actual[j]-q.actual[i])
+}
+
+// Value returns the current estimate of the quantile, false if no values have been added.
+func (q *P2Quantile) Value() (float64, bool) {
+	if q.count == 0 {
+		return 0, false
+	}
+	if q.count <= 5 {
+		sorted := slices.Clone(q.heights[:q.count])
+		slices.Sort(sorted)
+		return interpolate(sorted, q.p), true
+	}
+	return q.heights[2], true
+}
+
+// Histogram counts the values of a sequence into buckets.
+// Counts[i] is the number of values v where Bounds[i-1] < v <= Bounds[i], Counts[0] has no lower bound
+// and the last count, Counts[len(Bounds)], holds the values greater than the last bound.
+type Histogram[T seq.Number] struct {
+	Bounds []T
+	Counts []int64
+}
+
+// Add counts a single value into its bucket.
+func (h *Histogram[T]) Add(v T) {
+	i, _ := slices.BinarySearch(h.Bounds, v)
+	h.Counts[i]++
+}
+
+// Total returns the number of values counted in all the buckets.
+func (h *Histogram[T]) Total() int64 {
+	return seq.Sum(slices.Values(h.Counts))
+}
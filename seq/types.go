@@ -2,7 +2,6 @@ package seq
 
 import (
 	"iter"
-	"slices"
 	"sync/atomic"
 )
 
@@ -30,18 +29,42 @@ func (c CountingSeq[T]) Count() int64 {
 	return c.counter.Load()
 }
 
-type MemoizeSeq[T any] struct {
-	delegate []T
+// ErrorHandling determines how the error propagating pipeline stages, MapErr, FilterErr, ReduceErr etc.
+// react when an element of the pipeline fails.
+type ErrorHandling string
+
+func (eh ErrorHandling) String() string {
+	return string(eh)
 }
 
-func (m *MemoizeSeq[T]) Seq() iter.Seq[T] {
-	return slices.Values(m.delegate)
+// stop reports if the pipeline should stop after err was yielded.
+func (eh ErrorHandling) stop(err error) bool {
+	return err != nil && eh == FAIL_ON_FIRST_ERROR
 }
 
-func (m *MemoizeSeq[T]) Seq2() iter.Seq2[int,T] {
-	return slices.All(m.delegate)
+const (
+	// FAIL_ON_FIRST_ERROR yields the first error encountered and then ends the sequence.
+	FAIL_ON_FIRST_ERROR ErrorHandling = "fail_on_first_error"
+	// COLLECT_ERRORS yields every error in place of the element that failed and keeps processing the remaining elements.
+	COLLECT_ERRORS ErrorHandling = "collect_errors"
+)
+
+// Ordering determines if ParallelMap yields its results in the same order as the input sequence.
+type Ordering string
+
+func (o Ordering) String() string {
+	return string(o)
 }
 
-func (m *MemoizeSeq[T]) Len() int {
-	return len(m.delegate)
-}
\ No newline at end of file
+const (
+	// ORDERED yields results in the same order as the elements of the input sequence.
+	ORDERED Ordering = "ordered"
+	// UNORDERED yields results as soon as they are available.
+	UNORDERED Ordering = "unordered"
+)
+
+// Pair holds a single key value pair of an iter.Seq2 so it can be buffered or yielded as one value.
+type Pair[K any, V any] struct {
+	Key   K
+	Value V
+}
@@ -0,0 +1,250 @@
+package seq
+
+import (
+	"iter"
+	"slices"
+	"time"
+
+	errs "github.com/jarrodhroberson/ossgo/errors"
+	"github.com/jarrodhroberson/ossgo/timestamp"
+)
+
+// pairs converts an iter.Seq2 into an iter.Seq of Pair so it can be processed by the iter.Seq versions.
+func pairs[K any, V any](it iter.Seq2[K, V]) iter.Seq[Pair[K, V]] {
+	return func(yield func(Pair[K, V]) bool) {
+		for k, v := range it {
+			if !yield(Pair[K, V]{Key: k, Value: v}) {
+				return
+			}
+		}
+	}
+}
+
+// unpairs converts an iter.Seq of Pair back into an iter.Seq2.
+func unpairs[K any, V any](it iter.Seq[Pair[K, V]]) iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		for p := range it {
+			if !yield(p.Key, p.Value) {
+				return
+			}
+		}
+	}
+}
+
+// SlidingWindow returns an iterator over windows of size consecutive elements, each window starting
+// step elements after the start of the previous one.
+//   - step < size produces overlapping windows.
+//   - step == size produces the same tumbling windows as Chunk.
+//   - step > size skips step - size elements between windows.
+//
+// Only complete windows are yielded, if the sequence has fewer than size elements remaining they are dropped.
+// Each window is a copy so it can be kept and iterated after the next window is yielded.
+// SlidingWindow panics if size or step is less than 1.
+//
+// Example usage:
+//
+//	for w := range seq.SlidingWindow(seq.IntRange(1, 5), 3, 1) {
+//		fmt.Println(slices.Collect(w)) // [1 2 3], [2 3 4], [3 4 5]
+//	}
+func SlidingWindow[T any](it iter.Seq[T], size int, step int) iter.Seq[iter.Seq[T]] {
+	if size < 1 {
+		panic(errs.MinSizeExceededError.New("size %d must be >= 1", size))
+	}
+	if step < 1 {
+		panic(errs.MinSizeExceededError.New("step %d must be >= 1", step))
+	}
+
+	return func(yield func(iter.Seq[T]) bool) {
+		window := make([]T, 0, size)
+		skip := 0
+		for t := range it {
+			if skip > 0 {
+				skip--
+				continue
+			}
+			window = append(window, t)
+			if len(window) < size {
+				continue
+			}
+			if !yield(slices.Values(slices.Clone(window))) {
+				return
+			}
+			if step >= size {
+				window = window[:0]
+				skip = step - size
+			} else {
+				window = append(window[:0], window[step:]...)
+			}
+		}
+	}
+}
+
+// SlidingWindow2 is the iter.Seq2 version of SlidingWindow.
+func SlidingWindow2[K any, V any](it iter.Seq2[K, V], size int, step int) iter.Seq[iter.Seq2[K, V]] {
+	return Map(SlidingWindow(pairs(it), size, step), unpairs[K, V])
+}
+
+// GroupBy groups consecutive elements that have the same key into a single group.
+// The input sequence is expected to be sorted (or at least clustered) by key, every time the key changes
+// a new group is started, so the same key will be yielded more than once if the input is not sorted.
+// Use GroupByAll for unsorted input.
+//
+// GroupBy is lazy, it only reads as far into the input sequence as the group being iterated.
+// Each group must be iterated before moving on to the next one, a group that is skipped or only
+// partially iterated is drained before the next group is yielded and it can not be iterated afterward.
+//
+// Example usage:
+//
+//	words := seq.ToSeq("apple", "avocado", "banana", "blueberry", "cherry")
+//	for letter, group := range seq.GroupBy(words, func(s string) byte { return s[0] }) {
+//		fmt.Println(string(letter), slices.Collect(group)) // a [apple avocado], b [banana blueberry], c [cherry]
+//	}
+func GroupBy[T any, K comparable](it iter.Seq[T], keyFunc func(t T) K) iter.Seq2[K, iter.Seq[T]] {
+	return func(yield func(K, iter.Seq[T]) bool) {
+		next, stop := iter.Pull(it)
+		defer stop()
+
+		v, ok := next()
+		var vk K
+		if ok {
+			vk = keyFunc(v)
+		}
+		advance := func() {
+			v, ok = next()
+			if ok {
+				vk = keyFunc(v)
+			}
+		}
+
+		for ok {
+			key := vk
+			group := func(yield func(T) bool) {
+				for ok && vk == key {
+					current := v
+					advance()
+					if !yield(current) {
+						return
+					}
+				}
+			}
+			if !yield(key, group) {
+				return
+			}
+			// drain whatever is left of the group the caller did not iterate
+			for ok && vk == key {
+				advance()
+			}
+		}
+	}
+}
+
+// GroupBy2 is the iter.Seq2 version of GroupBy.
+func GroupBy2[K any, V any, G comparable](it iter.Seq2[K, V], keyFunc func(k K, v V) G) iter.Seq2[G, iter.Seq2[K, V]] {
+	return func(yield func(G, iter.Seq2[K, V]) bool) {
+		groups := GroupBy(pairs(it), func(p Pair[K, V]) G {
+			return keyFunc(p.Key, p.Value)
+		})
+		for g, group := range groups {
+			if !yield(g, unpairs(group)) {
+				return
+			}
+		}
+	}
+}
+
+// GroupByAll groups all the elements of an unsorted sequence by key.
+// Unlike GroupBy the entire sequence is consumed and held in memory.
+// The elements of each group are in the same order they were in the input sequence.
+func GroupByAll[T any, K comparable](it iter.Seq[T], keyFunc func(t T) K) map[K][]T {
+	m := make(map[K][]T)
+	for t := range it {
+		k := keyFunc(t)
+		m[k] = append(m[k], t)
+	}
+	return m
+}
+
+// GroupByAll2 is the iter.Seq2 version of GroupByAll.
+// The key value pairs of each group are returned as an iter.Seq2 that can be iterated more than once.
+func GroupByAll2[K any, V any, G comparable](it iter.Seq2[K, V], keyFunc func(k K, v V) G) map[G]iter.Seq2[K, V] {
+	grouped := GroupByAll(pairs(it), func(p Pair[K, V]) G {
+		return keyFunc(p.Key, p.Value)
+	})
+	m := make(map[G]iter.Seq2[K, V], len(grouped))
+	for g, ps := range grouped {
+		m[g] = unpairs(slices.Values(ps))
+	}
+	return m
+}
+
+// windowIndex returns the number of whole windows of duration d between origin and ts, rounding down.
+func windowIndex(origin time.Time, d time.Duration, ts *timestamp.Timestamp) int64 {
+	offset := timestamp.To(ts).Sub(origin)
+	idx := int64(offset / d)
+	if offset < 0 && offset%d != 0 {
+		idx--
+	}
+	return idx
+}
+
+// timeWindow groups the elements into consecutive windows of duration d aligned to origin.
+func timeWindow[T any](it iter.Seq[T], origin time.Time, d time.Duration, tsFunc func(t T) *timestamp.Timestamp) iter.Seq2[*timestamp.Period, iter.Seq[T]] {
+	if d <= 0 {
+		panic(errs.MinSizeExceededError.New("window duration %s must be > 0", d))
+	}
+	return func(yield func(*timestamp.Period, iter.Seq[T]) bool) {
+		groups := GroupBy(it, func(t T) int64 {
+			return windowIndex(origin, d, tsFunc(t))
+		})
+		for idx, group := range groups {
+			start := timestamp.From(origin.Add(time.Duration(idx) * d))
+			if !yield(timestamp.NewPeriod(start, start.Add(d)), group) {
+				return
+			}
+		}
+	}
+}
+
+// TimeWindow groups the elements of a sequence into tumbling windows of duration d using the timestamp
+// returned by tsFunc. Windows are aligned to the Unix epoch, the same way time.Time.Truncate aligns times,
+// so a window of time.Hour always starts at the top of the hour.
+//
+// Each window is yielded with the timestamp.Period it covers, start inclusive and end exclusive.
+// The input is expected to be ordered by timestamp, it is processed lazily with GroupBy and
+// has the same restrictions on iterating the groups. Windows with no elements are not yielded.
+// TimeWindow panics if d is not positive.
+//
+// Example usage:
+//
+//	for period, events := range seq.TimeWindow(events, time.Minute, func(e Event) *timestamp.Timestamp { return e.At }) {
+//		fmt.Println(period, seq.Count(events))
+//	}
+func TimeWindow[T any](it iter.Seq[T], d time.Duration, tsFunc func(t T) *timestamp.Timestamp) iter.Seq2[*timestamp.Period, iter.Seq[T]] {
+	return timeWindow(it, time.Unix(0, 0).UTC(), d, tsFunc)
+}
+
+// TimeWindow2 is the iter.Seq2 version of TimeWindow.
+func TimeWindow2[K any, V any](it iter.Seq2[K, V], d time.Duration, tsFunc func(k K, v V) *timestamp.Timestamp) iter.Seq2[*timestamp.Period, iter.Seq2[K, V]] {
+	return periodsToSeq2(TimeWindow(pairs(it), d, func(p Pair[K, V]) *timestamp.Timestamp {
+		return tsFunc(p.Key, p.Value)
+	}))
+}
+
+// PeriodWindow is the same as TimeWindow except the windows are aligned to the start of period and
+// each window is as long as period.Duration(). Elements before or after period are grouped into windows
+// that extend the same alignment in both directions, use Filter to limit the input to the period.
+func PeriodWindow[T any](it iter.Seq[T], period *timestamp.Period, tsFunc func(t T) *timestamp.Timestamp) iter.Seq2[*timestamp.Period, iter.Seq[T]] {
+	return timeWindow(it, timestamp.To(period.Start()), period.Duration(), tsFunc)
+}
+
+// PeriodWindow2 is the iter.Seq2 version of PeriodWindow.
+func PeriodWindow2[K any, V any](it iter.Seq2[K, V], period *timestamp.Period, tsFunc func(k K, v V) *timestamp.Timestamp) iter.Seq2[*timestamp.Period, iter.Seq2[K, V]] {
+	return periodsToSeq2(PeriodWindow(pairs(it), period, func(p Pair[K, V]) *timestamp.Timestamp {
+		return tsFunc(p.Key, p.Value)
+	}))
+}
+
+// periodsToSeq2 converts the windows of pairs back into windows of iter.Seq2.
+func periodsToSeq2[K any, V any](it iter.Seq2[*timestamp.Period, iter.Seq[Pair[K, V]]]) iter.Seq2[*timestamp.Period, iter.Seq2[K, V]] {
+	return Map2(it, PassThruFunc[*timestamp.Period], unpairs[K, V])
+}
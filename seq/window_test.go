@@ -0,0 +1,77 @@
+package seq
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/jarrodhroberson/ossgo/timestamp"
+)
+
+func TestSlidingWindow(t *testing.T) {
+	type testCase struct {
+		name string
+		size int
+		step int
+		want [][]int
+	}
+	tests := []testCase{
+		{name: "overlapping", size: 3, step: 1, want: [][]int{{1, 2, 3}, {2, 3, 4}, {3, 4, 5}}},
+		{name: "tumbling", size: 2, step: 2, want: [][]int{{1, 2}, {3, 4}}},
+		{name: "hopping", size: 1, step: 2, want: [][]int{{1}, {3}, {5}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := slices.Collect(Map(SlidingWindow(IntRange(1, 5), tt.size, tt.step), slices.Collect[int]))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SlidingWindow() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGroupBy(t *testing.T) {
+	words := ToSeq("apple", "avocado", "banana", "blueberry", "cherry", "apricot")
+	got := make([][]string, 0)
+	keys := make([]byte, 0)
+	for k, group := range GroupBy(words, func(s string) byte { return s[0] }) {
+		keys = append(keys, k)
+		got = append(got, slices.Collect(group))
+	}
+	want := [][]string{{"apple", "avocado"}, {"banana", "blueberry"}, {"cherry"}, {"apricot"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GroupBy() = %v, want %v", got, want)
+	}
+	if string(keys) != "abca" {
+		t.Errorf("GroupBy() keys = %s, want %s", string(keys), "abca")
+	}
+
+	t.Run("skipped_groups", func(t *testing.T) {
+		count := 0
+		for range GroupBy(IntRange(1, 10), func(i int) bool { return i > 5 }) {
+			count++
+		}
+		if count != 2 {
+			t.Errorf("GroupBy() groups = %d, want %d", count, 2)
+		}
+	})
+}
+
+func TestTimeWindow(t *testing.T) {
+	start := timestamp.From(time.Date(2024, time.January, 1, 10, 0, 0, 0, time.UTC))
+	minutes := Map(ToSeq(0, 10, 59, 60, 125), func(m int) *timestamp.Timestamp {
+		return start.Add(time.Duration(m) * time.Minute)
+	})
+	got := make([]int64, 0)
+	for period, group := range TimeWindow(minutes, time.Hour, PassThruFunc[*timestamp.Timestamp]) {
+		if period.Duration() != time.Hour {
+			t.Errorf("TimeWindow() period = %s, want duration %s", period, time.Hour)
+		}
+		got = append(got, Count(group))
+	}
+	want := []int64{3, 1, 1}
+	if !slices.Equal(got, want) {
+		t.Errorf("TimeWindow() = %v, want %v", got, want)
+	}
+}
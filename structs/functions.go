@@ -2,19 +2,33 @@ package structs
 
 import (
 	"reflect"
+	"strconv"
 	"strings"
 
 	"github.com/jarrodhroberson/destruct/destruct"
 )
 
+// parseTags parses a struct tag in the conventional format `name:"value1,value2" other:"value"`
+// into one Tag per name. Anything that does not follow the convention ends the parsing.
 func parseTags(t reflect.StructTag) []Tag {
-	tagsWithValues := strings.Split(string(t), " ")
-	tags := make([]Tag, 0, len(tagsWithValues))
-	for _, tag := range tagsWithValues {
-		kv := strings.Split(tag, ":")
-		name := kv[0]
-		values := strings.Split(kv[1], ",")
-		tags = append(tags, NewTag(name, values...))
+	tags := make([]Tag, 0)
+	s := strings.TrimSpace(string(t))
+	for s != "" {
+		colon := strings.Index(s, ":")
+		if colon <= 0 {
+			break
+		}
+		name := s[:colon]
+		quoted, err := strconv.QuotedPrefix(s[colon+1:])
+		if err != nil {
+			break
+		}
+		value, err := strconv.Unquote(quoted)
+		if err != nil {
+			break
+		}
+		tags = append(tags, NewTag(name, strings.Split(value, ",")...))
+		s = strings.TrimSpace(s[colon+1+len(quoted):])
 	}
 	return tags
 }
@@ -26,16 +40,33 @@ func NewTag(name string, values ...string) Tag {
 	}
 }
 
+// Tags returns the parsed struct tags of every field of t keyed by field name.
+// t may be a struct or a pointer to a struct, any other type returns an empty map.
 func Tags[T any](t T) map[string][]Tag {
-	v := reflect.ValueOf(t)
-	m := make(map[string][]Tag, v.NumField())
-	for i := 0; i < v.NumField(); i++ {
-		tags := parseTags(v.Type().Field(i).Tag)
-		m[v.Type().Field(i).Name] = tags
+	rt := reflect.TypeOf(t)
+	for rt != nil && rt.Kind() == reflect.Pointer {
+		rt = rt.Elem()
+	}
+	if rt == nil || rt.Kind() != reflect.Struct {
+		return make(map[string][]Tag)
+	}
+	m := make(map[string][]Tag, rt.NumField())
+	for i := 0; i < rt.NumField(); i++ {
+		m[rt.Field(i).Name] = parseTags(rt.Field(i).Tag)
 	}
 	return m
 }
 
+// FindTag returns the Tag with the given name from tags.
+func FindTag(tags []Tag, name string) (Tag, bool) {
+	for _, tag := range tags {
+		if tag.Name == name {
+			return tag, true
+		}
+	}
+	return Tag{}, false
+}
+
 func Hash[T any](t T) string {
 	return destruct.MustHashIdentity(t)
 }
@@ -2,12 +2,15 @@ package structs
 
 import (
 	"fmt"
+	"slices"
 	"strings"
 )
 
 const ReadOnly = "readonly"
 const Immutable = "immutable"
 const Ignore = "ignore"
+const Encrypt = "encrypt"
+const Deterministic = "deterministic"
 
 type Tag struct {
 	Name   string   `json:"name"`
@@ -21,3 +24,8 @@ func (t Tag) MarshalJSON() ([]byte, error) {
 func (t Tag) String() string {
 	return fmt.Sprintf("{\"%s\": [%s]}", t.Name, strings.Join(t.Values, ","))
 }
+
+// Has reports if value is one of the values of the tag.
+func (t Tag) Has(value string) bool {
+	return slices.Contains(t.Values, value)
+}
@@ -3,6 +3,7 @@ package tasks
 import (
 	"context"
 	"fmt"
+	"iter"
 	"time"
 
 	cloudtasks "cloud.google.com/go/cloudtasks/apiv2"
@@ -11,6 +12,7 @@ import (
 	errs "github.com/jarrodhroberson/ossgo/errors"
 	"github.com/jarrodhroberson/ossgo/functions/must"
 	"github.com/jarrodhroberson/ossgo/gcp"
+	"github.com/jarrodhroberson/ossgo/seq"
 	"github.com/jarrodhroberson/ossgo/timestamp"
 	"github.com/joomcode/errorx"
 	"github.com/rs/zerolog/log"
@@ -189,17 +191,21 @@ type TaskResult struct {
 // Returns:
 //   - <-chan TaskResult: A channel that will receive the task completion result
 func ListenForTaskCompletion(ctx context.Context, client *cloudtasks.Client, task *cloudtaskspb.Task) <-chan TaskResult {
-	resultChan := make(chan TaskResult, 1)
+	// the result is always delivered, even when ctx is done, pollTaskCompletion stops on its own once it has a result
+	return seq.ToChan(context.WithoutCancel(ctx), pollTaskCompletion(ctx, client, task), 1)
+}
 
-	go func() {
-		defer close(resultChan)
+// pollTaskCompletion is a sequence of exactly one TaskResult, it polls the task until it completes,
+// fails or ctx is done.
+func pollTaskCompletion(ctx context.Context, client *cloudtasks.Client, task *cloudtaskspb.Task) iter.Seq[TaskResult] {
+	return func(yield func(TaskResult) bool) {
 		ticker := time.NewTicker(task.ScheduleTime.AsTime().Sub(time.Now()) + time.Second*5)
 		defer ticker.Stop()
 
 		for {
 			select {
 			case <-ctx.Done():
-				resultChan <- TaskResult{Task: task, Error: ctx.Err()}
+				yield(TaskResult{Task: task, Error: ctx.Err()})
 				return
 			case <-ticker.C:
 				getTaskRequest := &cloudtaskspb.GetTaskRequest{
@@ -209,19 +215,17 @@ func ListenForTaskCompletion(ctx context.Context, client *cloudtasks.Client, tas
 
 				t, err := client.GetTask(ctx, getTaskRequest)
 				if err != nil {
-					resultChan <- TaskResult{Task: task, Error: err}
+					yield(TaskResult{Task: task, Error: err})
 					return
 				}
 
 				if t.LastAttempt != nil && t.LastAttempt.ResponseTime != nil && t.LastAttempt.ResponseStatus.Code == int32(200) {
-					resultChan <- TaskResult{Task: t, Error: nil}
+					yield(TaskResult{Task: t, Error: nil})
 					return
 				} else {
 					ticker.Reset(task.ScheduleTime.AsTime().Sub(time.Now()) + t.CreateTime.AsTime().Sub(t.LastAttempt.ResponseTime.AsTime()))
 				}
 			}
 		}
-	}()
-
-	return resultChan
+	}
 }